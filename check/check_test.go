@@ -1,7 +1,9 @@
 package main_test
 
 import (
+	"fmt"
 	"os/exec"
+	"strings"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -30,4 +32,97 @@ var _ = Describe("Check", func() {
 		Eventually(session.Out).Should(gbytes.Say(`\[\]`))
 		Eventually(session.Err).Should(gbytes.Say(""))
 	})
+
+	Context("when an inbound queue is configured", func() {
+		var (
+			sqs     *fakeSQS
+			source  string
+			version string
+			session *gexec.Session
+		)
+
+		BeforeEach(func() {
+			sqs = newFakeSQS()
+			source = fmt.Sprintf(`{
+		"aws_access_key_id": "key123",
+		"aws_secret_access_key": "secret123",
+		"region": "us-east-1",
+		"endpoint": %q,
+		"disable_ssl": true,
+		"inbound_queue_url": "%s/123456789012/replies",
+		"inbound_senders": ["+14151234567"],
+		"inbound_keyword": "^DEPLOY (\\w+)$",
+		"inbound_trigger": true
+	}`, sqs.URL(), sqs.URL())
+			version = "null"
+
+			sqs.Send(reply("reply-2", "+14151234567", "DEPLOY staging", "2026-10-18T09:32:00Z"))
+			sqs.Send(reply("reply-3", "+16505550123", "DEPLOY prod", "2026-10-18T09:33:00Z"))
+			sqs.Send(reply("reply-1", "+14151234567", "DEPLOY prod", "2026-10-18T09:31:00Z"))
+			sqs.Send(reply("reply-4", "+14151234567", "hello", "2026-10-18T09:34:00Z"))
+		})
+
+		AfterEach(func() {
+			sqs.Close()
+		})
+
+		JustBeforeEach(func() {
+			cmd := exec.Command(pathToBuiltBinary)
+			cmd.Stdin = strings.NewReader(fmt.Sprintf(`{"source": %s, "version": %s}`, source, version))
+			var err error
+			session, err = gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(0))
+		})
+
+		It("should emit a version for each reply that passes the filter, oldest first", func() {
+			Expect(session.Out).To(gbytes.Say(`^\[` +
+				`{"Time":"2026-10-18T09:31:00Z","ReplyID":"reply-1","Sender":"\+\*{7}4567","Body":"DEPLOY prod"},` +
+				`{"Time":"2026-10-18T09:32:00Z","ReplyID":"reply-2","Sender":"\+\*{7}4567","Body":"DEPLOY staging"}` +
+				`\]`))
+		})
+
+		It("should only delete the replies it emitted from the queue", func() {
+			Expect(sqs.Actions()).To(Equal([]string{"ReceiveMessage", "DeleteMessageBatch", "ReceiveMessage"}))
+			Expect(sqs.Deleted()).To(Equal([]string{"handle-0", "handle-2"}))
+		})
+
+		Context("when replies are not used as triggers", func() {
+			BeforeEach(func() {
+				source = strings.Replace(source, `"inbound_trigger": true`, `"inbound_trigger": false`, 1)
+			})
+
+			It("should leave the queue to the gets waiting for acknowledgements", func() {
+				Expect(session.Out).To(gbytes.Say(`^\[\]`))
+				Expect(sqs.Actions()).To(BeEmpty())
+			})
+		})
+
+		Context("when a version is passed in", func() {
+			BeforeEach(func() {
+				version = `{"Time":"2026-10-18T09:00:00Z","ReplyID":"reply-0","Sender":"+*******4567","Body":"DEPLOY dev"}`
+			})
+
+			It("should emit it before the new replies", func() {
+				Expect(session.Out).To(gbytes.Say(`^\[{"Time":"2026-10-18T09:00:00Z","ReplyID":"reply-0",.*"ReplyID":"reply-1",.*"ReplyID":"reply-2".*\]`))
+			})
+		})
+	})
+
+	Context("when the inbound configuration is invalid", func() {
+		It("should output an error to stderr", func() {
+			cmd := exec.Command(pathToBuiltBinary)
+			cmd.Stdin = strings.NewReader(`{"source": {"inbound_queue_url": "replies", "inbound_trigger": true}}`)
+			session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(1))
+			Eventually(session.Err).Should(gbytes.Say("source.inbound_queue_url from stdin must be a URL including the scheme and host"))
+		})
+	})
 })
+
+func reply(id string, sender string, body string, timestamp string) string {
+	message := fmt.Sprintf(`{"originationNumber":%q,"destinationNumber":"+18005550100","messageKeyword":"KEYWORD_123456789012","messageBody":%q,"inboundMessageId":%q}`,
+		sender, body, id)
+	return fmt.Sprintf(`{"Type":"Notification","MessageId":"notification-%s","Message":%q,"Timestamp":%q}`, id, message, timestamp)
+}
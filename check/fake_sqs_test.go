@@ -0,0 +1,107 @@
+package main_test
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+)
+
+// fakeSQS is a local stand-in for the SQS query API holding a single queue.
+// Each message is handed out once and remembered as deleted once its receipt
+// handle comes back.
+type fakeSQS struct {
+	server *httptest.Server
+
+	mutex    sync.Mutex
+	requests []url.Values
+	messages []string
+	received int
+	deleted  []string
+}
+
+func newFakeSQS() *fakeSQS {
+	sqs := &fakeSQS{}
+	sqs.server = httptest.NewServer(http.HandlerFunc(sqs.handle))
+	return sqs
+}
+
+func (f *fakeSQS) URL() string {
+	return f.server.URL
+}
+
+func (f *fakeSQS) Close() {
+	f.server.Close()
+}
+
+func (f *fakeSQS) Send(body string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.messages = append(f.messages, body)
+}
+
+func (f *fakeSQS) Deleted() []string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return append([]string{}, f.deleted...)
+}
+
+func (f *fakeSQS) Actions() []string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	actions := []string{}
+	for _, request := range f.requests {
+		actions = append(actions, request.Get("Action"))
+	}
+	return actions
+}
+
+func (f *fakeSQS) handle(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.requests = append(f.requests, r.PostForm)
+
+	action := r.PostForm.Get("Action")
+	switch action {
+	case "ReceiveMessage":
+		result := ""
+		for f.received < len(f.messages) {
+			body := f.messages[f.received]
+			sum := md5.Sum([]byte(body))
+			var escaped bytes.Buffer
+			xml.EscapeText(&escaped, []byte(body))
+			result += fmt.Sprintf(`<Message><MessageId>sqs-%[1]d</MessageId><ReceiptHandle>handle-%[1]d</ReceiptHandle><MD5OfBody>%[2]s</MD5OfBody><Body>%[3]s</Body><Attribute><Name>SentTimestamp</Name><Value>1792315800000</Value></Attribute></Message>`,
+				f.received, hex.EncodeToString(sum[:]), escaped.String())
+			f.received++
+		}
+		writeSQSResponse(w, action, result)
+	case "DeleteMessageBatch":
+		result := ""
+		for i := 1; r.PostForm.Get(fmt.Sprintf("DeleteMessageBatchRequestEntry.%d.Id", i)) != ""; i++ {
+			f.deleted = append(f.deleted, r.PostForm.Get(fmt.Sprintf("DeleteMessageBatchRequestEntry.%d.ReceiptHandle", i)))
+			result += fmt.Sprintf("<DeleteMessageBatchResultEntry><Id>%s</Id></DeleteMessageBatchResultEntry>",
+				r.PostForm.Get(fmt.Sprintf("DeleteMessageBatchRequestEntry.%d.Id", i)))
+		}
+		writeSQSResponse(w, action, result)
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, `<ErrorResponse><Error><Type>Sender</Type><Code>InvalidAction</Code><Message>unknown action %s</Message></Error><RequestId>request-id</RequestId></ErrorResponse>`, action)
+	}
+}
+
+func writeSQSResponse(w http.ResponseWriter, action string, result string) {
+	w.Header().Set("Content-Type", "text/xml")
+	fmt.Fprintf(w, `<%[1]sResponse><%[1]sResult>%[2]s</%[1]sResult><ResponseMetadata><RequestId>request-id</RequestId></ResponseMetadata></%[1]sResponse>`,
+		action, result)
+}
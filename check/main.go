@@ -1,7 +1,97 @@
 package main
 
-import "fmt"
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/nickwei84/sms-resource/lib/awsclient"
+	"github.com/nickwei84/sms-resource/lib/inbound"
+	"github.com/nickwei84/sms-resource/lib/phonenumber"
+	"github.com/nickwei84/sms-resource/out/application"
+	"github.com/nickwei84/sms-resource/out/models"
+)
+
+func handleErr(errMsg string) {
+	fmt.Fprintln(os.Stderr, errMsg)
+	os.Exit(1)
+}
+
+type inputJSON struct {
+	Source  models.Source   `json:"source"`
+	Version *models.Version `json:"version"`
+}
 
 func main() {
-	fmt.Println("[]")
+	var input inputJSON
+
+	stdinData, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		handleErr(fmt.Sprintf("error reading from stdin: %v", err))
+	}
+
+	if len(bytes.TrimSpace(stdinData)) > 0 {
+		err = json.Unmarshal(stdinData, &input)
+		if err != nil {
+			handleErr(fmt.Sprintf("error unmarshalling JSON: %v", err))
+		}
+	}
+
+	versions := []models.Version{}
+
+	// Replies only trigger builds when asked to, so that a queue used for
+	// acknowledgements is left to the gets waiting on it. Otherwise versions
+	// only come from puts.
+	if input.Source.InboundTrigger {
+		versions, err = checkReplies(input.Source, input.Version)
+		if err != nil {
+			handleErr(err.Error())
+		}
+	}
+
+	stdoutOutput, err := json.Marshal(versions)
+	if err != nil {
+		handleErr(fmt.Sprintf("error marshalling output for stdout: %v", err))
+	}
+
+	fmt.Printf("%s\n", stdoutOutput)
+}
+
+// checkReplies emits one version per new reply in the inbound queue, after the
+// current version. Replies are removed from the queue as they are received, so
+// each of them is only emitted once.
+func checkReplies(source models.Source, current *models.Version) ([]models.Version, error) {
+	err := source.CheckInbound()
+	if err != nil {
+		return nil, err
+	}
+
+	filter, err := inbound.NewFilter(source.InboundSenders, source.InboundKeyword, source.DefaultCountryCode)
+	if err != nil {
+		return nil, err
+	}
+
+	client := awsclient.NewAWSClient(application.AWSConfig(source))
+	replies, err := inbound.Receive(client, source.InboundQueueURL, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := []models.Version{}
+	if current != nil {
+		versions = append(versions, *current)
+	}
+
+	for _, reply := range replies {
+		versions = append(versions, models.Version{
+			Time:    reply.Timestamp,
+			ReplyID: reply.ID,
+			Sender:  phonenumber.Mask(reply.Sender),
+			Body:    reply.Body,
+		})
+	}
+
+	return versions, nil
 }
@@ -1,13 +1,24 @@
 package main_test
 
 import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"github.com/onsi/gomega/gbytes"
 	"github.com/onsi/gomega/gexec"
+	"github.com/onsi/gomega/ghttp"
 )
 
 var _ = Describe("In", func() {
@@ -65,25 +76,379 @@ var _ = Describe("In", func() {
 	})
 
 	Context("when version is passed in via stdin", func() {
-		var cmd *exec.Cmd
-		var session *gexec.Session
+		var (
+			cmd     *exec.Cmd
+			session *gexec.Session
+			destDir string
+		)
 
 		BeforeEach(func() {
 			var err error
-			cmd = exec.Command(pathToBuiltBinary)
+			destDir, err = ioutil.TempDir("", "sms-resource-in")
+			Expect(err).NotTo(HaveOccurred())
+
+			cmd = exec.Command(pathToBuiltBinary, destDir)
 			cmd.Stdin = strings.NewReader(`
 {
-	"version": "abc123"
+	"source": {"topic": "concourse"},
+	"version": {
+		"Time": "2026-10-18T09:30:00Z",
+		"MessageID": "message-1",
+		"TopicArn": "arn:aws:sns:us-east-1:123456789012:concourse",
+		"Recipients": "+*******4567,+*******0123",
+		"Body": "deploy failed"
+	}
 }
 `)
+		})
+
+		JustBeforeEach(func() {
+			var err error
 			session, err = gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
 			Expect(err).NotTo(HaveOccurred())
 		})
 
-		It("should output version to stdout", func() {
+		AfterEach(func() {
+			os.RemoveAll(destDir)
+		})
+
+		It("should output version and metadata to stdout", func() {
 			Eventually(session).Should(gexec.Exit(0))
-			Eventually(session.Out).Should(gbytes.Say(`{"version":"abc123"}`))
-			Eventually(session.Err).Should(gbytes.Say(""))
+			Expect(session.Out).To(gbytes.Say(`{"version":{"Time":"2026-10-18T09:30:00Z","MessageID":"message-1","TopicArn":"arn:aws:sns:us-east-1:123456789012:concourse","Recipients":"\+\*{7}4567,\+\*{7}0123","Body":"deploy failed"},`))
+			Expect(session.Out).To(gbytes.Say(`"metadata":\[{"Name":"message_id","Value":"message-1"},{"Name":"topic_arn","Value":"arn:aws:sns:us-east-1:123456789012:concourse"},{"Name":"sent_at","Value":"2026-10-18T09:30:00Z"}\]}`))
+		})
+
+		It("should write the details of the sent message to the destination directory", func() {
+			Eventually(session).Should(gexec.Exit(0))
+			Expect(readFile(destDir, "message_id")).To(Equal("message-1"))
+			Expect(readFile(destDir, "topic_arn")).To(Equal("arn:aws:sns:us-east-1:123456789012:concourse"))
+			Expect(readFile(destDir, "sent_at")).To(Equal("2026-10-18T09:30:00Z"))
+			Expect(readFile(destDir, "recipients.json")).To(MatchJSON(`["+*******4567","+*******0123"]`))
+			Expect(readFile(destDir, "body")).To(Equal("deploy failed"))
+		})
+
+		Context("when waiting for delivery is requested", func() {
+			var (
+				logs         *ghttp.Server
+				secondStatus string
+			)
+
+			BeforeEach(func() {
+				logs = ghttp.NewServer()
+				secondStatus = "SUCCESS"
+				cmd.Stdin = strings.NewReader(fmt.Sprintf(`{
+	"source": {
+		"aws_access_key_id": "key123",
+		"aws_secret_access_key": "secret123",
+		"region": "eu-west-1",
+		"endpoint": %q,
+		"disable_ssl": true
+	},
+	"version": {
+		"Time": "2026-10-18T09:30:00Z",
+		"MessageID": "message-1",
+		"TopicArn": "arn:aws:sns:eu-west-1:123456789012:concourse",
+		"Recipients": "+*******4567,+*******0123"
+	},
+	"params": {"wait_for_delivery": "1m"}
+}`, logs.URL()))
+			})
+
+			JustBeforeEach(func() {
+				logs.AppendHandlers(
+					ghttp.CombineHandlers(
+						ghttp.VerifyHeaderKV("X-Amz-Target", "Logs_20140328.DescribeLogGroups"),
+						ghttp.VerifyBody([]byte(`{"logGroupNamePrefix":"sns/eu-west-1/"}`)),
+						ghttp.RespondWith(http.StatusOK, `{"logGroups":[{"logGroupName":"sns/eu-west-1/123456789012/concourse"}]}`),
+					),
+					ghttp.CombineHandlers(
+						ghttp.VerifyHeaderKV("X-Amz-Target", "Logs_20140328.FilterLogEvents"),
+						ghttp.RespondWith(http.StatusOK, fmt.Sprintf(`{"events":[
+							{"timestamp":1792315801000,"message":"{\"notification\":{\"messageId\":\"message-1\"},\"delivery\":{\"destination\":\"+14151234567\",\"providerResponse\":\"Message has been accepted by phone carrier\"},\"status\":\"SUCCESS\"}"},
+							{"timestamp":1792315802000,"message":"{\"notification\":{\"messageId\":\"message-1\"},\"delivery\":{\"destination\":\"+16505550123\",\"providerResponse\":\"Phone carrier response\"},\"status\":\"%s\"}"}
+						]}`, secondStatus)),
+					),
+				)
+			})
+
+			AfterEach(func() {
+				logs.Close()
+			})
+
+			It("should write the delivery status for each phone number", func() {
+				Eventually(session).Should(gexec.Exit(0))
+				Expect(logs.ReceivedRequests()).To(HaveLen(2))
+				Expect(readFile(destDir, "delivery.json")).To(MatchJSON(`[
+					{"MessageID":"message-1","Destination":"+*******4567","Status":"SUCCESS","ProviderResponse":"Message has been accepted by phone carrier","Timestamp":"2026-10-18T09:30:01Z"},
+					{"MessageID":"message-1","Destination":"+*******0123","Status":"SUCCESS","ProviderResponse":"Phone carrier response","Timestamp":"2026-10-18T09:30:02Z"}
+				]`))
+				Expect(session.Out).To(gbytes.Say(`{"Name":"delivered","Value":"2"},{"Name":"delivery_failed","Value":"0"},{"Name":"delivery_unknown","Value":"0"}`))
+			})
+
+			Context("when fewer subscriptions were confirmed than there are recipients", func() {
+				BeforeEach(func() {
+					cmd.Stdin = strings.NewReader(fmt.Sprintf(`{
+	"source": {
+		"aws_access_key_id": "key123",
+		"aws_secret_access_key": "secret123",
+		"region": "eu-west-1",
+		"endpoint": %q,
+		"disable_ssl": true
+	},
+	"version": {
+		"Time": "2026-10-18T09:30:00Z",
+		"MessageID": "message-1",
+		"TopicArn": "arn:aws:sns:eu-west-1:123456789012:concourse",
+		"Recipients": "+*******4567,+*******0123,+*******9876",
+		"RecipientCount": "2"
+	},
+	"params": {"wait_for_delivery": "1m"}
+}`, logs.URL()))
+				})
+
+				It("should only expect deliveries to the confirmed subscriptions", func() {
+					Eventually(session).Should(gexec.Exit(0))
+					Expect(logs.ReceivedRequests()).To(HaveLen(2))
+					Expect(session.Out).To(gbytes.Say(`{"Name":"delivered","Value":"2"},{"Name":"delivery_failed","Value":"0"},{"Name":"delivery_unknown","Value":"0"}`))
+				})
+			})
+
+			Context("when a carrier rejected the message", func() {
+				BeforeEach(func() {
+					secondStatus = "FAILURE"
+				})
+
+				It("should output an error to stderr", func() {
+					Eventually(session).Should(gexec.Exit(1))
+					Expect(session.Err).To(gbytes.Say(`1 of 2 deliveries failed:\n  \+\*{7}0123: Phone carrier response`))
+				})
+			})
+		})
+
+		Context("when waiting for an acknowledgement is requested", func() {
+			var (
+				sqs      *ghttp.Server
+				ackReply string
+			)
+
+			BeforeEach(func() {
+				sqs = ghttp.NewServer()
+				ackReply = "ACK 4821"
+				cmd.Stdin = strings.NewReader(fmt.Sprintf(`{
+	"source": {
+		"aws_access_key_id": "key123",
+		"aws_secret_access_key": "secret123",
+		"inbound_queue_url": "%[1]s/123456789012/sms-replies",
+		"inbound_senders": ["+14151234567"],
+		"endpoint": %[1]q,
+		"disable_ssl": true
+	},
+	"version": {
+		"Time": "2026-10-18T09:30:00Z",
+		"MessageID": "message-1",
+		"TopicArn": "arn:aws:sns:us-east-1:123456789012:concourse",
+		"Recipients": "+*******4567",
+		"AckCode": "4821"
+	},
+	"params": {"wait_for_ack": "1m"}
+}`, sqs.URL()))
+			})
+
+			JustBeforeEach(func() {
+				sqs.AppendHandlers(
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("POST", "/"),
+						ghttp.VerifyFormKV("Action", "ReceiveMessage"),
+						ghttp.VerifyFormKV("WaitTimeSeconds", "20"),
+						ghttp.RespondWith(http.StatusOK, receiveMessageResponse(
+							inboundSMS("reply-1", "+16505550123", "ACK 4821"),
+							inboundSMS("reply-2", "+14151234567", ackReply),
+						)),
+					),
+					ghttp.CombineHandlers(
+						ghttp.VerifyFormKV("Action", "DeleteMessageBatch"),
+						ghttp.VerifyFormKV("DeleteMessageBatchRequestEntry.1.ReceiptHandle", "handle-2"),
+						ghttp.VerifyForm(map[string][]string{"DeleteMessageBatchRequestEntry.2.ReceiptHandle": nil}),
+						ghttp.RespondWith(http.StatusOK, `<DeleteMessageBatchResponse><DeleteMessageBatchResult><DeleteMessageBatchResultEntry><Id>0</Id></DeleteMessageBatchResultEntry></DeleteMessageBatchResult></DeleteMessageBatchResponse>`),
+					),
+				)
+			})
+
+			AfterEach(func() {
+				sqs.Close()
+			})
+
+			It("should write who acknowledged the message and when", func() {
+				Eventually(session).Should(gexec.Exit(0))
+				Expect(sqs.ReceivedRequests()).To(HaveLen(2))
+				Expect(readFile(destDir, "ack_code")).To(Equal("4821"))
+				Expect(readFile(destDir, "acknowledged_by")).To(Equal("+*******4567"))
+				Expect(readFile(destDir, "acknowledged_at")).To(Equal("2026-10-18T09:31:00Z"))
+				Expect(session.Out).To(gbytes.Say(`{"Name":"acknowledged_by","Value":"\+\*{7}4567"},{"Name":"acknowledged_at","Value":"2026-10-18T09:31:00Z"}`))
+			})
+
+			Context("when the message is refused", func() {
+				BeforeEach(func() {
+					ackReply = "NACK 4821"
+				})
+
+				It("should output an error to stderr", func() {
+					Eventually(session).Should(gexec.Exit(1))
+					Expect(session.Err).To(gbytes.Say(`message with code 4821 was refused by \+\*{7}4567`))
+				})
+			})
+
+			Context("when the message was not sent with an acknowledgement code", func() {
+				BeforeEach(func() {
+					cmd.Stdin = strings.NewReader(`{
+	"source": {"inbound_queue_url": "https://sqs.us-east-1.amazonaws.com/123456789012/sms-replies", "inbound_senders": ["+14151234567"]},
+	"version": {"Time": "2026-10-18T09:30:00Z", "MessageID": "message-1"},
+	"params": {"wait_for_ack": "1m"}
+}`)
+				})
+
+				It("should output an error to stderr", func() {
+					Eventually(session).Should(gexec.Exit(1))
+					Expect(session.Err).To(gbytes.Say("params.wait_for_ack from stdin requires a message sent with params.ack"))
+				})
+			})
+		})
+
+		Context("when the version is a suppressed duplicate", func() {
+			BeforeEach(func() {
+				cmd.Stdin = strings.NewReader(`{
+	"source": {"inbound_queue_url": "https://sqs.us-east-1.amazonaws.com/123456789012/sms-replies", "inbound_senders": ["+14151234567"]},
+	"version": {"Time": "2026-10-18T09:30:00Z", "Body": "deploy failed", "Suppressed": "true"},
+	"params": {"wait_for_delivery": "1m"}
+}`)
+			})
+
+			It("should not wait for delivery and report the suppression", func() {
+				Eventually(session).Should(gexec.Exit(0))
+				Expect(readFile(destDir, "body")).To(Equal("deploy failed"))
+				Expect(session.Out).To(gbytes.Say(`"metadata":\[{"Name":"suppressed_duplicate","Value":"true"},{"Name":"sent_at","Value":"2026-10-18T09:30:00Z"}\]}`))
+			})
+
+			Context("when an acknowledgement is awaited", func() {
+				BeforeEach(func() {
+					cmd.Stdin = strings.NewReader(`{
+	"source": {"inbound_queue_url": "https://sqs.us-east-1.amazonaws.com/123456789012/sms-replies", "inbound_senders": ["+14151234567"]},
+	"version": {"Time": "2026-10-18T09:30:00Z", "Body": "deploy failed", "Suppressed": "true"},
+	"params": {"wait_for_ack": "1m"}
+}`)
+				})
+
+				It("should fail rather than pass the gate", func() {
+					Eventually(session).Should(gexec.Exit(1))
+					Expect(session.Err).To(gbytes.Say("params.wait_for_ack from stdin cannot be used with a suppressed duplicate, which was never sent"))
+				})
+			})
+		})
+
+		Context("when the version is a dry run with an acknowledgement code", func() {
+			BeforeEach(func() {
+				cmd.Stdin = strings.NewReader(`{
+	"source": {"inbound_queue_url": "https://sqs.us-east-1.amazonaws.com/123456789012/sms-replies", "inbound_senders": ["+14151234567"]},
+	"version": {"Time": "2026-10-18T09:30:00Z", "Body": "deploy?", "AckCode": "4821", "DryRun": "true"},
+	"params": {"wait_for_ack": "30m"}
+}`)
+			})
+
+			It("should fail without waiting for a reply", func() {
+				Eventually(session).Should(gexec.Exit(1))
+				Expect(session.Err).To(gbytes.Say("params.wait_for_ack from stdin cannot be used with a dry run, which was never sent"))
+			})
+		})
+
+		Context("when the version is a reply emitted by check", func() {
+			BeforeEach(func() {
+				cmd.Stdin = strings.NewReader(`{
+	"source": {"inbound_keyword": "^DEPLOY (?P<env>\\w+)$"},
+	"version": {"Time": "2026-10-18T09:31:00Z", "ReplyID": "reply-1", "Sender": "+*******4567", "Body": "DEPLOY prod"}
+}`)
+			})
+
+			It("should write the reply and the keyword captures to the destination directory", func() {
+				Eventually(session).Should(gexec.Exit(0))
+				Expect(readFile(destDir, "reply_id")).To(Equal("reply-1"))
+				Expect(readFile(destDir, "sender")).To(Equal("+*******4567"))
+				Expect(readFile(destDir, "body")).To(Equal("DEPLOY prod"))
+				Expect(readFile(destDir, "received_at")).To(Equal("2026-10-18T09:31:00Z"))
+				Expect(readFile(destDir, "captures.json")).To(MatchJSON(`{"1": "prod", "env": "prod"}`))
+			})
+
+			It("should output the reply as metadata", func() {
+				Eventually(session).Should(gexec.Exit(0))
+				Expect(session.Out).To(gbytes.Say(`"metadata":\[{"Name":"reply_id","Value":"reply-1"},{"Name":"sender","Value":"\+\*{7}4567"},{"Name":"received_at","Value":"2026-10-18T09:31:00Z"}\]`))
+			})
+		})
+
+		Context("when the version is from a put that did not record the message details", func() {
+			BeforeEach(func() {
+				cmd.Stdin = strings.NewReader(`{"version": {"Time": "2026-10-18T09:30:00Z"}}`)
+			})
+
+			It("should write empty files", func() {
+				Eventually(session).Should(gexec.Exit(0))
+				Expect(readFile(destDir, "message_id")).To(BeEmpty())
+				Expect(readFile(destDir, "recipients.json")).To(Equal("[]"))
+				Expect(session.Out).To(gbytes.Say(`"metadata":\[{"Name":"sent_at","Value":"2026-10-18T09:30:00Z"}\]`))
+			})
+		})
+	})
+
+	Context("when the destination directory is not passed as an argument", func() {
+		var session *gexec.Session
+
+		BeforeEach(func() {
+			cmd := exec.Command(pathToBuiltBinary)
+			cmd.Stdin = strings.NewReader(`{"version": {"Time": "2026-10-18T09:30:00Z"}}`)
+			var err error
+			session, err = gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should output an error to stderr", func() {
+			Eventually(session).Should(gexec.Exit(1))
+			Eventually(session.Err).Should(gbytes.Say("error: destination directory was not provided as an argument"))
 		})
 	})
 })
+
+func readFile(dir string, name string) string {
+	contents, err := ioutil.ReadFile(filepath.Join(dir, name))
+	Expect(err).NotTo(HaveOccurred())
+	return string(contents)
+}
+
+func inboundSMS(id string, sender string, body string) string {
+	message, err := json.Marshal(map[string]string{
+		"originationNumber": sender,
+		"messageBody":       body,
+		"inboundMessageId":  id,
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	notification, err := json.Marshal(map[string]string{
+		"Type":      "Notification",
+		"MessageId": "notification-" + id,
+		"Message":   string(message),
+		"Timestamp": "2026-10-18T09:31:00Z",
+	})
+	Expect(err).NotTo(HaveOccurred())
+	return string(notification)
+}
+
+// receiveMessageResponse is an SQS ReceiveMessage response handing out bodies,
+// with receipt handles numbered from 1.
+func receiveMessageResponse(bodies ...string) string {
+	messages := ""
+	for i, body := range bodies {
+		sum := md5.Sum([]byte(body))
+		var escaped bytes.Buffer
+		xml.EscapeText(&escaped, []byte(body))
+		messages += fmt.Sprintf(`<Message><MessageId>sqs-%[1]d</MessageId><ReceiptHandle>handle-%[1]d</ReceiptHandle><MD5OfBody>%[2]s</MD5OfBody><Body>%[3]s</Body></Message>`,
+			i+1, hex.EncodeToString(sum[:]), escaped.String())
+	}
+	return "<ReceiveMessageResponse><ReceiveMessageResult>" + messages + "</ReceiveMessageResult></ReceiveMessageResponse>"
+}
@@ -5,6 +5,19 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nickwei84/sms-resource/lib/awsclient"
+	"github.com/nickwei84/sms-resource/lib/delivery"
+	"github.com/nickwei84/sms-resource/lib/inbound"
+	"github.com/nickwei84/sms-resource/lib/phonenumber"
+	"github.com/nickwei84/sms-resource/lib/sms"
+	"github.com/nickwei84/sms-resource/out/application"
+	"github.com/nickwei84/sms-resource/out/models"
+	"github.com/nickwei84/sms-resource/out/retry"
 )
 
 func handleErr(errMsg string) {
@@ -12,30 +25,290 @@ func handleErr(errMsg string) {
 	os.Exit(1)
 }
 
+type inputJSON struct {
+	Source  models.Source   `json:"source"`
+	Version *models.Version `json:"version"`
+	Params  inputParams     `json:"params"`
+}
+
+type inputParams struct {
+	WaitForDelivery models.Duration `json:"wait_for_delivery"`
+	WaitForAck      models.Duration `json:"wait_for_ack"`
+}
+
+type outputJSON struct {
+	Version  models.Version     `json:"version"`
+	Metadata []sms.MetadataItem `json:"metadata"`
+}
+
 func main() {
-	var output struct {
-		Version interface{} `json:"version"`
-	}
+	var input inputJSON
 
 	stdinData, err := ioutil.ReadAll(os.Stdin)
 	if err != nil {
 		handleErr(fmt.Sprintf("error reading from stdin: %v", err))
 	}
 
-	err = json.Unmarshal(stdinData, &output)
+	err = json.Unmarshal(stdinData, &input)
 	if err != nil {
 		handleErr(fmt.Sprintf("error unmarshalling JSON: %v", err))
 	}
 
-	if output.Version == nil {
+	if input.Version == nil {
 		fmt.Fprintf(os.Stderr, "error: version key pair is missing from stdin")
 		os.Exit(1)
 	}
 
-	stdoutOutput, err := json.Marshal(output)
+	if len(os.Args) < 2 {
+		handleErr("error: destination directory was not provided as an argument")
+	}
+
+	var metadata []sms.MetadataItem
+	if input.Version.IsReply() {
+		metadata, err = getReply(os.Args[1], input.Source, *input.Version)
+	} else {
+		metadata, err = getSentMessage(os.Args[1], input.Source, *input.Version, input.Params)
+	}
+	if err != nil {
+		handleErr(err.Error())
+	}
+
+	stdoutOutput, err := json.Marshal(outputJSON{
+		Version:  *input.Version,
+		Metadata: metadata,
+	})
 	if err != nil {
 		handleErr(fmt.Sprintf("error marshalling output for stdout: %v", err))
 	}
 
 	fmt.Printf("%s", []byte(stdoutOutput))
 }
+
+// getSentMessage writes the details of a message sent by out into destDir and,
+// when asked to, waits for its delivery statuses and for its acknowledgement.
+func getSentMessage(destDir string, source models.Source, version models.Version, params inputParams) ([]sms.MetadataItem, error) {
+	recipients, err := json.Marshal(splitList(version.Recipients))
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling recipients: %v", err)
+	}
+
+	err = writeFiles(destDir, map[string]string{
+		"message_id":      version.MessageID,
+		"topic_arn":       version.TopicArn,
+		"sent_at":         version.Time.Format(time.RFC3339),
+		"recipients.json": string(recipients),
+		"body":            version.Body,
+		"ack_code":        version.AckCode,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := versionMetadata(version)
+
+	if params.WaitForDelivery > 0 && version.MessageID != "" {
+		report, err := fetchDelivery(destDir, source, version, time.Duration(params.WaitForDelivery))
+		if err != nil {
+			return nil, err
+		}
+
+		metadata = append(metadata, report.Metadata()...)
+
+		err = report.Err()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// A suppressed duplicate was never sent, so nobody can acknowledge it, and
+	// passing the gate without an acknowledgement would approve it.
+	if params.WaitForAck > 0 && version.Suppressed != "" {
+		return nil, fmt.Errorf("params.wait_for_ack from stdin cannot be used with a suppressed duplicate, which was never sent")
+	}
+
+	if params.WaitForAck > 0 && version.DryRun != "" {
+		return nil, fmt.Errorf("params.wait_for_ack from stdin cannot be used with a dry run, which was never sent")
+	}
+
+	if params.WaitForAck > 0 {
+		ackMetadata, err := waitForAck(destDir, source, version, time.Duration(params.WaitForAck))
+		if err != nil {
+			return nil, err
+		}
+
+		metadata = append(metadata, ackMetadata...)
+	}
+
+	return metadata, nil
+}
+
+// waitForAck blocks until one of source.inbound_senders acknowledges the
+// message with its code, and writes who did and when into destDir.
+func waitForAck(destDir string, source models.Source, version models.Version, timeout time.Duration) ([]sms.MetadataItem, error) {
+	if version.AckCode == "" {
+		return nil, fmt.Errorf("params.wait_for_ack from stdin requires a message sent with params.ack")
+	}
+
+	err := source.CheckInbound()
+	if err != nil {
+		return nil, err
+	}
+
+	smsService, err := application.NewSMSService(source)
+	if err != nil {
+		return nil, err
+	}
+
+	client := retry.NewSMSService(smsService, retry.NewPolicy(source))
+	app := application.NewApplication(client, models.SMSConfig{Source: source})
+
+	// Replies always arrive through SNS two-way SMS, whichever provider sent
+	// the message.
+	queue := awsclient.NewAWSClient(application.AWSConfig(source))
+	reply, err := app.WaitForAck(inbound.NewListener(queue, source.InboundQueueURL), version.AckCode, version.Time, timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	acknowledgedBy := phonenumber.Mask(reply.Sender)
+	acknowledgedAt := reply.Timestamp.Format(time.RFC3339)
+
+	err = writeFiles(destDir, map[string]string{
+		"acknowledged_by": acknowledgedBy,
+		"acknowledged_at": acknowledgedAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return []sms.MetadataItem{
+		{Name: "acknowledged_by", Value: acknowledgedBy},
+		{Name: "acknowledged_at", Value: acknowledgedAt},
+	}, nil
+}
+
+// getReply writes a reply emitted by check into destDir, along with the groups
+// captured from it by source.inbound_keyword.
+func getReply(destDir string, source models.Source, version models.Version) ([]sms.MetadataItem, error) {
+	filter, err := inbound.NewFilter(nil, source.InboundKeyword, "")
+	if err != nil {
+		return nil, fmt.Errorf("source.inbound_keyword from stdin is not a valid regular expression: %v", err)
+	}
+
+	captures, err := json.Marshal(filter.Captures(version.Body))
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling captures: %v", err)
+	}
+
+	err = writeFiles(destDir, map[string]string{
+		"reply_id":      version.ReplyID,
+		"sender":        version.Sender,
+		"body":          version.Body,
+		"received_at":   version.Time.Format(time.RFC3339),
+		"captures.json": string(captures),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return []sms.MetadataItem{
+		{Name: "reply_id", Value: version.ReplyID},
+		{Name: "sender", Value: version.Sender},
+		{Name: "received_at", Value: version.Time.Format(time.RFC3339)},
+	}, nil
+}
+
+// writeFiles writes each of files into destDir, so that later steps of the
+// build can read them.
+func writeFiles(destDir string, files map[string]string) error {
+	err := os.MkdirAll(destDir, 0755)
+	if err != nil {
+		return fmt.Errorf("error creating destination directory: %v", err)
+	}
+
+	for name, contents := range files {
+		err = ioutil.WriteFile(filepath.Join(destDir, name), []byte(contents), 0644)
+		if err != nil {
+			return fmt.Errorf("error writing %s: %v", name, err)
+		}
+	}
+
+	return nil
+}
+
+// fetchDelivery waits for the delivery statuses of the sent message and writes
+// them to delivery.json in destDir, with the phone numbers masked. A message
+// published to a topic is expected to reach every confirmed subscription it
+// was published to, as out counted them, and each message published directly
+// reaches a single phone number.
+func fetchDelivery(destDir string, source models.Source, version models.Version, timeout time.Duration) (delivery.Report, error) {
+	messageIDs := splitList(version.MessageID)
+	perMessage := 1
+	if version.TopicArn != "" {
+		perMessage = len(splitList(version.Recipients))
+
+		// Versions emitted before the count was recorded only have the
+		// subscribers given to the put.
+		if recipientCount, err := strconv.Atoi(version.RecipientCount); err == nil {
+			perMessage = recipientCount
+		}
+	}
+
+	// The version does not record which provider sent each message, so with a
+	// provider chain only the messages sent by the first provider are found.
+	if len(source.Providers) > 0 {
+		chain, err := source.ProviderChain()
+		if err != nil {
+			return delivery.Report{}, err
+		}
+		source = chain[0]
+	}
+
+	smsService, err := application.NewSMSService(source)
+	if err != nil {
+		return delivery.Report{}, err
+	}
+
+	client := retry.NewSMSService(smsService, retry.NewPolicy(source))
+	statuses, err := client.WaitForDelivery(messageIDs, perMessage, version.Time, timeout)
+	if err != nil {
+		return delivery.Report{}, err
+	}
+
+	for i := range statuses {
+		statuses[i].Destination = phonenumber.Mask(statuses[i].Destination)
+	}
+
+	contents, err := json.Marshal(statuses)
+	if err != nil {
+		return delivery.Report{}, fmt.Errorf("error marshalling delivery statuses: %v", err)
+	}
+
+	err = ioutil.WriteFile(filepath.Join(destDir, "delivery.json"), contents, 0644)
+	if err != nil {
+		return delivery.Report{}, fmt.Errorf("error writing delivery.json: %v", err)
+	}
+
+	return delivery.NewReport(statuses, perMessage*len(messageIDs)), nil
+}
+
+func versionMetadata(version models.Version) []sms.MetadataItem {
+	metadata := []sms.MetadataItem{}
+	if version.MessageID != "" {
+		metadata = append(metadata, sms.MetadataItem{Name: "message_id", Value: version.MessageID})
+	}
+	if version.TopicArn != "" {
+		metadata = append(metadata, sms.MetadataItem{Name: "topic_arn", Value: version.TopicArn})
+	}
+	if version.Suppressed != "" {
+		metadata = append(metadata, sms.MetadataItem{Name: "suppressed_duplicate", Value: version.Suppressed})
+	}
+	return append(metadata, sms.MetadataItem{Name: "sent_at", Value: version.Time.Format(time.RFC3339)})
+}
+
+func splitList(list string) []string {
+	if list == "" {
+		return []string{}
+	}
+	return strings.Split(list, ",")
+}
@@ -2,22 +2,97 @@ package awsclient
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
 	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
 	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/nickwei84/sms-resource/lib/sms"
+)
+
+// SNS lists subscriptions that are awaiting confirmation with a placeholder
+// instead of an ARN, and Subscribe answers with a differently spelled one.
+const (
+	pendingConfirmationArn          = "PendingConfirmation"
+	subscribePendingConfirmationArn = "pending confirmation"
 )
 
 type AWSClient struct {
-	snsService *sns.SNS
+	snsService  *sns.SNS
+	logsService *cloudwatchlogs.CloudWatchLogs
+	sqsService  *sqs.SQS
+}
+
+const DefaultRegion = "us-east-1"
+
+type Config struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	AssumeRoleArn   string
+	ExternalID      string
+	RoleSessionName string
+	Region          string
+	Endpoint        string
+	DisableSSL      bool
 }
 
-func NewAWSClient(awsAccessKeyID string, awsSecretAccessKey string) AWSClient {
-	creds := credentials.NewStaticCredentials(awsAccessKeyID, awsSecretAccessKey, "")
+func NewAWSClient(config Config) AWSClient {
+	sess := NewSession(config)
 	return AWSClient{
-		snsService: sns.New(session.New(), aws.NewConfig().WithCredentials(creds).WithRegion("us-east-1")),
+		// Retries of SNS calls are left to the caller, which knows which
+		// calls are safe to repeat and how long the whole put may take.
+		snsService:  sns.New(sess, aws.NewConfig().WithMaxRetries(0)),
+		logsService: cloudwatchlogs.New(sess),
+		sqsService:  sqs.New(sess),
+	}
+}
+
+// NewSession returns an AWS session for config, for the services this client
+// does not cover.
+func NewSession(config Config) *session.Session {
+	return session.New(awsConfig(config))
+}
+
+// awsConfig uses the static credentials from config when they are provided, and
+// otherwise leaves the credentials unset so that the SDK falls back to its
+// default provider chain (environment, shared credentials file, EC2 instance
+// role). When a role is given, it is assumed using those credentials.
+func awsConfig(config Config) *aws.Config {
+	region := config.Region
+	if region == "" {
+		region = DefaultRegion
+	}
+
+	awsConfig := aws.NewConfig().WithRegion(region)
+
+	if config.AccessKeyID != "" {
+		awsConfig = awsConfig.WithCredentials(credentials.NewStaticCredentials(config.AccessKeyID, config.SecretAccessKey, config.SessionToken))
+	}
+
+	if config.AssumeRoleArn != "" {
+		awsConfig = awsConfig.WithCredentials(stscreds.NewCredentials(session.New(awsConfig), config.AssumeRoleArn, func(p *stscreds.AssumeRoleProvider) {
+			p.RoleSessionName = config.RoleSessionName
+			if config.ExternalID != "" {
+				p.ExternalID = aws.String(config.ExternalID)
+			}
+		}))
+	}
+
+	if config.Endpoint != "" {
+		awsConfig = awsConfig.WithEndpoint(config.Endpoint)
+	}
+
+	if config.DisableSSL {
+		awsConfig = awsConfig.WithDisableSSL(true)
 	}
+
+	return awsConfig
 }
 
 func (s AWSClient) CreateTopic(topic string) (string, error) {
@@ -25,7 +100,7 @@ func (s AWSClient) CreateTopic(topic string) (string, error) {
 		Name: aws.String(topic),
 	})
 	if err != nil {
-		return "", fmt.Errorf("error creating topic: %v", err)
+		return "", fmt.Errorf("error creating topic: %w", err)
 	}
 
 	topicArn := *createTopicResp.TopicArn
@@ -36,59 +111,139 @@ func (s AWSClient) CreateTopic(topic string) (string, error) {
 		AttributeValue: aws.String(topic),
 	})
 	if err != nil {
-		return "", fmt.Errorf("error creating SMS display name for topic: %v", err)
+		return "", fmt.Errorf("error creating SMS display name for topic: %w", err)
 	}
 
 	return topicArn, nil
 }
 
-func (s AWSClient) GetExistingSubscribers(topicArn string) ([]string, error) {
-	existingSubscribers := []string{}
+func (s AWSClient) FindTopic(topic string) (string, error) {
+	var topicArn string
 
-	// TODO: this returns a max of 100 subscriptions, additional calls will be needed to retrieve paginated results
-	listSubscriptionsByTopicResp, err := s.snsService.ListSubscriptionsByTopic(&sns.ListSubscriptionsByTopicInput{
-		TopicArn: aws.String(topicArn),
+	err := s.snsService.ListTopicsPages(&sns.ListTopicsInput{}, func(page *sns.ListTopicsOutput, lastPage bool) bool {
+		for _, t := range page.Topics {
+			if strings.HasSuffix(*t.TopicArn, ":"+topic) {
+				topicArn = *t.TopicArn
+				return false
+			}
+		}
+		return true
 	})
 	if err != nil {
-		return existingSubscribers, fmt.Errorf("error getting list of existing subscribers: %v", err)
+		return "", fmt.Errorf("error finding topic: %w", err)
 	}
 
-	subscriptions := listSubscriptionsByTopicResp.Subscriptions
+	return topicArn, nil
+}
 
-	if subscriptions == nil {
-		return existingSubscribers, nil
-	}
+func (s AWSClient) GetExistingSubscribers(topicArn string) ([]sms.Subscription, error) {
+	existingSubscriptions := []sms.Subscription{}
 
-	for _, subscription := range subscriptions {
-		existingSubscribers = append(existingSubscribers, *subscription.Endpoint)
+	err := s.snsService.ListSubscriptionsByTopicPages(&sns.ListSubscriptionsByTopicInput{
+		TopicArn: aws.String(topicArn),
+	}, func(page *sns.ListSubscriptionsByTopicOutput, lastPage bool) bool {
+		for _, subscription := range page.Subscriptions {
+			existingSubscriptions = append(existingSubscriptions, sms.Subscription{
+				Endpoint:            aws.StringValue(subscription.Endpoint),
+				Protocol:            aws.StringValue(subscription.Protocol),
+				SubscriptionArn:     aws.StringValue(subscription.SubscriptionArn),
+				PendingConfirmation: isPendingConfirmation(aws.StringValue(subscription.SubscriptionArn)),
+			})
+		}
+		return true
+	})
+	if err != nil {
+		return []sms.Subscription{}, fmt.Errorf("error getting list of existing subscribers: %w", err)
 	}
 
-	return existingSubscribers, nil
+	return existingSubscriptions, nil
 }
 
-func (s AWSClient) CreateNewSubscriptions(topicArn string, newSubscribers []string) error {
+func (s AWSClient) CreateNewSubscriptions(topicArn string, newSubscribers []string) ([]sms.Subscription, error) {
+	subscriptions := []sms.Subscription{}
+
 	for _, subscriber := range newSubscribers {
-		_, err := s.snsService.Subscribe(&sns.SubscribeInput{
+		subscribeResp, err := s.snsService.Subscribe(&sns.SubscribeInput{
 			TopicArn: aws.String(topicArn),
 			Protocol: aws.String("sms"),
 			Endpoint: aws.String(subscriber),
 		})
 		if err != nil {
-			return fmt.Errorf("error subscribing %s: %v", subscriber, err)
+			return subscriptions, fmt.Errorf("error subscribing %s: %w", subscriber, err)
+		}
+
+		subscriptions = append(subscriptions, sms.Subscription{
+			Endpoint:            subscriber,
+			Protocol:            sms.ProtocolSMS,
+			SubscriptionArn:     aws.StringValue(subscribeResp.SubscriptionArn),
+			PendingConfirmation: isPendingConfirmation(aws.StringValue(subscribeResp.SubscriptionArn)),
+		})
+	}
+
+	return subscriptions, nil
+}
+
+func isPendingConfirmation(subscriptionArn string) bool {
+	return subscriptionArn == pendingConfirmationArn || subscriptionArn == subscribePendingConfirmationArn
+}
+
+func (s AWSClient) RemoveSubscriptions(subscriptions []sms.Subscription) error {
+	for _, subscription := range subscriptions {
+		_, err := s.snsService.Unsubscribe(&sns.UnsubscribeInput{
+			SubscriptionArn: aws.String(subscription.SubscriptionArn),
+		})
+		if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == "NotFound" {
+			// already removed, e.g. by an earlier attempt that was retried
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("error unsubscribing %s: %w", subscription.Endpoint, err)
 		}
 	}
 
 	return nil
 }
 
-func (s AWSClient) PublishMessage(topicArn string, message string) error {
-	_, err := s.snsService.Publish(&sns.PublishInput{
-		TopicArn: aws.String(topicArn),
-		Message:  aws.String(message),
+func (s AWSClient) PublishMessage(topicArn string, message string, attributes sms.MessageAttributes) (string, error) {
+	publishResp, err := s.snsService.Publish(&sns.PublishInput{
+		TopicArn:          aws.String(topicArn),
+		Message:           aws.String(message),
+		MessageAttributes: smsAttributes(attributes),
 	})
 	if err != nil {
-		return fmt.Errorf("error publishing message: %v", err)
+		return "", fmt.Errorf("error publishing message: %w", err)
 	}
 
-	return nil
+	return aws.StringValue(publishResp.MessageId), nil
+}
+
+func smsAttributes(attributes sms.MessageAttributes) map[string]*sns.MessageAttributeValue {
+	smsAttributes := map[string]*sns.MessageAttributeValue{}
+
+	if attributes.SenderID != "" {
+		smsAttributes["AWS.SNS.SMS.SenderID"] = &sns.MessageAttributeValue{
+			DataType:    aws.String("String"),
+			StringValue: aws.String(attributes.SenderID),
+		}
+	}
+
+	if attributes.SMSType != "" {
+		smsAttributes["AWS.SNS.SMS.SMSType"] = &sns.MessageAttributeValue{
+			DataType:    aws.String("String"),
+			StringValue: aws.String(attributes.SMSType),
+		}
+	}
+
+	if attributes.MaxPrice != "" {
+		smsAttributes["AWS.SNS.SMS.MaxPrice"] = &sns.MessageAttributeValue{
+			DataType:    aws.String("Number"),
+			StringValue: aws.String(attributes.MaxPrice),
+		}
+	}
+
+	if len(smsAttributes) == 0 {
+		return nil
+	}
+
+	return smsAttributes
 }
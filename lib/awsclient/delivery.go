@@ -0,0 +1,170 @@
+package awsclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/nickwei84/sms-resource/lib/sms"
+)
+
+// SNS logs SMS deliveries to CloudWatch Logs groups named after the region and
+// account, e.g. sns/us-east-1/123456789012/DirectPublishToPhoneNumber for
+// successes and the same name with a /Failure suffix for failures. Messages
+// published to a topic are logged to groups named after the topic instead.
+const deliveryLogGroupPrefix = "sns/%s/"
+
+const deliveryPollInterval = 5 * time.Second
+
+// Delivery status logs are searched from a little before the message was sent,
+// in case the local clock is ahead of the one SNS logs with.
+const deliveryClockSkew = time.Minute
+
+// CloudWatch Logs rejects filter patterns longer than this, so the message IDs
+// are searched for in batches.
+const maxFilterPatternLength = 1024
+
+// deliveryLogEvent is the part of an SNS delivery status log entry that is
+// reported back.
+type deliveryLogEvent struct {
+	Notification struct {
+		MessageID string `json:"messageId"`
+	} `json:"notification"`
+	Delivery struct {
+		Destination      string `json:"destination"`
+		ProviderResponse string `json:"providerResponse"`
+	} `json:"delivery"`
+	Status string `json:"status"`
+}
+
+// WaitForDelivery polls the SNS delivery status logs for the messages with
+// messageIDs, sent no earlier than since, until perMessage statuses have been
+// logged for each of them or timeout has passed. The statuses found so far are
+// returned either way; a message whose delivery was not logged in time is
+// simply missing from them.
+func (s AWSClient) WaitForDelivery(messageIDs []string, perMessage int, since time.Time, timeout time.Duration) ([]sms.DeliveryStatus, error) {
+	deadline := time.Now().Add(timeout)
+
+	for {
+		statuses, err := s.deliveryStatuses(messageIDs, since)
+		if err != nil {
+			return nil, err
+		}
+
+		if allLogged(statuses, messageIDs, perMessage) || time.Now().Add(deliveryPollInterval).After(deadline) {
+			return statuses, nil
+		}
+
+		time.Sleep(deliveryPollInterval)
+	}
+}
+
+func allLogged(statuses []sms.DeliveryStatus, messageIDs []string, perMessage int) bool {
+	logged := map[string]int{}
+	for _, status := range statuses {
+		logged[status.MessageID]++
+	}
+
+	for _, messageID := range messageIDs {
+		if logged[messageID] < perMessage {
+			return false
+		}
+	}
+	return true
+}
+
+func (s AWSClient) deliveryStatuses(messageIDs []string, since time.Time) ([]sms.DeliveryStatus, error) {
+	statuses := []sms.DeliveryStatus{}
+	if len(messageIDs) == 0 {
+		return statuses, nil
+	}
+
+	logGroups, err := s.deliveryLogGroups()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, logGroup := range logGroups {
+		for _, pattern := range messageIDFilterPatterns(messageIDs) {
+			found, err := s.filterDeliveryStatuses(logGroup, pattern, since)
+			if err != nil {
+				return nil, err
+			}
+			statuses = append(statuses, found...)
+		}
+	}
+
+	return statuses, nil
+}
+
+func (s AWSClient) filterDeliveryStatuses(logGroup string, pattern string, since time.Time) ([]sms.DeliveryStatus, error) {
+	statuses := []sms.DeliveryStatus{}
+
+	err := s.logsService.FilterLogEventsPages(&cloudwatchlogs.FilterLogEventsInput{
+		LogGroupName:  aws.String(logGroup),
+		FilterPattern: aws.String(pattern),
+		StartTime:     aws.Int64(since.Add(-deliveryClockSkew).UnixNano() / int64(time.Millisecond)),
+	}, func(page *cloudwatchlogs.FilterLogEventsOutput, lastPage bool) bool {
+		for _, event := range page.Events {
+			var logEvent deliveryLogEvent
+			if json.Unmarshal([]byte(aws.StringValue(event.Message)), &logEvent) != nil {
+				continue
+			}
+
+			statuses = append(statuses, sms.DeliveryStatus{
+				MessageID:        logEvent.Notification.MessageID,
+				Destination:      logEvent.Delivery.Destination,
+				Status:           logEvent.Status,
+				ProviderResponse: logEvent.Delivery.ProviderResponse,
+				Timestamp:        time.Unix(0, aws.Int64Value(event.Timestamp)*int64(time.Millisecond)).UTC(),
+			})
+		}
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error searching delivery status logs in %s: %w", logGroup, err)
+	}
+
+	return statuses, nil
+}
+
+func (s AWSClient) deliveryLogGroups() ([]string, error) {
+	logGroups := []string{}
+
+	err := s.logsService.DescribeLogGroupsPages(&cloudwatchlogs.DescribeLogGroupsInput{
+		LogGroupNamePrefix: aws.String(fmt.Sprintf(deliveryLogGroupPrefix, aws.StringValue(s.logsService.Config.Region))),
+	}, func(page *cloudwatchlogs.DescribeLogGroupsOutput, lastPage bool) bool {
+		for _, logGroup := range page.LogGroups {
+			logGroups = append(logGroups, aws.StringValue(logGroup.LogGroupName))
+		}
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing delivery status log groups: %w", err)
+	}
+
+	return logGroups, nil
+}
+
+// messageIDFilterPatterns returns filter patterns matching the delivery status
+// logs of the messages, each as long as CloudWatch Logs allows.
+func messageIDFilterPatterns(messageIDs []string) []string {
+	patterns := []string{}
+	conditions := []string{}
+	for _, messageID := range messageIDs {
+		condition := fmt.Sprintf("$.notification.messageId = %q", messageID)
+		if len(conditions) > 0 && len(filterPattern(conditions))+len(" || ")+len(condition) > maxFilterPatternLength {
+			patterns = append(patterns, filterPattern(conditions))
+			conditions = []string{}
+		}
+		conditions = append(conditions, condition)
+	}
+	return append(patterns, filterPattern(conditions))
+}
+
+func filterPattern(conditions []string) string {
+	return "{ " + strings.Join(conditions, " || ") + " }"
+}
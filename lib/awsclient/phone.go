@@ -0,0 +1,104 @@
+package awsclient
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/nickwei84/sms-resource/lib/sms"
+)
+
+// The vendored SNS client predates SMS support, so the phone number operations
+// below are described here and sent through the client's request pipeline.
+
+type publishToPhoneInput struct {
+	_ struct{} `type:"structure"`
+
+	Message *string `type:"string" required:"true"`
+
+	MessageAttributes map[string]*sns.MessageAttributeValue `locationNameKey:"Name" locationNameValue:"Value" type:"map"`
+
+	PhoneNumber *string `type:"string"`
+}
+
+func (s AWSClient) PublishToPhone(phoneNumber string, message string, attributes sms.MessageAttributes) (string, error) {
+	output := &sns.PublishOutput{}
+	req := s.snsService.NewRequest(&request.Operation{
+		Name:       "Publish",
+		HTTPMethod: "POST",
+		HTTPPath:   "/",
+	}, &publishToPhoneInput{
+		Message:           aws.String(message),
+		MessageAttributes: smsAttributes(attributes),
+		PhoneNumber:       aws.String(phoneNumber),
+	}, output)
+
+	err := req.Send()
+	if err != nil {
+		return "", fmt.Errorf("error publishing message to %s: %w", phoneNumber, err)
+	}
+
+	return aws.StringValue(output.MessageId), nil
+}
+
+type checkIfPhoneNumberIsOptedOutInput struct {
+	_ struct{} `type:"structure"`
+
+	PhoneNumber *string `locationName:"phoneNumber" type:"string" required:"true"`
+}
+
+type checkIfPhoneNumberIsOptedOutOutput struct {
+	_ struct{} `type:"structure"`
+
+	IsOptedOut *bool `locationName:"isOptedOut" type:"boolean"`
+}
+
+// IsOptedOut reports whether phoneNumber has replied STOP to the account's
+// messages, in which case SNS drops any message sent to it.
+func (s AWSClient) IsOptedOut(phoneNumber string) (bool, error) {
+	output := &checkIfPhoneNumberIsOptedOutOutput{}
+	req := s.snsService.NewRequest(&request.Operation{
+		Name:       "CheckIfPhoneNumberIsOptedOut",
+		HTTPMethod: "POST",
+		HTTPPath:   "/",
+	}, &checkIfPhoneNumberIsOptedOutInput{
+		PhoneNumber: aws.String(phoneNumber),
+	}, output)
+
+	err := req.Send()
+	if err != nil {
+		return false, fmt.Errorf("error checking whether %s opted out: %w", phoneNumber, err)
+	}
+
+	return aws.BoolValue(output.IsOptedOut), nil
+}
+
+type optInPhoneNumberInput struct {
+	_ struct{} `type:"structure"`
+
+	PhoneNumber *string `locationName:"phoneNumber" type:"string" required:"true"`
+}
+
+type optInPhoneNumberOutput struct {
+	_ struct{} `type:"structure"`
+}
+
+// OptIn lets phoneNumber receive the account's messages again after it opted
+// out. SNS only allows this once every 30 days per number.
+func (s AWSClient) OptIn(phoneNumber string) error {
+	req := s.snsService.NewRequest(&request.Operation{
+		Name:       "OptInPhoneNumber",
+		HTTPMethod: "POST",
+		HTTPPath:   "/",
+	}, &optInPhoneNumberInput{
+		PhoneNumber: aws.String(phoneNumber),
+	}, &optInPhoneNumberOutput{})
+
+	err := req.Send()
+	if err != nil {
+		return fmt.Errorf("error opting in %s: %w", phoneNumber, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,85 @@
+package awsclient
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/nickwei84/sms-resource/lib/sms"
+)
+
+// SQS hands out and deletes at most this many messages per call.
+const sqsBatchSize = 10
+
+// SQS long polls for at most this long per call.
+const sqsMaxWait = 20 * time.Second
+
+const sentTimestampAttribute = "SentTimestamp"
+
+// ReceiveMessages returns the messages waiting in the queue. With a wait of a
+// second or more, it long polls until a message arrives or the wait is over.
+func (s AWSClient) ReceiveMessages(queueURL string, wait time.Duration) ([]sms.QueueMessage, error) {
+	if wait > sqsMaxWait {
+		wait = sqsMaxWait
+	}
+
+	receiveResp, err := s.sqsService.ReceiveMessage(&sqs.ReceiveMessageInput{
+		QueueUrl:            aws.String(queueURL),
+		MaxNumberOfMessages: aws.Int64(sqsBatchSize),
+		AttributeNames:      []*string{aws.String(sentTimestampAttribute)},
+		WaitTimeSeconds:     aws.Int64(int64(wait / time.Second)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error receiving messages from %s: %w", queueURL, err)
+	}
+
+	messages := []sms.QueueMessage{}
+	for _, message := range receiveResp.Messages {
+		sentTimestamp, _ := strconv.ParseInt(aws.StringValue(message.Attributes[sentTimestampAttribute]), 10, 64)
+		messages = append(messages, sms.QueueMessage{
+			Body:          aws.StringValue(message.Body),
+			ReceiptHandle: aws.StringValue(message.ReceiptHandle),
+			SentTimestamp: time.Unix(0, sentTimestamp*int64(time.Millisecond)).UTC(),
+		})
+	}
+
+	return messages, nil
+}
+
+func (s AWSClient) DeleteMessages(queueURL string, messages []sms.QueueMessage) error {
+	for start := 0; start < len(messages); start += sqsBatchSize {
+		end := start + sqsBatchSize
+		if end > len(messages) {
+			end = len(messages)
+		}
+
+		entries := []*sqs.DeleteMessageBatchRequestEntry{}
+		for i, message := range messages[start:end] {
+			entries = append(entries, &sqs.DeleteMessageBatchRequestEntry{
+				Id:            aws.String(strconv.Itoa(start + i)),
+				ReceiptHandle: aws.String(message.ReceiptHandle),
+			})
+		}
+
+		deleteResp, err := s.sqsService.DeleteMessageBatch(&sqs.DeleteMessageBatchInput{
+			QueueUrl: aws.String(queueURL),
+			Entries:  entries,
+		})
+		if err != nil {
+			return fmt.Errorf("error deleting messages from %s: %w", queueURL, err)
+		}
+
+		if len(deleteResp.Failed) > 0 {
+			failures := []string{}
+			for _, failed := range deleteResp.Failed {
+				failures = append(failures, aws.StringValue(failed.Message))
+			}
+			return fmt.Errorf("error deleting %d messages from %s: %s", len(deleteResp.Failed), queueURL, strings.Join(failures, "; "))
+		}
+	}
+
+	return nil
+}
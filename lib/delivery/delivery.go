@@ -0,0 +1,63 @@
+package delivery
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/nickwei84/sms-resource/lib/phonenumber"
+	"github.com/nickwei84/sms-resource/lib/sms"
+)
+
+// Report sorts the delivery statuses logged for a sent message into the ones
+// delivered and the ones rejected, and counts the deliveries that were
+// expected but not logged.
+type Report struct {
+	Delivered []sms.DeliveryStatus
+	Failed    []sms.DeliveryStatus
+	Unknown   int
+}
+
+func NewReport(statuses []sms.DeliveryStatus, expected int) Report {
+	report := Report{
+		Delivered: []sms.DeliveryStatus{},
+		Failed:    []sms.DeliveryStatus{},
+	}
+
+	for _, status := range statuses {
+		if status.Status == sms.DeliveryStatusSuccess {
+			report.Delivered = append(report.Delivered, status)
+		} else {
+			report.Failed = append(report.Failed, status)
+		}
+	}
+
+	if logged := len(statuses); logged < expected {
+		report.Unknown = expected - logged
+	}
+
+	return report
+}
+
+func (r Report) Metadata() []sms.MetadataItem {
+	return []sms.MetadataItem{
+		{Name: "delivered", Value: strconv.Itoa(len(r.Delivered))},
+		{Name: "delivery_failed", Value: strconv.Itoa(len(r.Failed))},
+		{Name: "delivery_unknown", Value: strconv.Itoa(r.Unknown)},
+	}
+}
+
+// Err lists the deliveries the carriers rejected, with the phone numbers
+// masked, or returns nil if there were none. Deliveries that were not logged
+// are not treated as failures.
+func (r Report) Err() error {
+	if len(r.Failed) == 0 {
+		return nil
+	}
+
+	lines := []string{fmt.Sprintf("%d of %d deliveries failed:", len(r.Failed), len(r.Delivered)+len(r.Failed)+r.Unknown)}
+	for _, status := range r.Failed {
+		lines = append(lines, fmt.Sprintf("  %s: %s", phonenumber.Mask(status.Destination), status.ProviderResponse))
+	}
+	return fmt.Errorf("%s", strings.Join(lines, "\n"))
+}
@@ -0,0 +1,13 @@
+package delivery_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestDelivery(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Delivery Suite")
+}
@@ -0,0 +1,62 @@
+package delivery_test
+
+import (
+	"github.com/nickwei84/sms-resource/lib/delivery"
+	"github.com/nickwei84/sms-resource/lib/sms"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Delivery", func() {
+	var (
+		delivered = sms.DeliveryStatus{
+			MessageID:        "message-1",
+			Destination:      "+14151234567",
+			Status:           "SUCCESS",
+			ProviderResponse: "Message has been accepted by phone carrier",
+		}
+		failed = sms.DeliveryStatus{
+			MessageID:        "message-1",
+			Destination:      "+16505550123",
+			Status:           "FAILURE",
+			ProviderResponse: "Phone carrier has blocked this message",
+		}
+	)
+
+	Describe("NewReport", func() {
+		It("should sort the statuses into delivered and failed", func() {
+			report := delivery.NewReport([]sms.DeliveryStatus{delivered, failed}, 2)
+			Expect(report.Delivered).To(Equal([]sms.DeliveryStatus{delivered}))
+			Expect(report.Failed).To(Equal([]sms.DeliveryStatus{failed}))
+			Expect(report.Unknown).To(Equal(0))
+		})
+
+		It("should count the expected deliveries that were not logged", func() {
+			report := delivery.NewReport([]sms.DeliveryStatus{delivered}, 3)
+			Expect(report.Unknown).To(Equal(2))
+		})
+	})
+
+	Describe("Metadata", func() {
+		It("should report the counts", func() {
+			report := delivery.NewReport([]sms.DeliveryStatus{delivered, failed}, 3)
+			Expect(report.Metadata()).To(Equal([]sms.MetadataItem{
+				{Name: "delivered", Value: "1"},
+				{Name: "delivery_failed", Value: "1"},
+				{Name: "delivery_unknown", Value: "1"},
+			}))
+		})
+	})
+
+	Describe("Err", func() {
+		It("should return nil when nothing failed", func() {
+			Expect(delivery.NewReport([]sms.DeliveryStatus{delivered}, 2).Err()).NotTo(HaveOccurred())
+		})
+
+		It("should list the failed deliveries with the numbers masked", func() {
+			err := delivery.NewReport([]sms.DeliveryStatus{delivered, failed}, 2).Err()
+			Expect(err).To(MatchError("1 of 2 deliveries failed:\n" +
+				"  +*******0123: Phone carrier has blocked this message"))
+		})
+	})
+})
@@ -0,0 +1,237 @@
+package inbound
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/nickwei84/sms-resource/lib/phonenumber"
+	"github.com/nickwei84/sms-resource/lib/sms"
+)
+
+// Receive makes at most this many calls to the queue, so that a queue that
+// keeps filling up does not keep a check running forever.
+const maxReceives = 10
+
+//go:generate counterfeiter . Queue
+type Queue interface {
+	ReceiveMessages(queueURL string, wait time.Duration) ([]sms.QueueMessage, error)
+	DeleteMessages(queueURL string, messages []sms.QueueMessage) error
+}
+
+// Filter picks out the replies to act on: those sent from one of the allowed
+// numbers, and whose body matches the keyword pattern. An empty pattern lets
+// every reply from an allowed number through, but an empty allow-list lets
+// none through.
+type Filter struct {
+	senders map[string]bool
+	keyword *regexp.Regexp
+}
+
+func NewFilter(senders []string, keyword string, defaultCountryCode string) (Filter, error) {
+	filter := Filter{senders: map[string]bool{}}
+
+	normalizedSenders, err := phonenumber.NormalizeAll(senders, defaultCountryCode)
+	if err != nil {
+		return Filter{}, err
+	}
+	for _, sender := range normalizedSenders {
+		filter.senders[sender] = true
+	}
+
+	if keyword != "" {
+		filter.keyword, err = regexp.Compile(keyword)
+		if err != nil {
+			return Filter{}, err
+		}
+	}
+
+	return filter, nil
+}
+
+func (f Filter) Matches(reply sms.Reply) bool {
+	sender, err := phonenumber.Normalize(reply.Sender, "")
+	if err != nil || !f.senders[sender] {
+		return false
+	}
+
+	return f.keyword == nil || f.keyword.MatchString(reply.Body)
+}
+
+// Captures returns the groups captured by the keyword pattern in body, keyed
+// by their index and, for named groups, also by their name.
+func (f Filter) Captures(body string) map[string]string {
+	captures := map[string]string{}
+	if f.keyword == nil {
+		return captures
+	}
+
+	match := f.keyword.FindStringSubmatch(body)
+	if match == nil {
+		return captures
+	}
+
+	for i, name := range f.keyword.SubexpNames() {
+		if i == 0 {
+			continue
+		}
+		captures[strconv.Itoa(i)] = match[i]
+		if name != "" {
+			captures[name] = match[i]
+		}
+	}
+
+	return captures
+}
+
+// snsNotification is the envelope SNS wraps a message in when it delivers it
+// to an SQS queue without raw message delivery.
+type snsNotification struct {
+	Type      string
+	MessageID string `json:"MessageId"`
+	Message   string
+	Timestamp time.Time
+}
+
+// inboundSMS is the message SNS two-way SMS publishes for each reply.
+type inboundSMS struct {
+	OriginationNumber          string `json:"originationNumber"`
+	MessageBody                string `json:"messageBody"`
+	InboundMessageID           string `json:"inboundMessageId"`
+	PreviousPublishedMessageID string `json:"previousPublishedMessageId"`
+}
+
+// Parse reads a reply from a queue message, whether or not SNS wrapped it in a
+// notification envelope.
+func Parse(message sms.QueueMessage) (sms.Reply, error) {
+	body := message.Body
+	timestamp := message.SentTimestamp
+
+	var notification snsNotification
+	err := json.Unmarshal([]byte(body), &notification)
+	if err == nil && notification.Type == "Notification" {
+		body = notification.Message
+		if !notification.Timestamp.IsZero() {
+			timestamp = notification.Timestamp
+		}
+	}
+
+	var inbound inboundSMS
+	err = json.Unmarshal([]byte(body), &inbound)
+	if err != nil {
+		return sms.Reply{}, fmt.Errorf("error parsing inbound SMS: %v", err)
+	}
+
+	if inbound.OriginationNumber == "" || inbound.InboundMessageID == "" {
+		return sms.Reply{}, fmt.Errorf("error parsing inbound SMS: originationNumber or inboundMessageId is missing")
+	}
+
+	return sms.Reply{
+		ID:                         inbound.InboundMessageID,
+		Sender:                     inbound.OriginationNumber,
+		Body:                       inbound.MessageBody,
+		Timestamp:                  timestamp,
+		PreviousPublishedMessageID: inbound.PreviousPublishedMessageID,
+	}, nil
+}
+
+// Receive takes every message waiting in the queue and returns the replies
+// that pass filter, oldest first. Only those replies are deleted; the other
+// messages become visible again once their visibility timeout is over, for
+// whoever else reads the queue, such as a get waiting for an acknowledgement.
+func Receive(queue Queue, queueURL string, filter Filter) ([]sms.Reply, error) {
+	replies := []sms.Reply{}
+
+	for i := 0; i < maxReceives; i++ {
+		messages, err := queue.ReceiveMessages(queueURL, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(messages) == 0 {
+			break
+		}
+
+		matched := []sms.QueueMessage{}
+		for _, message := range messages {
+			reply, err := Parse(message)
+			if err == nil && filter.Matches(reply) {
+				replies = append(replies, reply)
+				matched = append(matched, message)
+			}
+		}
+
+		if len(matched) > 0 {
+			err = queue.DeleteMessages(queueURL, matched)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	sort.SliceStable(replies, func(i, j int) bool {
+		return replies[i].Timestamp.Before(replies[j].Timestamp)
+	})
+
+	return replies, nil
+}
+
+// Listener receives replies from a queue as they arrive. Unlike Receive, it
+// only deletes the replies it is told to, so the other messages in the queue
+// become visible again for whoever else reads it.
+type Listener struct {
+	queue    Queue
+	queueURL string
+	messages map[string]sms.QueueMessage
+}
+
+func NewListener(queue Queue, queueURL string) *Listener {
+	return &Listener{
+		queue:    queue,
+		queueURL: queueURL,
+		messages: map[string]sms.QueueMessage{},
+	}
+}
+
+// Receive waits up to wait for messages to arrive in the queue and returns the
+// replies among them. Messages that are not replies are skipped.
+func (l *Listener) Receive(wait time.Duration) ([]sms.Reply, error) {
+	messages, err := l.queue.ReceiveMessages(l.queueURL, wait)
+	if err != nil {
+		return nil, err
+	}
+
+	replies := []sms.Reply{}
+	for _, message := range messages {
+		reply, err := Parse(message)
+		if err != nil {
+			continue
+		}
+		l.messages[reply.ID] = message
+		replies = append(replies, reply)
+	}
+
+	return replies, nil
+}
+
+// Delete removes replies returned by Receive from the queue.
+func (l *Listener) Delete(replies []sms.Reply) error {
+	messages := []sms.QueueMessage{}
+	for _, reply := range replies {
+		message, ok := l.messages[reply.ID]
+		if !ok {
+			continue
+		}
+		messages = append(messages, message)
+		delete(l.messages, reply.ID)
+	}
+
+	if len(messages) == 0 {
+		return nil
+	}
+
+	return l.queue.DeleteMessages(l.queueURL, messages)
+}
@@ -0,0 +1,255 @@
+package inbound_test
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nickwei84/sms-resource/lib/inbound"
+	"github.com/nickwei84/sms-resource/lib/inbound/inboundfakes"
+	"github.com/nickwei84/sms-resource/lib/sms"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func inboundSMS(id string, sender string, body string) string {
+	return fmt.Sprintf(`{"originationNumber":%q,"destinationNumber":"+18005550100","messageKeyword":"KEYWORD_123456789012","messageBody":%q,"inboundMessageId":%q,"previousPublishedMessageId":"message-1"}`,
+		sender, body, id)
+}
+
+func notification(message string, timestamp string) string {
+	return fmt.Sprintf(`{"Type":"Notification","MessageId":"notification-1","TopicArn":"arn:aws:sns:us-east-1:123456789012:replies","Message":%q,"Timestamp":%q}`,
+		message, timestamp)
+}
+
+var _ = Describe("Inbound", func() {
+	Describe("Parse", func() {
+		It("should parse a reply wrapped in an SNS notification", func() {
+			reply, err := inbound.Parse(sms.QueueMessage{
+				Body: notification(inboundSMS("reply-1", "+14151234567", "DEPLOY prod"), "2026-10-18T09:30:00.000Z"),
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(reply).To(Equal(sms.Reply{
+				ID:                         "reply-1",
+				Sender:                     "+14151234567",
+				Body:                       "DEPLOY prod",
+				Timestamp:                  time.Date(2026, 10, 18, 9, 30, 0, 0, time.UTC),
+				PreviousPublishedMessageID: "message-1",
+			}))
+		})
+
+		It("should parse a reply delivered as a raw message", func() {
+			sentTimestamp := time.Date(2026, 10, 18, 9, 31, 0, 0, time.UTC)
+			reply, err := inbound.Parse(sms.QueueMessage{
+				Body:          inboundSMS("reply-2", "+14151234567", "ACK 4821"),
+				SentTimestamp: sentTimestamp,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(reply.ID).To(Equal("reply-2"))
+			Expect(reply.Timestamp).To(Equal(sentTimestamp))
+		})
+
+		It("should return an error for a message that is not a reply", func() {
+			_, err := inbound.Parse(sms.QueueMessage{Body: `{"hello": "world"}`})
+			Expect(err).To(MatchError("error parsing inbound SMS: originationNumber or inboundMessageId is missing"))
+
+			_, err = inbound.Parse(sms.QueueMessage{Body: "hello"})
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("Filter", func() {
+		It("should let every reply from an allowed sender through without a keyword pattern", func() {
+			filter, err := inbound.NewFilter([]string{"+16505550123"}, "", "")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(filter.Matches(sms.Reply{Sender: "+16505550123", Body: "anything"})).To(BeTrue())
+		})
+
+		It("should not let any reply through without allowed senders", func() {
+			filter, err := inbound.NewFilter(nil, "", "")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(filter.Matches(sms.Reply{Sender: "+16505550123", Body: "anything"})).To(BeFalse())
+		})
+
+		It("should only let replies from allowed senders through", func() {
+			filter, err := inbound.NewFilter([]string{"(415) 123-4567"}, "", "1")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(filter.Matches(sms.Reply{Sender: "+14151234567"})).To(BeTrue())
+			Expect(filter.Matches(sms.Reply{Sender: "+16505550123"})).To(BeFalse())
+		})
+
+		It("should only let replies matching the keyword pattern through", func() {
+			filter, err := inbound.NewFilter([]string{"+14151234567"}, `^(?i)DEPLOY (\w+)$`, "")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(filter.Matches(sms.Reply{Sender: "+14151234567", Body: "deploy prod"})).To(BeTrue())
+			Expect(filter.Matches(sms.Reply{Sender: "+14151234567", Body: "please deploy prod"})).To(BeFalse())
+		})
+
+		It("should return an error for an invalid sender or pattern", func() {
+			_, err := inbound.NewFilter([]string{"abc"}, "", "")
+			Expect(err).To(HaveOccurred())
+
+			_, err = inbound.NewFilter(nil, "DEPLOY (", "")
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should return the captures of the keyword pattern by index and name", func() {
+			filter, err := inbound.NewFilter(nil, `^DEPLOY (?P<env>\w+)( now)?$`, "")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(filter.Captures("DEPLOY prod")).To(Equal(map[string]string{
+				"1":   "prod",
+				"env": "prod",
+				"2":   "",
+			}))
+			Expect(filter.Captures("hello")).To(BeEmpty())
+		})
+	})
+
+	Describe("Receive", func() {
+		var (
+			queue   *inboundfakes.FakeQueue
+			filter  inbound.Filter
+			batches [][]sms.QueueMessage
+		)
+
+		BeforeEach(func() {
+			queue = new(inboundfakes.FakeQueue)
+			batches = [][]sms.QueueMessage{
+				{
+					{Body: notification(inboundSMS("reply-2", "+14151234567", "DEPLOY staging"), "2026-10-18T09:32:00Z"), ReceiptHandle: "handle-1"},
+					{Body: notification(inboundSMS("reply-3", "+16505550123", "DEPLOY prod"), "2026-10-18T09:33:00Z"), ReceiptHandle: "handle-2"},
+					{Body: "not a reply", ReceiptHandle: "handle-3"},
+				},
+				{
+					{Body: notification(inboundSMS("reply-1", "+14151234567", "DEPLOY prod"), "2026-10-18T09:31:00Z"), ReceiptHandle: "handle-4"},
+				},
+			}
+			queue.ReceiveMessagesStub = func(queueURL string, wait time.Duration) ([]sms.QueueMessage, error) {
+				if len(batches) == 0 {
+					return []sms.QueueMessage{}, nil
+				}
+				batch := batches[0]
+				batches = batches[1:]
+				return batch, nil
+			}
+
+			var err error
+			filter, err = inbound.NewFilter([]string{"+14151234567"}, "^DEPLOY", "")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should return the replies that pass the filter, oldest first", func() {
+			replies, err := inbound.Receive(queue, "queue-url", filter)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(replies).To(HaveLen(2))
+			Expect(replies[0].ID).To(Equal("reply-1"))
+			Expect(replies[1].ID).To(Equal("reply-2"))
+			Expect(queue.ReceiveMessagesCallCount()).To(Equal(3))
+			queueURL, wait := queue.ReceiveMessagesArgsForCall(0)
+			Expect(queueURL).To(Equal("queue-url"))
+			Expect(wait).To(BeZero())
+		})
+
+		It("should only delete the replies that pass the filter", func() {
+			_, err := inbound.Receive(queue, "queue-url", filter)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(queue.DeleteMessagesCallCount()).To(Equal(2))
+			queueURL, messages := queue.DeleteMessagesArgsForCall(0)
+			Expect(queueURL).To(Equal("queue-url"))
+			Expect(messages).To(HaveLen(1))
+			Expect(messages[0].ReceiptHandle).To(Equal("handle-1"))
+			_, messages = queue.DeleteMessagesArgsForCall(1)
+			Expect(messages).To(HaveLen(1))
+			Expect(messages[0].ReceiptHandle).To(Equal("handle-4"))
+		})
+
+		It("should not delete anything when no reply passes the filter", func() {
+			queue.ReceiveMessagesStub = nil
+			queue.ReceiveMessagesReturns([]sms.QueueMessage{{Body: "not a reply"}}, nil)
+			_, err := inbound.Receive(queue, "queue-url", filter)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(queue.DeleteMessagesCallCount()).To(BeZero())
+		})
+
+		It("should stop receiving after a bounded number of calls", func() {
+			queue.ReceiveMessagesReturns([]sms.QueueMessage{{Body: "not a reply"}}, nil)
+			queue.ReceiveMessagesStub = nil
+			_, err := inbound.Receive(queue, "queue-url", filter)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(queue.ReceiveMessagesCallCount()).To(Equal(10))
+		})
+
+		It("should return an error when receiving fails", func() {
+			queue.ReceiveMessagesStub = nil
+			queue.ReceiveMessagesReturns(nil, errors.New("error receiving messages from queue-url: access denied"))
+			_, err := inbound.Receive(queue, "queue-url", filter)
+			Expect(err).To(MatchError("error receiving messages from queue-url: access denied"))
+		})
+
+		It("should return an error when deleting fails", func() {
+			queue.DeleteMessagesReturns(errors.New("error deleting messages from queue-url: access denied"))
+			_, err := inbound.Receive(queue, "queue-url", filter)
+			Expect(err).To(MatchError("error deleting messages from queue-url: access denied"))
+		})
+	})
+
+	Describe("Listener", func() {
+		var (
+			queue    *inboundfakes.FakeQueue
+			listener *inbound.Listener
+		)
+
+		BeforeEach(func() {
+			queue = new(inboundfakes.FakeQueue)
+			queue.ReceiveMessagesReturns([]sms.QueueMessage{
+				{Body: notification(inboundSMS("reply-1", "+14151234567", "ACK 4821"), "2026-10-18T09:31:00Z"), ReceiptHandle: "handle-1"},
+				{Body: "not a reply", ReceiptHandle: "handle-2"},
+				{Body: notification(inboundSMS("reply-2", "+16505550123", "DEPLOY prod"), "2026-10-18T09:32:00Z"), ReceiptHandle: "handle-3"},
+			}, nil)
+			listener = inbound.NewListener(queue, "queue-url")
+		})
+
+		It("should return the replies received within the wait", func() {
+			replies, err := listener.Receive(20 * time.Second)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(replies).To(HaveLen(2))
+			Expect(replies[0].ID).To(Equal("reply-1"))
+			Expect(replies[1].ID).To(Equal("reply-2"))
+
+			queueURL, wait := queue.ReceiveMessagesArgsForCall(0)
+			Expect(queueURL).To(Equal("queue-url"))
+			Expect(wait).To(Equal(20 * time.Second))
+		})
+
+		It("should not delete anything it is not told to", func() {
+			_, err := listener.Receive(time.Second)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(queue.DeleteMessagesCallCount()).To(Equal(0))
+		})
+
+		It("should delete only the given replies", func() {
+			replies, err := listener.Receive(time.Second)
+			Expect(err).NotTo(HaveOccurred())
+
+			err = listener.Delete(replies[:1])
+			Expect(err).NotTo(HaveOccurred())
+			Expect(queue.DeleteMessagesCallCount()).To(Equal(1))
+			queueURL, messages := queue.DeleteMessagesArgsForCall(0)
+			Expect(queueURL).To(Equal("queue-url"))
+			Expect(messages).To(HaveLen(1))
+			Expect(messages[0].ReceiptHandle).To(Equal("handle-1"))
+		})
+
+		It("should not call the queue when there is nothing to delete", func() {
+			err := listener.Delete([]sms.Reply{{ID: "unknown"}})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(queue.DeleteMessagesCallCount()).To(Equal(0))
+		})
+
+		It("should return an error when receiving fails", func() {
+			queue.ReceiveMessagesReturns(nil, errors.New("error receiving messages from queue-url: access denied"))
+			_, err := listener.Receive(time.Second)
+			Expect(err).To(MatchError("error receiving messages from queue-url: access denied"))
+		})
+	})
+})
@@ -0,0 +1,124 @@
+// This file was generated by counterfeiter
+package inboundfakes
+
+import (
+	"sync"
+	"time"
+
+	"github.com/nickwei84/sms-resource/lib/inbound"
+	"github.com/nickwei84/sms-resource/lib/sms"
+)
+
+type FakeQueue struct {
+	ReceiveMessagesStub        func(queueURL string, wait time.Duration) ([]sms.QueueMessage, error)
+	receiveMessagesMutex       sync.RWMutex
+	receiveMessagesArgsForCall []struct {
+		queueURL string
+		wait     time.Duration
+	}
+	receiveMessagesReturns struct {
+		result1 []sms.QueueMessage
+		result2 error
+	}
+	DeleteMessagesStub        func(queueURL string, messages []sms.QueueMessage) error
+	deleteMessagesMutex       sync.RWMutex
+	deleteMessagesArgsForCall []struct {
+		queueURL string
+		messages []sms.QueueMessage
+	}
+	deleteMessagesReturns struct {
+		result1 error
+	}
+	invocations map[string][][]interface{}
+}
+
+func (fake *FakeQueue) ReceiveMessages(queueURL string, wait time.Duration) ([]sms.QueueMessage, error) {
+	fake.receiveMessagesMutex.Lock()
+	fake.receiveMessagesArgsForCall = append(fake.receiveMessagesArgsForCall, struct {
+		queueURL string
+		wait     time.Duration
+	}{queueURL, wait})
+	fake.guard("ReceiveMessages")
+	fake.invocations["ReceiveMessages"] = append(fake.invocations["ReceiveMessages"], []interface{}{queueURL, wait})
+	fake.receiveMessagesMutex.Unlock()
+	if fake.ReceiveMessagesStub != nil {
+		return fake.ReceiveMessagesStub(queueURL, wait)
+	} else {
+		return fake.receiveMessagesReturns.result1, fake.receiveMessagesReturns.result2
+	}
+}
+
+func (fake *FakeQueue) ReceiveMessagesCallCount() int {
+	fake.receiveMessagesMutex.RLock()
+	defer fake.receiveMessagesMutex.RUnlock()
+	return len(fake.receiveMessagesArgsForCall)
+}
+
+func (fake *FakeQueue) ReceiveMessagesArgsForCall(i int) (string, time.Duration) {
+	fake.receiveMessagesMutex.RLock()
+	defer fake.receiveMessagesMutex.RUnlock()
+	return fake.receiveMessagesArgsForCall[i].queueURL, fake.receiveMessagesArgsForCall[i].wait
+}
+
+func (fake *FakeQueue) ReceiveMessagesReturns(result1 []sms.QueueMessage, result2 error) {
+	fake.ReceiveMessagesStub = nil
+	fake.receiveMessagesReturns = struct {
+		result1 []sms.QueueMessage
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeQueue) DeleteMessages(queueURL string, messages []sms.QueueMessage) error {
+	var messagesCopy []sms.QueueMessage
+	if messages != nil {
+		messagesCopy = make([]sms.QueueMessage, len(messages))
+		copy(messagesCopy, messages)
+	}
+	fake.deleteMessagesMutex.Lock()
+	fake.deleteMessagesArgsForCall = append(fake.deleteMessagesArgsForCall, struct {
+		queueURL string
+		messages []sms.QueueMessage
+	}{queueURL, messagesCopy})
+	fake.guard("DeleteMessages")
+	fake.invocations["DeleteMessages"] = append(fake.invocations["DeleteMessages"], []interface{}{queueURL, messagesCopy})
+	fake.deleteMessagesMutex.Unlock()
+	if fake.DeleteMessagesStub != nil {
+		return fake.DeleteMessagesStub(queueURL, messages)
+	} else {
+		return fake.deleteMessagesReturns.result1
+	}
+}
+
+func (fake *FakeQueue) DeleteMessagesCallCount() int {
+	fake.deleteMessagesMutex.RLock()
+	defer fake.deleteMessagesMutex.RUnlock()
+	return len(fake.deleteMessagesArgsForCall)
+}
+
+func (fake *FakeQueue) DeleteMessagesArgsForCall(i int) (string, []sms.QueueMessage) {
+	fake.deleteMessagesMutex.RLock()
+	defer fake.deleteMessagesMutex.RUnlock()
+	return fake.deleteMessagesArgsForCall[i].queueURL, fake.deleteMessagesArgsForCall[i].messages
+}
+
+func (fake *FakeQueue) DeleteMessagesReturns(result1 error) {
+	fake.DeleteMessagesStub = nil
+	fake.deleteMessagesReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeQueue) Invocations() map[string][][]interface{} {
+	return fake.invocations
+}
+
+func (fake *FakeQueue) guard(key string) {
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+}
+
+var _ inbound.Queue = new(FakeQueue)
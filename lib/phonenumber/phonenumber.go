@@ -0,0 +1,173 @@
+package phonenumber
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	minDigits = 7
+	maxDigits = 15
+
+	nanpCountryCode    = "1"
+	nanpNationalDigits = 10
+
+	maskedVisibleDigits = 4
+)
+
+// InvalidNumbersError lists every number that failed to normalize, so that
+// they can all be fixed at once.
+type InvalidNumbersError struct {
+	Numbers []string
+	Reasons []string
+}
+
+func (e InvalidNumbersError) Error() string {
+	lines := []string{fmt.Sprintf("%d invalid phone number(s):", len(e.Numbers))}
+	for i, number := range e.Numbers {
+		lines = append(lines, fmt.Sprintf("  %q: %s", number, e.Reasons[i]))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Normalize returns number in E.164 form, e.g. "+14151234567". Spaces, dashes,
+// dots and parentheses are ignored, and a leading "00" is read as the
+// international prefix.
+//
+// A number without an international prefix is taken to already include its
+// country code, unless defaultCountryCode is set and the number does not start
+// with it. In that case the number is a national number: a leading trunk '0'
+// is dropped and the default country code is prepended. Without
+// defaultCountryCode, a number shaped like a North American national number,
+// such as "415-123-4567", is rejected rather than read as "+41 51 234 567".
+func Normalize(number string, defaultCountryCode string) (string, error) {
+	stripped := strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '\t', '-', '.', '(', ')':
+			return -1
+		}
+		return r
+	}, number)
+
+	international := false
+	switch {
+	case strings.HasPrefix(stripped, "+"):
+		stripped = stripped[1:]
+		international = true
+	case strings.HasPrefix(stripped, "00"):
+		stripped = stripped[2:]
+		international = true
+	}
+
+	if stripped == "" {
+		return "", fmt.Errorf("is empty")
+	}
+
+	for _, r := range stripped {
+		if r < '0' || r > '9' {
+			return "", fmt.Errorf("contains characters other than digits")
+		}
+	}
+
+	defaultCountryCode = strings.TrimPrefix(defaultCountryCode, "+")
+	if !international && defaultCountryCode == "" && len(stripped) == nanpNationalDigits && stripped[0] >= '2' {
+		return "", fmt.Errorf("looks like a national number without a country code; add it with a leading \"+\" or set a default country code")
+	}
+
+	if !international && defaultCountryCode != "" && !strings.HasPrefix(stripped, defaultCountryCode) {
+		stripped = defaultCountryCode + strings.TrimPrefix(stripped, "0")
+	}
+
+	err := validate(stripped)
+	if err != nil {
+		return "", err
+	}
+
+	return "+" + stripped, nil
+}
+
+// NormalizeAll normalizes every number and drops the ones that turn out to be
+// equivalent to an earlier number. If any number is invalid, all of them are
+// reported in an InvalidNumbersError.
+func NormalizeAll(numbers []string, defaultCountryCode string) ([]string, error) {
+	normalizedNumbers := []string{}
+	seen := map[string]bool{}
+	invalid := InvalidNumbersError{}
+
+	for _, number := range numbers {
+		normalized, err := Normalize(number, defaultCountryCode)
+		if err != nil {
+			invalid.Numbers = append(invalid.Numbers, number)
+			invalid.Reasons = append(invalid.Reasons, err.Error())
+			continue
+		}
+
+		if seen[normalized] {
+			continue
+		}
+		seen[normalized] = true
+		normalizedNumbers = append(normalizedNumbers, normalized)
+	}
+
+	if len(invalid.Numbers) > 0 {
+		return nil, invalid
+	}
+
+	return normalizedNumbers, nil
+}
+
+// Mask hides all but the last maskedVisibleDigits digits of number, e.g.
+// "+14151234567" becomes "+*******4567", so that it can be shown in build
+// output that is visible to everyone with access to the pipeline.
+func Mask(number string) string {
+	digits := 0
+	for _, r := range number {
+		if r >= '0' && r <= '9' {
+			digits++
+		}
+	}
+
+	masked := []rune{}
+	for _, r := range number {
+		if r >= '0' && r <= '9' {
+			digits--
+			if digits >= maskedVisibleDigits {
+				r = '*'
+			}
+		}
+		masked = append(masked, r)
+	}
+	return string(masked)
+}
+
+// MaskAll masks every number in numbers.
+func MaskAll(numbers []string) []string {
+	masked := []string{}
+	for _, number := range numbers {
+		masked = append(masked, Mask(number))
+	}
+	return masked
+}
+
+func validate(digits string) error {
+	if len(digits) < minDigits || len(digits) > maxDigits {
+		return fmt.Errorf("must have between %d and %d digits including the country code", minDigits, maxDigits)
+	}
+
+	countryCode := countryCodeOf(digits)
+	if countryCode == "" {
+		return fmt.Errorf("does not start with a valid country code")
+	}
+
+	if countryCode == nanpCountryCode {
+		national := digits[len(countryCode):]
+		if len(national) != nanpNationalDigits {
+			return fmt.Errorf("must have %d digits after country code +1", nanpNationalDigits)
+		}
+		if national[0] < '2' {
+			return fmt.Errorf("has an invalid North American area code")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,13 @@
+package phonenumber_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestPhoneNumber(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Phone Number Suite")
+}
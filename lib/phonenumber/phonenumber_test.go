@@ -0,0 +1,164 @@
+package phonenumber_test
+
+import (
+	"github.com/nickwei84/sms-resource/lib/phonenumber"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Phone Number", func() {
+	Describe("Normalize", func() {
+		It("should return E.164 numbers unchanged", func() {
+			number, err := phonenumber.Normalize("+14151234567", "")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(number).To(Equal("+14151234567"))
+		})
+
+		It("should strip spaces, dashes, dots and parentheses", func() {
+			number, err := phonenumber.Normalize(" +1 (415) 123-4567 ", "")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(number).To(Equal("+14151234567"))
+
+			number, err = phonenumber.Normalize("+44 7700.900.123", "")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(number).To(Equal("+447700900123"))
+		})
+
+		It("should read a leading 00 as the international prefix", func() {
+			number, err := phonenumber.Normalize("0044 7700 900123", "1")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(number).To(Equal("+447700900123"))
+		})
+
+		It("should treat numbers without a prefix as including the country code", func() {
+			number, err := phonenumber.Normalize("14151234567", "")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(number).To(Equal("+14151234567"))
+		})
+
+		It("should return an error for a North American national number without a default country code", func() {
+			_, err := phonenumber.Normalize("415-123-4567", "")
+			Expect(err).To(MatchError(`looks like a national number without a country code; add it with a leading "+" or set a default country code`))
+		})
+
+		Context("when a default country code is provided", func() {
+			It("should apply it to national numbers", func() {
+				number, err := phonenumber.Normalize("(415) 123-4567", "1")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(number).To(Equal("+14151234567"))
+			})
+
+			It("should drop the trunk prefix of national numbers", func() {
+				number, err := phonenumber.Normalize("07700 900123", "+44")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(number).To(Equal("+447700900123"))
+			})
+
+			It("should not apply it to numbers that already start with it", func() {
+				number, err := phonenumber.Normalize("14151234567", "1")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(number).To(Equal("+14151234567"))
+			})
+
+			It("should not apply it to international numbers", func() {
+				number, err := phonenumber.Normalize("+33 6 12 34 56 78", "1")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(number).To(Equal("+33612345678"))
+			})
+		})
+
+		It("should return an error if the number contains letters", func() {
+			_, err := phonenumber.Normalize("+1415CALLNOW", "")
+			Expect(err).To(MatchError("contains characters other than digits"))
+		})
+
+		It("should return an error if the number is empty", func() {
+			_, err := phonenumber.Normalize(" - ", "")
+			Expect(err).To(MatchError("is empty"))
+		})
+
+		It("should return an error if the number is too short or too long", func() {
+			_, err := phonenumber.Normalize("+44123", "")
+			Expect(err).To(MatchError("must have between 7 and 15 digits including the country code"))
+
+			_, err = phonenumber.Normalize("+4412345678901234", "")
+			Expect(err).To(MatchError("must have between 7 and 15 digits including the country code"))
+		})
+
+		It("should return an error if the country code is not assigned", func() {
+			_, err := phonenumber.Normalize("+8091234567", "")
+			Expect(err).To(MatchError("does not start with a valid country code"))
+		})
+
+		It("should return an error if a North American number has the wrong length", func() {
+			_, err := phonenumber.Normalize("1234567890", "")
+			Expect(err).To(MatchError("must have 10 digits after country code +1"))
+		})
+
+		It("should return an error if a North American number has an invalid area code", func() {
+			_, err := phonenumber.Normalize("+10151234567", "")
+			Expect(err).To(MatchError("has an invalid North American area code"))
+		})
+	})
+
+	Describe("NormalizeAll", func() {
+		It("should normalize every number", func() {
+			numbers, err := phonenumber.NormalizeAll([]string{"4151234567", "+44 7700 900123"}, "1")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(numbers).To(Equal([]string{"+14151234567", "+447700900123"}))
+		})
+
+		It("should remove equivalent numbers", func() {
+			numbers, err := phonenumber.NormalizeAll([]string{"14151234567", "+1 415 123 4567", "(415) 123-4567"}, "1")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(numbers).To(Equal([]string{"+14151234567"}))
+		})
+
+		It("should report all invalid numbers at once", func() {
+			_, err := phonenumber.NormalizeAll([]string{"14151234567", "abc", "+44123"}, "")
+			Expect(err).To(Equal(phonenumber.InvalidNumbersError{
+				Numbers: []string{"abc", "+44123"},
+				Reasons: []string{
+					"contains characters other than digits",
+					"must have between 7 and 15 digits including the country code",
+				},
+			}))
+			Expect(err).To(MatchError("2 invalid phone number(s):\n" +
+				`  "abc": contains characters other than digits` + "\n" +
+				`  "+44123": must have between 7 and 15 digits including the country code`))
+		})
+	})
+
+	Describe("Mask", func() {
+		It("should hide all but the last four digits", func() {
+			Expect(phonenumber.Mask("+14151234567")).To(Equal("+*******4567"))
+			Expect(phonenumber.Mask("+44 7700 900123")).To(Equal("+** **** **0123"))
+		})
+
+		It("should leave numbers of four digits or fewer alone", func() {
+			Expect(phonenumber.Mask("1234")).To(Equal("1234"))
+			Expect(phonenumber.Mask("")).To(Equal(""))
+		})
+	})
+
+	Describe("MaskAll", func() {
+		It("should mask every number", func() {
+			Expect(phonenumber.MaskAll([]string{"+14151234567", "+447700900123"})).To(Equal([]string{"+*******4567", "+********0123"}))
+			Expect(phonenumber.MaskAll(nil)).To(BeEmpty())
+		})
+	})
+
+	Describe("IsCountryCode", func() {
+		It("should accept assigned country codes with or without a plus", func() {
+			Expect(phonenumber.IsCountryCode("1")).To(BeTrue())
+			Expect(phonenumber.IsCountryCode("+44")).To(BeTrue())
+			Expect(phonenumber.IsCountryCode("886")).To(BeTrue())
+		})
+
+		It("should reject unassigned country codes", func() {
+			Expect(phonenumber.IsCountryCode("")).To(BeFalse())
+			Expect(phonenumber.IsCountryCode("80")).To(BeFalse())
+			Expect(phonenumber.IsCountryCode("4412")).To(BeFalse())
+		})
+	})
+})
@@ -0,0 +1,303 @@
+package schedule
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	dateLayout  = "2006-01-02"
+	clockLayout = "15:04"
+
+	defaultRotationDays = 7
+)
+
+// Schedule is an on-call rotation. Layers are listed from lowest to highest
+// priority: at any moment the members on call come from the highest layer that
+// is active, and overrides replace them while they last. Times of day are in
+// the layer's time zone, or the schedule's, or UTC when neither has one.
+type Schedule struct {
+	TimeZone  string     `json:"time_zone"`
+	Layers    []Layer    `json:"layers"`
+	Overrides []Override `json:"overrides"`
+}
+
+// Layer hands off from one member to the next every RotationDays days, at the
+// Handoff time of day, starting with the first member on the Start date. Days
+// and Hours restrict the layer to part of the week or day, leaving lower
+// layers on call the rest of the time.
+type Layer struct {
+	Name         string   `json:"name"`
+	Members      []string `json:"members"`
+	Start        string   `json:"start"`
+	Handoff      string   `json:"handoff"`
+	RotationDays int      `json:"rotation_days"`
+	TimeZone     string   `json:"time_zone"`
+	Days         []string `json:"days"`
+	Hours        Hours    `json:"hours"`
+}
+
+// Hours is a daily window given as "15:04" times. A window that ends before it
+// starts runs past midnight, and belongs to the day it starts on.
+type Hours struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+// Override puts Member on call from Start until End, instead of the layers.
+type Override struct {
+	Member string    `json:"member"`
+	Start  time.Time `json:"start"`
+	End    time.Time `json:"end"`
+}
+
+var weekdays = map[string]time.Weekday{
+	"sunday":    time.Sunday,
+	"monday":    time.Monday,
+	"tuesday":   time.Tuesday,
+	"wednesday": time.Wednesday,
+	"thursday":  time.Thursday,
+	"friday":    time.Friday,
+	"saturday":  time.Saturday,
+}
+
+// Check validates the schedule. OnCall expects a schedule that passed it.
+func (s Schedule) Check() error {
+	if len(s.Layers) == 0 && len(s.Overrides) == 0 {
+		return fmt.Errorf("layers and overrides are both empty or missing")
+	}
+
+	_, err := time.LoadLocation(s.TimeZone)
+	if err != nil {
+		return fmt.Errorf("time_zone is not a valid IANA time zone: %v", err)
+	}
+
+	for i, layer := range s.Layers {
+		err = layer.check()
+		if err != nil {
+			return fmt.Errorf("layers[%d].%v", i, err)
+		}
+	}
+
+	for i, override := range s.Overrides {
+		if override.Member == "" {
+			return fmt.Errorf("overrides[%d].member is either empty or missing", i)
+		}
+
+		if override.Start.IsZero() || override.End.IsZero() {
+			return fmt.Errorf("overrides[%d] must have a start and end like \"2026-01-05T09:00:00Z\"", i)
+		}
+
+		if !override.End.After(override.Start) {
+			return fmt.Errorf("overrides[%d].end must be after its start", i)
+		}
+	}
+
+	return nil
+}
+
+func (l Layer) check() error {
+	if len(l.Members) == 0 {
+		return fmt.Errorf("members is either empty or missing")
+	}
+
+	for i, member := range l.Members {
+		if member == "" {
+			return fmt.Errorf("members[%d] is empty", i)
+		}
+	}
+
+	_, err := time.Parse(dateLayout, l.Start)
+	if err != nil {
+		return fmt.Errorf("start must be a date like \"2026-01-05\"")
+	}
+
+	if l.Handoff != "" {
+		_, err = time.Parse(clockLayout, l.Handoff)
+		if err != nil {
+			return fmt.Errorf("handoff must be a time like \"09:00\"")
+		}
+	}
+
+	if l.RotationDays < 0 {
+		return fmt.Errorf("rotation_days cannot be negative")
+	}
+
+	_, err = time.LoadLocation(l.TimeZone)
+	if err != nil {
+		return fmt.Errorf("time_zone is not a valid IANA time zone: %v", err)
+	}
+
+	for i, day := range l.Days {
+		_, ok := weekdays[strings.ToLower(day)]
+		if !ok {
+			return fmt.Errorf("days[%d] must be a day of the week like \"monday\"", i)
+		}
+	}
+
+	if l.Hours.Start == "" && l.Hours.End == "" {
+		return nil
+	}
+
+	start, startErr := time.Parse(clockLayout, l.Hours.Start)
+	end, endErr := time.Parse(clockLayout, l.Hours.End)
+	if startErr != nil || endErr != nil {
+		return fmt.Errorf("hours must have a start and end like \"09:00\"")
+	}
+
+	if start.Equal(end) {
+		return fmt.Errorf("hours cannot start and end at the same time")
+	}
+
+	return nil
+}
+
+// Members returns every member of the schedule's layers and overrides, in the
+// order they are listed, without duplicates.
+func (s Schedule) Members() []string {
+	members := []string{}
+	for _, layer := range s.Layers {
+		members = appendNew(members, layer.Members...)
+	}
+	for _, override := range s.Overrides {
+		members = appendNew(members, override.Member)
+	}
+	return members
+}
+
+// OnCall returns the members on call at the given time, which is empty when no
+// layer is active and no override applies. Overlapping overrides put every one
+// of their members on call.
+func (s Schedule) OnCall(at time.Time) []string {
+	overriding := []string{}
+	for _, override := range s.Overrides {
+		if !at.Before(override.Start) && at.Before(override.End) {
+			overriding = appendNew(overriding, override.Member)
+		}
+	}
+	if len(overriding) > 0 {
+		return overriding
+	}
+
+	for i := len(s.Layers) - 1; i >= 0; i-- {
+		member, ok := s.Layers[i].onCall(at, s.TimeZone)
+		if ok {
+			return []string{member}
+		}
+	}
+
+	return []string{}
+}
+
+// onCall returns the layer's member on call at the given time, if the layer
+// has started and is active then.
+func (l Layer) onCall(at time.Time, defaultTimeZone string) (string, bool) {
+	timeZone := l.TimeZone
+	if timeZone == "" {
+		timeZone = defaultTimeZone
+	}
+
+	location, err := time.LoadLocation(timeZone)
+	if err != nil {
+		return "", false
+	}
+
+	local := at.In(location)
+	if !l.isActive(local) {
+		return "", false
+	}
+
+	start, _ := time.Parse(dateLayout, l.Start)
+	handoff := minuteOfDay(l.Handoff)
+
+	// Handoffs happen at the same time of day in the layer's time zone, so
+	// shifts are counted in calendar days rather than 24 hour periods, which
+	// change length with daylight saving time.
+	days := dayNumber(local) - dayNumber(start)
+	if local.Hour()*60+local.Minute() < handoff {
+		days--
+	}
+	if days < 0 {
+		return "", false
+	}
+
+	rotationDays := l.RotationDays
+	if rotationDays == 0 {
+		rotationDays = defaultRotationDays
+	}
+
+	shift := days / rotationDays
+	return l.Members[shift%len(l.Members)], true
+}
+
+// isActive reports whether the layer's days and hours include the given local
+// time.
+func (l Layer) isActive(local time.Time) bool {
+	day := local.Weekday()
+
+	if l.Hours.Start != "" {
+		minute := local.Hour()*60 + local.Minute()
+		start := minuteOfDay(l.Hours.Start)
+		end := minuteOfDay(l.Hours.End)
+
+		if start < end {
+			if minute < start || minute >= end {
+				return false
+			}
+		} else {
+			if minute < start && minute >= end {
+				return false
+			}
+
+			// The early hours of an overnight window belong to the day
+			// before, when the window started.
+			if minute < end {
+				day = (day + 6) % 7
+			}
+		}
+	}
+
+	if len(l.Days) == 0 {
+		return true
+	}
+
+	for _, name := range l.Days {
+		if weekdays[strings.ToLower(name)] == day {
+			return true
+		}
+	}
+	return false
+}
+
+func minuteOfDay(clock string) int {
+	if clock == "" {
+		return 0
+	}
+
+	t, _ := time.Parse(clockLayout, clock)
+	return t.Hour()*60 + t.Minute()
+}
+
+// dayNumber counts the calendar days from the Unix epoch to the date of t, in
+// t's own location.
+func dayNumber(t time.Time) int {
+	date := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	return int(date.Unix() / (24 * 60 * 60))
+}
+
+func appendNew(list []string, values ...string) []string {
+	for _, value := range values {
+		found := false
+		for _, existing := range list {
+			if existing == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			list = append(list, value)
+		}
+	}
+	return list
+}
@@ -0,0 +1,222 @@
+package schedule_test
+
+import (
+	"time"
+
+	"github.com/nickwei84/sms-resource/lib/schedule"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func at(value string) time.Time {
+	t, err := time.Parse(time.RFC3339, value)
+	Expect(err).NotTo(HaveOccurred())
+	return t
+}
+
+var _ = Describe("Schedule", func() {
+	var s schedule.Schedule
+
+	BeforeEach(func() {
+		s = schedule.Schedule{
+			Layers: []schedule.Layer{{
+				Name:    "primary",
+				Members: []string{"+14151234567", "+16501234567", "+12125551234"},
+				Start:   "2026-01-05",
+				Handoff: "09:00",
+			}},
+		}
+	})
+
+	Describe("OnCall", func() {
+		It("should hand off to the next member every week", func() {
+			Expect(s.OnCall(at("2026-01-05T09:00:00Z"))).To(Equal([]string{"+14151234567"}))
+			Expect(s.OnCall(at("2026-01-12T08:59:00Z"))).To(Equal([]string{"+14151234567"}))
+			Expect(s.OnCall(at("2026-01-12T09:00:00Z"))).To(Equal([]string{"+16501234567"}))
+			Expect(s.OnCall(at("2026-01-19T09:00:00Z"))).To(Equal([]string{"+12125551234"}))
+			Expect(s.OnCall(at("2026-01-26T09:00:00Z"))).To(Equal([]string{"+14151234567"}))
+		})
+
+		It("should have no one on call before the rotation starts", func() {
+			Expect(s.OnCall(at("2026-01-05T08:59:00Z"))).To(BeEmpty())
+		})
+
+		It("should hand off every rotation_days days", func() {
+			s.Layers[0].RotationDays = 1
+			Expect(s.OnCall(at("2026-01-06T08:00:00Z"))).To(Equal([]string{"+14151234567"}))
+			Expect(s.OnCall(at("2026-01-06T09:00:00Z"))).To(Equal([]string{"+16501234567"}))
+			Expect(s.OnCall(at("2026-01-08T09:00:00Z"))).To(Equal([]string{"+14151234567"}))
+		})
+
+		Context("when the layer has a time zone", func() {
+			BeforeEach(func() {
+				s.Layers[0].TimeZone = "America/New_York"
+				s.Layers[0].Start = "2026-03-07"
+				s.Layers[0].RotationDays = 1
+			})
+
+			It("should hand off at the same local time across daylight saving time", func() {
+				Expect(s.OnCall(at("2026-03-07T14:00:00Z"))).To(Equal([]string{"+14151234567"}))
+				Expect(s.OnCall(at("2026-03-08T13:30:00Z"))).To(Equal([]string{"+16501234567"}))
+				Expect(s.OnCall(at("2026-03-09T12:30:00Z"))).To(Equal([]string{"+16501234567"}))
+				Expect(s.OnCall(at("2026-03-09T13:00:00Z"))).To(Equal([]string{"+12125551234"}))
+			})
+		})
+
+		Context("when the schedule has a time zone", func() {
+			BeforeEach(func() {
+				s.TimeZone = "Asia/Tokyo"
+			})
+
+			It("should be used by layers without one", func() {
+				Expect(s.OnCall(at("2026-01-12T00:00:00Z"))).To(Equal([]string{"+16501234567"}))
+			})
+		})
+
+		Context("when a higher layer is restricted to some days", func() {
+			BeforeEach(func() {
+				s.Layers = append(s.Layers, schedule.Layer{
+					Name:    "weekend",
+					Members: []string{"+447700900123"},
+					Start:   "2026-01-03",
+					Days:    []string{"Saturday", "sunday"},
+				})
+			})
+
+			It("should be on call on those days", func() {
+				Expect(s.OnCall(at("2026-01-10T12:00:00Z"))).To(Equal([]string{"+447700900123"}))
+				Expect(s.OnCall(at("2026-01-11T23:59:00Z"))).To(Equal([]string{"+447700900123"}))
+			})
+
+			It("should leave the lower layer on call on other days", func() {
+				Expect(s.OnCall(at("2026-01-09T12:00:00Z"))).To(Equal([]string{"+14151234567"}))
+				Expect(s.OnCall(at("2026-01-12T00:00:00Z"))).To(Equal([]string{"+14151234567"}))
+			})
+		})
+
+		Context("when a higher layer is restricted to overnight hours", func() {
+			BeforeEach(func() {
+				s.Layers = append(s.Layers, schedule.Layer{
+					Name:    "friday night",
+					Members: []string{"+447700900123"},
+					Start:   "2026-01-02",
+					Days:    []string{"friday"},
+					Hours:   schedule.Hours{Start: "18:00", End: "08:00"},
+				})
+			})
+
+			It("should be on call until the window ends the next morning", func() {
+				Expect(s.OnCall(at("2026-01-09T18:00:00Z"))).To(Equal([]string{"+447700900123"}))
+				Expect(s.OnCall(at("2026-01-10T07:59:00Z"))).To(Equal([]string{"+447700900123"}))
+			})
+
+			It("should leave the lower layer on call outside the window", func() {
+				Expect(s.OnCall(at("2026-01-09T17:59:00Z"))).To(Equal([]string{"+14151234567"}))
+				Expect(s.OnCall(at("2026-01-10T08:00:00Z"))).To(Equal([]string{"+14151234567"}))
+				Expect(s.OnCall(at("2026-01-10T20:00:00Z"))).To(Equal([]string{"+14151234567"}))
+				Expect(s.OnCall(at("2026-01-09T03:00:00Z"))).To(Equal([]string{"+14151234567"}))
+			})
+		})
+
+		Context("when there are overrides", func() {
+			BeforeEach(func() {
+				s.Overrides = []schedule.Override{
+					{Member: "+447700900123", Start: at("2026-01-06T00:00:00Z"), End: at("2026-01-07T00:00:00Z")},
+					{Member: "+12125551234", Start: at("2026-01-06T20:00:00Z"), End: at("2026-01-08T00:00:00Z")},
+				}
+			})
+
+			It("should put the override member on call instead of the layers", func() {
+				Expect(s.OnCall(at("2026-01-06T00:00:00Z"))).To(Equal([]string{"+447700900123"}))
+				Expect(s.OnCall(at("2026-01-07T12:00:00Z"))).To(Equal([]string{"+12125551234"}))
+			})
+
+			It("should put every overlapping override member on call", func() {
+				Expect(s.OnCall(at("2026-01-06T21:00:00Z"))).To(Equal([]string{"+447700900123", "+12125551234"}))
+			})
+
+			It("should return to the layers when the overrides end", func() {
+				Expect(s.OnCall(at("2026-01-05T23:59:00Z"))).To(Equal([]string{"+14151234567"}))
+				Expect(s.OnCall(at("2026-01-08T00:00:00Z"))).To(Equal([]string{"+14151234567"}))
+			})
+		})
+	})
+
+	Describe("Members", func() {
+		It("should return every member once", func() {
+			s.Overrides = []schedule.Override{{Member: "+16501234567"}, {Member: "+447700900123"}}
+			Expect(s.Members()).To(Equal([]string{"+14151234567", "+16501234567", "+12125551234", "+447700900123"}))
+		})
+	})
+
+	Describe("Check", func() {
+		It("should not return an error", func() {
+			Expect(s.Check()).To(Succeed())
+		})
+
+		It("should return an error if there are no layers or overrides", func() {
+			s.Layers = nil
+			Expect(s.Check()).To(MatchError("layers and overrides are both empty or missing"))
+		})
+
+		It("should return an error if the time zone is unknown", func() {
+			s.TimeZone = "Mars/Olympus_Mons"
+			Expect(s.Check()).To(MatchError(HavePrefix("time_zone is not a valid IANA time zone: ")))
+		})
+
+		It("should return an error if a layer has no members", func() {
+			s.Layers[0].Members = nil
+			Expect(s.Check()).To(MatchError("layers[0].members is either empty or missing"))
+		})
+
+		It("should return an error if a layer start is not a date", func() {
+			s.Layers[0].Start = "next monday"
+			Expect(s.Check()).To(MatchError(`layers[0].start must be a date like "2026-01-05"`))
+		})
+
+		It("should return an error if a layer handoff is not a time", func() {
+			s.Layers[0].Handoff = "9am"
+			Expect(s.Check()).To(MatchError(`layers[0].handoff must be a time like "09:00"`))
+		})
+
+		It("should return an error if a layer rotation is negative", func() {
+			s.Layers[0].RotationDays = -1
+			Expect(s.Check()).To(MatchError("layers[0].rotation_days cannot be negative"))
+		})
+
+		It("should return an error if a layer time zone is unknown", func() {
+			s.Layers[0].TimeZone = "Mars/Olympus_Mons"
+			Expect(s.Check()).To(MatchError(HavePrefix("layers[0].time_zone is not a valid IANA time zone: ")))
+		})
+
+		It("should return an error if a layer day is unknown", func() {
+			s.Layers[0].Days = []string{"monday", "funday"}
+			Expect(s.Check()).To(MatchError(`layers[0].days[1] must be a day of the week like "monday"`))
+		})
+
+		It("should return an error if layer hours are incomplete", func() {
+			s.Layers[0].Hours = schedule.Hours{Start: "09:00"}
+			Expect(s.Check()).To(MatchError(`layers[0].hours must have a start and end like "09:00"`))
+		})
+
+		It("should return an error if layer hours are empty", func() {
+			s.Layers[0].Hours = schedule.Hours{Start: "09:00", End: "09:00"}
+			Expect(s.Check()).To(MatchError("layers[0].hours cannot start and end at the same time"))
+		})
+
+		It("should return an error if an override has no member", func() {
+			s.Overrides = []schedule.Override{{Start: at("2026-01-06T00:00:00Z"), End: at("2026-01-07T00:00:00Z")}}
+			Expect(s.Check()).To(MatchError("overrides[0].member is either empty or missing"))
+		})
+
+		It("should return an error if an override has no end", func() {
+			s.Overrides = []schedule.Override{{Member: "+447700900123", Start: at("2026-01-06T00:00:00Z")}}
+			Expect(s.Check()).To(MatchError(`overrides[0] must have a start and end like "2026-01-05T09:00:00Z"`))
+		})
+
+		It("should return an error if an override ends before it starts", func() {
+			s.Overrides = []schedule.Override{{Member: "+447700900123", Start: at("2026-01-07T00:00:00Z"), End: at("2026-01-06T00:00:00Z")}}
+			Expect(s.Check()).To(MatchError("overrides[0].end must be after its start"))
+		})
+	})
+})
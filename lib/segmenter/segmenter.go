@@ -0,0 +1,192 @@
+package segmenter
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf16"
+)
+
+type Encoding string
+
+const (
+	GSM7 Encoding = "GSM-7"
+	UCS2 Encoding = "UCS-2"
+)
+
+// Limits of a single SMS, and of each segment of a concatenated SMS, whose
+// header takes up part of the payload. GSM-7 limits are in septets and UCS-2
+// limits in UTF-16 code units.
+const (
+	gsm7SingleLimit    = 160
+	gsm7MultipartLimit = 153
+	ucs2SingleLimit    = 70
+	ucs2MultipartLimit = 67
+)
+
+const ellipsis = "..."
+
+const gsm7Basic = "@£$¥èéùìòÇ\nØø\rÅåΔ_ΦΓΛΩΠΨΣΘΞÆæßÉ !\"#¤%&'()*+,-./0123456789:;<=>?" +
+	"¡ABCDEFGHIJKLMNOPQRSTUVWXYZÄÖÑÜ§¿abcdefghijklmnopqrstuvwxyzäöñüà"
+
+// Characters in the GSM-7 extension table are sent as an escape followed by
+// the character, so they take up two septets.
+const gsm7Extension = "\f^{}\\[~]|€"
+
+type Info struct {
+	Encoding Encoding
+	Units    int
+	Segments int
+}
+
+// Analyze works out the encoding a carrier will use to send message and how
+// many segments it will be split into.
+func Analyze(message string) Info {
+	encoding := encodingOf(message)
+	units := unitCount(message, encoding)
+
+	segments := 1
+	if units > singleLimit(encoding) {
+		multipartLimit := gsm7MultipartLimit
+		if encoding == UCS2 {
+			multipartLimit = ucs2MultipartLimit
+		}
+		segments = (units + multipartLimit - 1) / multipartLimit
+	}
+
+	return Info{
+		Encoding: encoding,
+		Units:    units,
+		Segments: segments,
+	}
+}
+
+// Truncate shortens message to fit in a single segment, marking the cut with
+// "...". A URL at the end of message, such as a link to the build, is kept
+// intact when there is room for it.
+func Truncate(message string) string {
+	encoding := encodingOf(message)
+	limit := singleLimit(encoding)
+	if unitCount(message, encoding) <= limit {
+		return message
+	}
+
+	body, url := splitTrailingURL(message)
+	if url != "" {
+		suffix := ellipsis + " " + url
+		room := limit - unitCount(suffix, encoding)
+		if room > 0 {
+			return strings.TrimRight(cut(body, room, encoding), " ") + suffix
+		}
+	}
+
+	return cut(message, limit-unitCount(ellipsis, encoding), encoding) + ellipsis
+}
+
+// Split breaks message into parts that each fit in a single segment, numbered
+// "(1/3) ", "(2/3) " and so on. Parts are broken at whitespace where possible.
+func Split(message string) []string {
+	encoding := encodingOf(message)
+	limit := singleLimit(encoding)
+	if unitCount(message, encoding) <= limit {
+		return []string{message}
+	}
+
+	// The part numbers take up room in each part, and their width depends on
+	// how many parts there are. Grow the estimate until the parts fit; fewer
+	// parts than estimated only means narrower numbers, so they still fit.
+	total := 1
+	for {
+		room := limit - unitCount(prefix(total, total), encoding)
+		chunks := chunk(message, room, encoding)
+		if len(chunks) <= total {
+			parts := []string{}
+			for i, c := range chunks {
+				parts = append(parts, prefix(i+1, len(chunks))+c)
+			}
+			return parts
+		}
+		total = len(chunks)
+	}
+}
+
+func prefix(part int, total int) string {
+	return fmt.Sprintf("(%d/%d) ", part, total)
+}
+
+func encodingOf(message string) Encoding {
+	for _, r := range message {
+		if !strings.ContainsRune(gsm7Basic, r) && !strings.ContainsRune(gsm7Extension, r) {
+			return UCS2
+		}
+	}
+	return GSM7
+}
+
+func singleLimit(encoding Encoding) int {
+	if encoding == UCS2 {
+		return ucs2SingleLimit
+	}
+	return gsm7SingleLimit
+}
+
+func unitCount(s string, encoding Encoding) int {
+	units := 0
+	for _, r := range s {
+		units += runeUnits(r, encoding)
+	}
+	return units
+}
+
+func runeUnits(r rune, encoding Encoding) int {
+	if encoding == UCS2 {
+		if utf16.IsSurrogate(r) || r > 0xFFFF {
+			return 2
+		}
+		return 1
+	}
+
+	if strings.ContainsRune(gsm7Extension, r) {
+		return 2
+	}
+	return 1
+}
+
+// cut returns the longest prefix of s that fits in limit units.
+func cut(s string, limit int, encoding Encoding) string {
+	units := 0
+	for i, r := range s {
+		units += runeUnits(r, encoding)
+		if units > limit {
+			return s[:i]
+		}
+	}
+	return s
+}
+
+// chunk breaks s into pieces of at most limit units, preferring to break after
+// whitespace in the second half of a piece.
+func chunk(s string, limit int, encoding Encoding) []string {
+	chunks := []string{}
+	for s != "" {
+		piece := cut(s, limit, encoding)
+		if len(piece) < len(s) {
+			if i := strings.LastIndexAny(piece, " \n"); i >= len(piece)/2 {
+				piece = piece[:i+1]
+			}
+		}
+
+		chunks = append(chunks, strings.TrimRight(piece, " \n"))
+		s = strings.TrimLeft(s[len(piece):], " \n")
+	}
+	return chunks
+}
+
+func splitTrailingURL(message string) (string, string) {
+	trimmed := strings.TrimRight(message, " \n")
+	i := strings.LastIndexAny(trimmed, " \n")
+	last := trimmed[i+1:]
+	if strings.HasPrefix(last, "http://") || strings.HasPrefix(last, "https://") {
+		return trimmed[:i+1], last
+	}
+	return message, ""
+}
@@ -0,0 +1,13 @@
+package segmenter_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestSegmenter(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Segmenter Suite")
+}
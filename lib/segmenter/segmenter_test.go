@@ -0,0 +1,119 @@
+package segmenter_test
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nickwei84/sms-resource/lib/segmenter"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Segmenter", func() {
+	Describe("Analyze", func() {
+		It("should detect GSM-7 messages", func() {
+			Expect(segmenter.Analyze("deploy failed @ 13:00 £5 é")).To(Equal(segmenter.Info{
+				Encoding: segmenter.GSM7,
+				Units:    26,
+				Segments: 1,
+			}))
+		})
+
+		It("should count GSM-7 extension characters as two units", func() {
+			Expect(segmenter.Analyze("{build} €")).To(Equal(segmenter.Info{
+				Encoding: segmenter.GSM7,
+				Units:    12,
+				Segments: 1,
+			}))
+		})
+
+		It("should detect UCS-2 messages", func() {
+			Expect(segmenter.Analyze("build ✅ 🚀")).To(Equal(segmenter.Info{
+				Encoding: segmenter.UCS2,
+				Units:    10,
+				Segments: 1,
+			}))
+		})
+
+		It("should count GSM-7 segments", func() {
+			Expect(segmenter.Analyze(strings.Repeat("a", 160)).Segments).To(Equal(1))
+			Expect(segmenter.Analyze(strings.Repeat("a", 161)).Segments).To(Equal(2))
+			Expect(segmenter.Analyze(strings.Repeat("a", 306)).Segments).To(Equal(2))
+			Expect(segmenter.Analyze(strings.Repeat("a", 307)).Segments).To(Equal(3))
+		})
+
+		It("should count UCS-2 segments", func() {
+			Expect(segmenter.Analyze(strings.Repeat("ä✓", 35)).Segments).To(Equal(1))
+			Expect(segmenter.Analyze(strings.Repeat("ä✓", 35) + "✓").Segments).To(Equal(2))
+			Expect(segmenter.Analyze(strings.Repeat("✓", 135)).Segments).To(Equal(3))
+		})
+	})
+
+	Describe("Truncate", func() {
+		It("should return messages that fit in a single segment unchanged", func() {
+			message := strings.Repeat("a", 160)
+			Expect(segmenter.Truncate(message)).To(Equal(message))
+		})
+
+		It("should cut long messages to a single segment", func() {
+			truncated := segmenter.Truncate(strings.Repeat("a", 200))
+			Expect(truncated).To(Equal(strings.Repeat("a", 157) + "..."))
+		})
+
+		It("should cut long UCS-2 messages to a single segment", func() {
+			truncated := segmenter.Truncate(strings.Repeat("✓", 100))
+			Expect(truncated).To(Equal(strings.Repeat("✓", 67) + "..."))
+			Expect(segmenter.Analyze(truncated).Segments).To(Equal(1))
+		})
+
+		It("should keep a trailing URL intact", func() {
+			url := "https://ci.example.com/teams/main/pipelines/prod/jobs/deploy/builds/42"
+			truncated := segmenter.Truncate(strings.Repeat("failed ", 30) + url)
+			Expect(truncated).To(HaveSuffix("... " + url))
+			Expect(truncated).To(HavePrefix("failed failed"))
+			Expect(segmenter.Analyze(truncated)).To(Equal(segmenter.Info{
+				Encoding: segmenter.GSM7,
+				Units:    160,
+				Segments: 1,
+			}))
+		})
+	})
+
+	Describe("Split", func() {
+		It("should return messages that fit in a single segment unchanged", func() {
+			Expect(segmenter.Split("hello")).To(Equal([]string{"hello"}))
+		})
+
+		It("should split long messages into numbered parts at whitespace", func() {
+			message := strings.TrimSpace(strings.Repeat("spec failed. ", 30))
+			parts := segmenter.Split(message)
+			Expect(parts).To(HaveLen(3))
+
+			words := []string{}
+			for i, part := range parts {
+				Expect(part).To(HavePrefix(fmt.Sprintf("(%d/3) ", i+1)))
+				Expect(segmenter.Analyze(part).Segments).To(Equal(1))
+				words = append(words, strings.TrimPrefix(part, fmt.Sprintf("(%d/3) ", i+1)))
+			}
+			Expect(strings.Join(words, " ")).To(Equal(message))
+		})
+
+		It("should split words that do not fit in a part", func() {
+			parts := segmenter.Split(strings.Repeat("a", 200))
+			Expect(parts).To(Equal([]string{
+				"(1/2) " + strings.Repeat("a", 154),
+				"(2/2) " + strings.Repeat("a", 46),
+			}))
+		})
+
+		It("should widen the part numbers when there are ten or more parts", func() {
+			parts := segmenter.Split(strings.Repeat("a", 154*9+1))
+			Expect(parts).To(HaveLen(10))
+			Expect(parts[0]).To(HavePrefix("(1/10) "))
+			Expect(parts[9]).To(HavePrefix("(10/10) "))
+			for _, part := range parts {
+				Expect(segmenter.Analyze(part).Segments).To(Equal(1))
+			}
+		})
+	})
+})
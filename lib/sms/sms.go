@@ -0,0 +1,94 @@
+package sms
+
+import (
+	"fmt"
+	"time"
+)
+
+// MetadataItem is a name and value reported in the metadata of a Concourse
+// step.
+type MetadataItem struct {
+	Name  string
+	Value string
+}
+
+const ProtocolSMS = "sms"
+
+type Subscription struct {
+	Endpoint            string
+	Protocol            string
+	SubscriptionArn     string
+	PendingConfirmation bool
+}
+
+// MessageAttributes control how SNS delivers an SMS message. Empty fields are
+// left to the account defaults.
+type MessageAttributes struct {
+	SenderID string
+	SMSType  string
+	MaxPrice string
+}
+
+const (
+	DeliveryStatusSuccess = "SUCCESS"
+	DeliveryStatusFailure = "FAILURE"
+)
+
+// DeliveryStatus is the outcome SNS logged for delivering a message to one
+// phone number.
+type DeliveryStatus struct {
+	MessageID        string
+	Destination      string
+	Status           string
+	ProviderResponse string
+	Timestamp        time.Time
+}
+
+// QueueMessage is a message received from an SQS queue, which has to be
+// deleted with its receipt handle once it has been dealt with.
+type QueueMessage struct {
+	Body          string
+	ReceiptHandle string
+	SentTimestamp time.Time
+}
+
+// Reply is an SMS message sent to one of the account's numbers, as delivered
+// by SNS two-way SMS.
+type Reply struct {
+	ID                         string
+	Sender                     string
+	Body                       string
+	Timestamp                  time.Time
+	PreviousPublishedMessageID string
+}
+
+// MessageIDError is returned by a provider that sent a message but could not
+// read its message ID. The message was accepted, so it must be treated as sent
+// rather than sent again.
+type MessageIDError struct {
+	PhoneNumber string
+	Err         error
+}
+
+func (e MessageIDError) Error() string {
+	return fmt.Sprintf("error reading message ID for %s: %v", e.PhoneNumber, e.Err)
+}
+
+func (e MessageIDError) Unwrap() error {
+	return e.Err
+}
+
+// MaybeSentError is returned for a send that failed in a way that the provider
+// may have accepted the message anyway, such as a request that got no
+// response. The message must not be sent again, by any provider.
+type MaybeSentError struct {
+	Err error
+}
+
+func (e MaybeSentError) Error() string {
+	return fmt.Sprintf("%v (the message may have been sent)", e.Err)
+}
+
+func (e MaybeSentError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,182 @@
+package statestore
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// S3 answers GetObject for a missing key with NoSuchKey, or with a bare 404
+// when the caller may not list the bucket.
+const (
+	s3NoSuchKey = "NoSuchKey"
+	s3NotFound  = "NotFound"
+)
+
+// DynamoDB items are looked up by a string partition key named "key", and hold
+// the record in a binary attribute named "value".
+const (
+	dynamoDBKeyAttribute   = "key"
+	dynamoDBValueAttribute = "value"
+)
+
+// FileStore keeps each record in a file of its own in a directory, for use on
+// a single worker and in tests.
+type FileStore struct {
+	dir string
+}
+
+func NewFileStore(dir string) FileStore {
+	return FileStore{dir: dir}
+}
+
+func (s FileStore) Get(key string) ([]byte, bool, error) {
+	value, err := ioutil.ReadFile(s.path(key))
+	if os.IsNotExist(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("error reading state %s: %v", key, err)
+	}
+	return value, true, nil
+}
+
+// Put writes the record to a temporary file first, so that a put running at
+// the same time never reads half of it.
+func (s FileStore) Put(key string, value []byte) error {
+	err := os.MkdirAll(s.dir, 0755)
+	if err != nil {
+		return fmt.Errorf("error writing state %s: %v", key, err)
+	}
+
+	file, err := ioutil.TempFile(s.dir, ".tmp-")
+	if err != nil {
+		return fmt.Errorf("error writing state %s: %v", key, err)
+	}
+	defer os.Remove(file.Name())
+
+	_, err = file.Write(value)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), s.path(key))
+	}
+	if err != nil {
+		return fmt.Errorf("error writing state %s: %v", key, err)
+	}
+
+	return nil
+}
+
+func (s FileStore) path(key string) string {
+	return filepath.Join(s.dir, url.PathEscape(key))
+}
+
+// S3Store keeps each record in an object named after its key, under a prefix.
+type S3Store struct {
+	service *s3.S3
+	bucket  string
+	prefix  string
+}
+
+// NewS3Store addresses the bucket in the path when the session has a custom
+// endpoint, as S3-compatible stores rarely serve bucket subdomains.
+func NewS3Store(sess *session.Session, bucket string, prefix string) S3Store {
+	config := aws.NewConfig()
+	if aws.StringValue(sess.Config.Endpoint) != "" {
+		config = config.WithS3ForcePathStyle(true)
+	}
+
+	return S3Store{
+		service: s3.New(sess, config),
+		bucket:  bucket,
+		prefix:  prefix,
+	}
+}
+
+func (s S3Store) Get(key string) ([]byte, bool, error) {
+	resp, err := s.service.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.prefix + key),
+	})
+	if awsErr, ok := err.(awserr.Error); ok && (awsErr.Code() == s3NoSuchKey || awsErr.Code() == s3NotFound) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("error getting state %s from S3: %w", key, err)
+	}
+	defer resp.Body.Close()
+
+	value, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, false, fmt.Errorf("error getting state %s from S3: %v", key, err)
+	}
+	return value, true, nil
+}
+
+func (s S3Store) Put(key string, value []byte) error {
+	_, err := s.service.PutObject(&s3.PutObjectInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(s.prefix + key),
+		Body:        bytes.NewReader(value),
+		ContentType: aws.String("application/json"),
+	})
+	if err != nil {
+		return fmt.Errorf("error putting state %s to S3: %w", key, err)
+	}
+	return nil
+}
+
+// DynamoDBStore keeps each record in an item of a table.
+type DynamoDBStore struct {
+	service *dynamodb.DynamoDB
+	table   string
+}
+
+func NewDynamoDBStore(sess *session.Session, table string) DynamoDBStore {
+	return DynamoDBStore{
+		service: dynamodb.New(sess),
+		table:   table,
+	}
+}
+
+func (s DynamoDBStore) Get(key string) ([]byte, bool, error) {
+	resp, err := s.service.GetItem(&dynamodb.GetItemInput{
+		TableName:      aws.String(s.table),
+		Key:            map[string]*dynamodb.AttributeValue{dynamoDBKeyAttribute: {S: aws.String(key)}},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, false, fmt.Errorf("error getting state %s from DynamoDB: %w", key, err)
+	}
+
+	value, ok := resp.Item[dynamoDBValueAttribute]
+	if !ok {
+		return nil, false, nil
+	}
+	return value.B, true, nil
+}
+
+func (s DynamoDBStore) Put(key string, value []byte) error {
+	_, err := s.service.PutItem(&dynamodb.PutItemInput{
+		TableName: aws.String(s.table),
+		Item: map[string]*dynamodb.AttributeValue{
+			dynamoDBKeyAttribute:   {S: aws.String(key)},
+			dynamoDBValueAttribute: {B: value},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("error putting state %s to DynamoDB: %w", key, err)
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+package statestore_test
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/nickwei84/sms-resource/lib/awsclient"
+	"github.com/nickwei84/sms-resource/lib/statestore"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+)
+
+var _ = Describe("Statestore", func() {
+	Describe("FileStore", func() {
+		var (
+			dir   string
+			store statestore.FileStore
+		)
+
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "statestore")
+			Expect(err).NotTo(HaveOccurred())
+			store = statestore.NewFileStore(filepath.Join(dir, "state"))
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(dir)
+		})
+
+		It("should report a missing record as not found", func() {
+			_, found, err := store.Get("dedupe/abc")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(found).To(BeFalse())
+		})
+
+		It("should return the record that was put", func() {
+			Expect(store.Put("dedupe/abc", []byte(`{"sent_at":"2026-10-18T09:30:00Z"}`))).To(Succeed())
+			Expect(store.Put("dedupe/abc", []byte(`{"sent_at":"2026-10-18T10:00:00Z"}`))).To(Succeed())
+
+			value, found, err := store.Get("dedupe/abc")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(found).To(BeTrue())
+			Expect(string(value)).To(Equal(`{"sent_at":"2026-10-18T10:00:00Z"}`))
+
+			files, err := ioutil.ReadDir(filepath.Join(dir, "state"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(files).To(HaveLen(1))
+		})
+	})
+
+	Describe("S3Store", func() {
+		var (
+			server *ghttp.Server
+			store  statestore.S3Store
+		)
+
+		BeforeEach(func() {
+			server = ghttp.NewServer()
+			store = statestore.NewS3Store(awsclient.NewSession(awsclient.Config{
+				AccessKeyID:     "key123",
+				SecretAccessKey: "secret123",
+				Endpoint:        server.URL(),
+				DisableSSL:      true,
+			}), "sms-state", "concourse/")
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("should get the object named after the key", func() {
+			server.AppendHandlers(ghttp.CombineHandlers(
+				ghttp.VerifyRequest("GET", "/sms-state/concourse/dedupe/abc"),
+				ghttp.RespondWith(http.StatusOK, `{"sent_at":"2026-10-18T09:30:00Z"}`),
+			))
+
+			value, found, err := store.Get("dedupe/abc")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(found).To(BeTrue())
+			Expect(string(value)).To(Equal(`{"sent_at":"2026-10-18T09:30:00Z"}`))
+		})
+
+		It("should report a missing object as not found", func() {
+			server.AppendHandlers(ghttp.RespondWith(http.StatusNotFound, `<?xml version="1.0" encoding="UTF-8"?><Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message></Error>`))
+
+			_, found, err := store.Get("dedupe/abc")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(found).To(BeFalse())
+		})
+
+		It("should put the object named after the key", func() {
+			server.AppendHandlers(ghttp.CombineHandlers(
+				ghttp.VerifyRequest("PUT", "/sms-state/concourse/dedupe/abc"),
+				ghttp.VerifyBody([]byte(`{"sent_at":"2026-10-18T09:30:00Z"}`)),
+				ghttp.RespondWith(http.StatusOK, ""),
+			))
+
+			Expect(store.Put("dedupe/abc", []byte(`{"sent_at":"2026-10-18T09:30:00Z"}`))).To(Succeed())
+		})
+
+		It("should return other errors", func() {
+			server.AppendHandlers(ghttp.RespondWith(http.StatusForbidden, `<?xml version="1.0" encoding="UTF-8"?><Error><Code>AccessDenied</Code><Message>Access Denied</Message></Error>`))
+
+			_, _, err := store.Get("dedupe/abc")
+			Expect(err).To(MatchError(HavePrefix("error getting state dedupe/abc from S3: AccessDenied: Access Denied")))
+		})
+	})
+
+	Describe("DynamoDBStore", func() {
+		var (
+			server *ghttp.Server
+			store  statestore.DynamoDBStore
+		)
+
+		BeforeEach(func() {
+			server = ghttp.NewServer()
+			store = statestore.NewDynamoDBStore(awsclient.NewSession(awsclient.Config{
+				AccessKeyID:     "key123",
+				SecretAccessKey: "secret123",
+				Endpoint:        server.URL(),
+				DisableSSL:      true,
+			}), "sms-state")
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("should get the value of the item with the key", func() {
+			value := base64.StdEncoding.EncodeToString([]byte(`{"sent_at":"2026-10-18T09:30:00Z"}`))
+			server.AppendHandlers(ghttp.CombineHandlers(
+				ghttp.VerifyRequest("POST", "/"),
+				ghttp.VerifyHeaderKV("X-Amz-Target", "DynamoDB_20120810.GetItem"),
+				verifyJSONBody(`{"TableName":"sms-state","Key":{"key":{"S":"dedupe/abc"}},"ConsistentRead":true}`),
+				ghttp.RespondWith(http.StatusOK, `{"Item":{"key":{"S":"dedupe/abc"},"value":{"B":"`+value+`"}}}`),
+			))
+
+			record, found, err := store.Get("dedupe/abc")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(found).To(BeTrue())
+			Expect(string(record)).To(Equal(`{"sent_at":"2026-10-18T09:30:00Z"}`))
+		})
+
+		It("should report a missing item as not found", func() {
+			server.AppendHandlers(ghttp.RespondWith(http.StatusOK, `{}`))
+
+			_, found, err := store.Get("dedupe/abc")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(found).To(BeFalse())
+		})
+
+		It("should put an item with the key and value", func() {
+			value := base64.StdEncoding.EncodeToString([]byte(`{}`))
+			server.AppendHandlers(ghttp.CombineHandlers(
+				ghttp.VerifyHeaderKV("X-Amz-Target", "DynamoDB_20120810.PutItem"),
+				verifyJSONBody(`{"TableName":"sms-state","Item":{"key":{"S":"dedupe/abc"},"value":{"B":"`+value+`"}}}`),
+				ghttp.RespondWith(http.StatusOK, `{}`),
+			))
+
+			Expect(store.Put("dedupe/abc", []byte(`{}`))).To(Succeed())
+		})
+	})
+})
+
+// verifyJSONBody checks the body of a DynamoDB request, which is JSON with a
+// content type of its own.
+func verifyJSONBody(expected string) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		body, err := ioutil.ReadAll(req.Body)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(body).To(MatchJSON(expected))
+	}
+}
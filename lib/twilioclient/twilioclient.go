@@ -0,0 +1,286 @@
+package twilioclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/nickwei84/sms-resource/lib/sms"
+)
+
+const DefaultBaseURL = "https://api.twilio.com"
+
+const apiVersion = "2010-04-01"
+
+const requestTimeout = 30 * time.Second
+
+const deliveryPollInterval = 5 * time.Second
+
+// Message statuses after which Twilio does not update a message any more. A
+// message to a carrier that does not report delivery stays "sent".
+const (
+	statusDelivered   = "delivered"
+	statusUndelivered = "undelivered"
+	statusFailed      = "failed"
+)
+
+// Twilio formats timestamps in its JSON responses like RFC 1123.
+const timestampLayout = time.RFC1123Z
+
+type Config struct {
+	AccountSID string
+	AuthToken  string
+	From       string
+	BaseURL    string
+}
+
+// Client sends SMS messages through Twilio's REST Messages API. Twilio has no
+// topics, so messages are always sent to each phone number directly.
+type Client struct {
+	config     Config
+	httpClient *http.Client
+}
+
+func NewClient(config Config) Client {
+	if config.BaseURL == "" {
+		config.BaseURL = DefaultBaseURL
+	}
+
+	return Client{
+		config:     config,
+		httpClient: &http.Client{Timeout: requestTimeout},
+	}
+}
+
+// Error is an error response from Twilio. StatusCode is 0 when the request
+// never got a response.
+type Error struct {
+	StatusCode int
+	Code       int
+	Message    string
+}
+
+func (e Error) Error() string {
+	if e.StatusCode == 0 {
+		return fmt.Sprintf("request to Twilio failed: %s", e.Message)
+	}
+	return fmt.Sprintf("Twilio error %d: %s (status %d)", e.Code, e.Message, e.StatusCode)
+}
+
+// Retryable reports whether the request may succeed if it is made again.
+func (e Error) Retryable() bool {
+	return e.StatusCode == 0 || e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// RetryableSend reports whether a message sent with the request was rejected
+// and may succeed if it is sent again. A request that got no response may have
+// been accepted already, so it is not.
+func (e Error) RetryableSend() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+type message struct {
+	SID          string  `json:"sid"`
+	To           string  `json:"to"`
+	Status       string  `json:"status"`
+	ErrorCode    *int    `json:"error_code"`
+	ErrorMessage *string `json:"error_message"`
+	DateUpdated  string  `json:"date_updated"`
+}
+
+func (c Client) CreateTopic(topic string) (string, error) {
+	return "", errTopicsNotSupported()
+}
+
+func (c Client) FindTopic(topic string) (string, error) {
+	return "", errTopicsNotSupported()
+}
+
+func (c Client) GetExistingSubscribers(topicID string) ([]sms.Subscription, error) {
+	return nil, errTopicsNotSupported()
+}
+
+func (c Client) CreateNewSubscriptions(topicID string, newSubscribers []string) ([]sms.Subscription, error) {
+	return nil, errTopicsNotSupported()
+}
+
+func (c Client) RemoveSubscriptions(subscriptions []sms.Subscription) error {
+	return errTopicsNotSupported()
+}
+
+func (c Client) PublishMessage(topicID string, message string, attributes sms.MessageAttributes) (string, error) {
+	return "", errTopicsNotSupported()
+}
+
+func errTopicsNotSupported() error {
+	return fmt.Errorf("topics are not supported by Twilio")
+}
+
+// PublishToPhone sends message from the configured number, or from the
+// alphanumeric sender ID when one is given. The SMS type is not supported by
+// Twilio and is ignored.
+func (c Client) PublishToPhone(phoneNumber string, body string, attributes sms.MessageAttributes) (string, error) {
+	form := url.Values{
+		"To":   {phoneNumber},
+		"From": {c.config.From},
+		"Body": {body},
+	}
+
+	if attributes.SenderID != "" {
+		form.Set("From", attributes.SenderID)
+	}
+
+	if attributes.MaxPrice != "" {
+		form.Set("MaxPrice", attributes.MaxPrice)
+	}
+
+	var sent message
+	err := c.do("POST", c.messagesURL(""), form, &sent)
+	if err != nil {
+		return "", fmt.Errorf("error publishing message to %s: %w", phoneNumber, err)
+	}
+
+	return sent.SID, nil
+}
+
+// IsOptedOut always reports false, as Twilio does not expose whether a number
+// has opted out. Twilio refuses to send to numbers that replied STOP itself,
+// so they show up as failed sends instead.
+func (c Client) IsOptedOut(phoneNumber string) (bool, error) {
+	return false, nil
+}
+
+func (c Client) OptIn(phoneNumber string) error {
+	return fmt.Errorf("error opting in %s: opting in is not supported by Twilio, the recipient has to reply START", phoneNumber)
+}
+
+// WaitForDelivery polls the status of each message until Twilio has either
+// delivered it or given up on it, or timeout has passed. Each message goes to
+// a single phone number, so perMessage is not needed.
+func (c Client) WaitForDelivery(messageIDs []string, perMessage int, since time.Time, timeout time.Duration) ([]sms.DeliveryStatus, error) {
+	deadline := time.Now().Add(timeout)
+	final := map[string]sms.DeliveryStatus{}
+
+	for {
+		for _, messageID := range messageIDs {
+			if _, ok := final[messageID]; ok {
+				continue
+			}
+
+			var polled message
+			err := c.do("GET", c.messagesURL(messageID), nil, &polled)
+			if err != nil {
+				return nil, fmt.Errorf("error getting the status of message %s: %w", messageID, err)
+			}
+
+			status, ok := deliveryStatus(polled)
+			if ok {
+				final[messageID] = status
+			}
+		}
+
+		if len(final) == len(messageIDs) || time.Now().Add(deliveryPollInterval).After(deadline) {
+			break
+		}
+
+		time.Sleep(deliveryPollInterval)
+	}
+
+	statuses := []sms.DeliveryStatus{}
+	for _, messageID := range messageIDs {
+		if status, ok := final[messageID]; ok {
+			statuses = append(statuses, status)
+		}
+	}
+	return statuses, nil
+}
+
+// deliveryStatus returns the outcome of a message, once there is one.
+func deliveryStatus(polled message) (sms.DeliveryStatus, bool) {
+	status := sms.DeliveryStatus{
+		MessageID:        polled.SID,
+		Destination:      polled.To,
+		ProviderResponse: polled.Status,
+	}
+	status.Timestamp, _ = time.Parse(timestampLayout, polled.DateUpdated)
+	status.Timestamp = status.Timestamp.UTC()
+
+	switch polled.Status {
+	case statusDelivered:
+		status.Status = sms.DeliveryStatusSuccess
+	case statusUndelivered, statusFailed:
+		status.Status = sms.DeliveryStatusFailure
+		if polled.ErrorMessage != nil && *polled.ErrorMessage != "" {
+			status.ProviderResponse = *polled.ErrorMessage
+		} else if polled.ErrorCode != nil {
+			status.ProviderResponse = fmt.Sprintf("%s with error %d", polled.Status, *polled.ErrorCode)
+		}
+	default:
+		return sms.DeliveryStatus{}, false
+	}
+
+	return status, true
+}
+
+func (c Client) messagesURL(messageID string) string {
+	messagesURL := fmt.Sprintf("%s/%s/Accounts/%s/Messages", strings.TrimSuffix(c.config.BaseURL, "/"), apiVersion, url.PathEscape(c.config.AccountSID))
+	if messageID != "" {
+		messagesURL += "/" + url.PathEscape(messageID)
+	}
+	return messagesURL + ".json"
+}
+
+func (c Client) do(method string, requestURL string, form url.Values, result interface{}) error {
+	var body *strings.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	} else {
+		body = strings.NewReader("")
+	}
+
+	req, err := http.NewRequest(method, requestURL, body)
+	if err != nil {
+		return err
+	}
+
+	req.SetBasicAuth(c.config.AccountSID, c.config.AuthToken)
+	req.Header.Set("Accept", "application/json")
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return Error{Message: err.Error()}
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return Error{Message: err.Error()}
+	}
+
+	if resp.StatusCode >= 300 {
+		twilioErr := Error{StatusCode: resp.StatusCode, Message: http.StatusText(resp.StatusCode)}
+		var errorBody struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(respBody, &errorBody) == nil && errorBody.Message != "" {
+			twilioErr.Code = errorBody.Code
+			twilioErr.Message = errorBody.Message
+		}
+		return twilioErr
+	}
+
+	err = json.Unmarshal(respBody, result)
+	if err != nil {
+		return fmt.Errorf("error parsing response from Twilio: %v", err)
+	}
+
+	return nil
+}
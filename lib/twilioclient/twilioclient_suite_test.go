@@ -0,0 +1,13 @@
+package twilioclient_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestTwilioclient(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Twilioclient Suite")
+}
@@ -0,0 +1,137 @@
+package twilioclient_test
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/nickwei84/sms-resource/lib/sms"
+	"github.com/nickwei84/sms-resource/lib/twilioclient"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+)
+
+var _ = Describe("Twilioclient", func() {
+	var (
+		server *ghttp.Server
+		client twilioclient.Client
+	)
+
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+		client = twilioclient.NewClient(twilioclient.Config{
+			AccountSID: "AC123",
+			AuthToken:  "token123",
+			From:       "+14155550100",
+			BaseURL:    server.URL(),
+		})
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Describe("PublishToPhone", func() {
+		It("should create a message and return its SID", func() {
+			server.AppendHandlers(ghttp.CombineHandlers(
+				ghttp.VerifyRequest("POST", "/2010-04-01/Accounts/AC123/Messages.json"),
+				ghttp.VerifyBasicAuth("AC123", "token123"),
+				ghttp.VerifyContentType("application/x-www-form-urlencoded"),
+				ghttp.VerifyForm(map[string][]string{
+					"To":   {"+14151234567"},
+					"From": {"+14155550100"},
+					"Body": {"hello"},
+				}),
+				ghttp.RespondWith(http.StatusCreated, `{"sid":"SM123","status":"queued"}`),
+			))
+
+			messageID, err := client.PublishToPhone("+14151234567", "hello", sms.MessageAttributes{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(messageID).To(Equal("SM123"))
+		})
+
+		It("should send from the sender ID and with the maximum price when they are set", func() {
+			server.AppendHandlers(ghttp.CombineHandlers(
+				ghttp.VerifyFormKV("From", "Concourse"),
+				ghttp.VerifyFormKV("MaxPrice", "0.50"),
+				ghttp.RespondWith(http.StatusCreated, `{"sid":"SM123"}`),
+			))
+
+			_, err := client.PublishToPhone("+14151234567", "hello", sms.MessageAttributes{SenderID: "Concourse", MaxPrice: "0.50", SMSType: "Transactional"})
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should return the error from Twilio", func() {
+			server.AppendHandlers(ghttp.RespondWith(http.StatusBadRequest, `{"code":21610,"message":"Attempt to send to unsubscribed recipient","status":400}`))
+
+			_, err := client.PublishToPhone("+14151234567", "hello", sms.MessageAttributes{})
+			Expect(err).To(MatchError("error publishing message to +14151234567: Twilio error 21610: Attempt to send to unsubscribed recipient (status 400)"))
+		})
+
+		It("should only report rate limiting and server errors as retryable", func() {
+			Expect(twilioclient.Error{StatusCode: http.StatusTooManyRequests}.Retryable()).To(BeTrue())
+			Expect(twilioclient.Error{StatusCode: http.StatusServiceUnavailable}.Retryable()).To(BeTrue())
+			Expect(twilioclient.Error{}.Retryable()).To(BeTrue())
+			Expect(twilioclient.Error{StatusCode: http.StatusBadRequest}.Retryable()).To(BeFalse())
+		})
+
+		It("should not report sends that got no response as retryable", func() {
+			Expect(twilioclient.Error{StatusCode: http.StatusTooManyRequests}.RetryableSend()).To(BeTrue())
+			Expect(twilioclient.Error{StatusCode: http.StatusServiceUnavailable}.RetryableSend()).To(BeTrue())
+			Expect(twilioclient.Error{}.RetryableSend()).To(BeFalse())
+		})
+	})
+
+	Describe("WaitForDelivery", func() {
+		It("should return the outcome of each message", func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", "/2010-04-01/Accounts/AC123/Messages/SM1.json"),
+					ghttp.VerifyBasicAuth("AC123", "token123"),
+					ghttp.RespondWith(http.StatusOK, `{"sid":"SM1","to":"+14151234567","status":"delivered","date_updated":"Sun, 18 Oct 2026 09:30:01 +0000"}`),
+				),
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", "/2010-04-01/Accounts/AC123/Messages/SM2.json"),
+					ghttp.RespondWith(http.StatusOK, `{"sid":"SM2","to":"+16505550123","status":"undelivered","error_code":30003,"error_message":"Unreachable destination handset"}`),
+				),
+			)
+
+			statuses, err := client.WaitForDelivery([]string{"SM1", "SM2"}, 1, time.Now(), time.Minute)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(statuses).To(Equal([]sms.DeliveryStatus{
+				{MessageID: "SM1", Destination: "+14151234567", Status: "SUCCESS", ProviderResponse: "delivered", Timestamp: time.Date(2026, 10, 18, 9, 30, 1, 0, time.UTC)},
+				{MessageID: "SM2", Destination: "+16505550123", Status: "FAILURE", ProviderResponse: "Unreachable destination handset", Timestamp: time.Time{}},
+			}))
+		})
+
+		It("should leave out messages that have no outcome in time", func() {
+			server.AppendHandlers(ghttp.RespondWith(http.StatusOK, `{"sid":"SM1","to":"+14151234567","status":"sent"}`))
+
+			statuses, err := client.WaitForDelivery([]string{"SM1"}, 1, time.Now(), time.Second)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(statuses).To(BeEmpty())
+			Expect(server.ReceivedRequests()).To(HaveLen(1))
+		})
+
+		It("should return an error when the status cannot be read", func() {
+			server.AppendHandlers(ghttp.RespondWith(http.StatusNotFound, `{"code":20404,"message":"The requested resource was not found","status":404}`))
+
+			_, err := client.WaitForDelivery([]string{"SM1"}, 1, time.Now(), time.Minute)
+			Expect(err).To(MatchError("error getting the status of message SM1: Twilio error 20404: The requested resource was not found (status 404)"))
+		})
+	})
+
+	Describe("topics", func() {
+		It("should not be supported", func() {
+			_, err := client.CreateTopic("concourse")
+			Expect(err).To(MatchError("topics are not supported by Twilio"))
+		})
+	})
+
+	Describe("OptIn", func() {
+		It("should not be supported", func() {
+			err := client.OptIn("+14151234567")
+			Expect(err).To(MatchError("error opting in +14151234567: opting in is not supported by Twilio, the recipient has to reply START"))
+		})
+	})
+})
@@ -0,0 +1,322 @@
+package webhookclient
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/nickwei84/sms-resource/lib/sms"
+)
+
+const DefaultMethod = "POST"
+
+const DefaultHMACHeader = "X-Signature-256"
+
+// DefaultBody sends the phone number and message as a JSON object.
+const DefaultBody = `{"to": {{json .Recipient}}, "message": {{json .Message}}}`
+
+const requestTimeout = 30 * time.Second
+
+// Config describes the request made to the gateway for each phone number.
+// Exactly one of the authentication methods may be set.
+type Config struct {
+	URL           string
+	Method        string
+	Headers       map[string]string
+	BasicUsername string
+	BasicPassword string
+	BearerToken   string
+	HMACSecret    string
+	HMACHeader    string
+	Body          string
+	SuccessCodes  []int
+	MessageIDPath string
+}
+
+// Client sends SMS messages through an HTTP gateway, making one request per
+// phone number. A gateway has no topics, so messages are always sent to each
+// phone number directly.
+type Client struct {
+	config     Config
+	body       *template.Template
+	httpClient *http.Client
+}
+
+// NewClient returns an error when the body template cannot be parsed.
+func NewClient(config Config) (Client, error) {
+	if config.Method == "" {
+		config.Method = DefaultMethod
+	}
+
+	if config.Body == "" {
+		config.Body = DefaultBody
+	}
+
+	if config.HMACHeader == "" {
+		config.HMACHeader = DefaultHMACHeader
+	}
+
+	body, err := template.New("body").Funcs(templateFuncs).Option("missingkey=error").Parse(config.Body)
+	if err != nil {
+		return Client{}, err
+	}
+
+	return Client{
+		config:     config,
+		body:       body,
+		httpClient: &http.Client{Timeout: requestTimeout},
+	}, nil
+}
+
+// BodyData is available to the body template.
+type BodyData struct {
+	Recipient  string
+	Recipients []string
+	Message    string
+	Attributes sms.MessageAttributes
+}
+
+var templateFuncs = template.FuncMap{
+	"json": func(value interface{}) (string, error) {
+		encoded, err := json.Marshal(value)
+		return string(encoded), err
+	},
+}
+
+// Error is a response from the gateway without a success status code.
+// StatusCode is 0 when the request never got a response.
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e Error) Error() string {
+	if e.StatusCode == 0 {
+		return fmt.Sprintf("request to webhook failed: %s", e.Message)
+	}
+	return fmt.Sprintf("webhook responded with status %d: %s", e.StatusCode, e.Message)
+}
+
+// Retryable reports whether the request may succeed if it is made again.
+func (e Error) Retryable() bool {
+	return e.StatusCode == 0 || e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// RetryableSend reports whether a message sent with the request was rejected
+// and may succeed if it is sent again. A request that got no response may have
+// been accepted already, so it is not.
+func (e Error) RetryableSend() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+func (c Client) CreateTopic(topic string) (string, error) {
+	return "", errTopicsNotSupported()
+}
+
+func (c Client) FindTopic(topic string) (string, error) {
+	return "", errTopicsNotSupported()
+}
+
+func (c Client) GetExistingSubscribers(topicID string) ([]sms.Subscription, error) {
+	return nil, errTopicsNotSupported()
+}
+
+func (c Client) CreateNewSubscriptions(topicID string, newSubscribers []string) ([]sms.Subscription, error) {
+	return nil, errTopicsNotSupported()
+}
+
+func (c Client) RemoveSubscriptions(subscriptions []sms.Subscription) error {
+	return errTopicsNotSupported()
+}
+
+func (c Client) PublishMessage(topicID string, message string, attributes sms.MessageAttributes) (string, error) {
+	return "", errTopicsNotSupported()
+}
+
+// IsOptedOut always reports false, as a gateway has no way to tell.
+func (c Client) IsOptedOut(phoneNumber string) (bool, error) {
+	return false, nil
+}
+
+func (c Client) OptIn(phoneNumber string) error {
+	return fmt.Errorf("error opting in %s: opting in is not supported by the webhook provider", phoneNumber)
+}
+
+func (c Client) WaitForDelivery(messageIDs []string, perMessage int, since time.Time, timeout time.Duration) ([]sms.DeliveryStatus, error) {
+	return nil, fmt.Errorf("waiting for delivery is not supported by the webhook provider")
+}
+
+func errTopicsNotSupported() error {
+	return fmt.Errorf("topics are not supported by the webhook provider")
+}
+
+// PublishToPhone renders the body template for phoneNumber and sends it to the
+// gateway. The message ID is taken from the JSON response when a path to it is
+// configured. When the gateway accepted the message but the ID cannot be read,
+// it returns an empty ID with an sms.MessageIDError, so that the message is not
+// sent again.
+func (c Client) PublishToPhone(phoneNumber string, message string, attributes sms.MessageAttributes) (string, error) {
+	body, err := c.renderBody(BodyData{
+		Recipient:  phoneNumber,
+		Recipients: []string{phoneNumber},
+		Message:    message,
+		Attributes: attributes,
+	})
+	if err != nil {
+		return "", fmt.Errorf("error rendering webhook body for %s: %v", phoneNumber, err)
+	}
+
+	respBody, err := c.send(body)
+	if err != nil {
+		return "", fmt.Errorf("error publishing message to %s: %w", phoneNumber, err)
+	}
+
+	if c.config.MessageIDPath == "" {
+		return "", nil
+	}
+
+	messageID, err := Extract(respBody, c.config.MessageIDPath)
+	if err != nil {
+		return "", sms.MessageIDError{PhoneNumber: phoneNumber, Err: fmt.Errorf("webhook response: %v", err)}
+	}
+
+	return messageID, nil
+}
+
+func (c Client) renderBody(data BodyData) ([]byte, error) {
+	var body bytes.Buffer
+	err := c.body.Execute(&body, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return body.Bytes(), nil
+}
+
+func (c Client) send(body []byte) ([]byte, error) {
+	req, err := http.NewRequest(c.config.Method, c.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range c.config.Headers {
+		req.Header.Set(name, value)
+	}
+
+	switch {
+	case c.config.BasicUsername != "":
+		req.SetBasicAuth(c.config.BasicUsername, c.config.BasicPassword)
+	case c.config.BearerToken != "":
+		req.Header.Set("Authorization", "Bearer "+c.config.BearerToken)
+	case c.config.HMACSecret != "":
+		req.Header.Set(c.config.HMACHeader, Sign(c.config.HMACSecret, body))
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, Error{Message: err.Error()}
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, Error{Message: err.Error()}
+	}
+
+	if !c.isSuccess(resp.StatusCode) {
+		message := strings.TrimSpace(string(respBody))
+		if message == "" {
+			message = http.StatusText(resp.StatusCode)
+		}
+		return nil, Error{StatusCode: resp.StatusCode, Message: message}
+	}
+
+	return respBody, nil
+}
+
+// isSuccess accepts any 2xx status code unless success codes are configured.
+func (c Client) isSuccess(statusCode int) bool {
+	if len(c.config.SuccessCodes) == 0 {
+		return statusCode >= 200 && statusCode < 300
+	}
+
+	for _, successCode := range c.config.SuccessCodes {
+		if statusCode == successCode {
+			return true
+		}
+	}
+	return false
+}
+
+// Sign returns the HMAC-SHA256 signature of body with secret, in the form
+// "sha256=<hex digest>".
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+var pathSegmentPattern = regexp.MustCompile(`^([^\[\]]*)((?:\[\d+\])*)$`)
+var pathIndexPattern = regexp.MustCompile(`\[(\d+)\]`)
+
+// Extract returns the string or number found at path in a JSON document. The
+// path is made of object keys separated by dots, each optionally followed by
+// array indexes, e.g. "data.messages[0].id". A leading "$." is ignored.
+func Extract(document []byte, path string) (string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(document))
+	decoder.UseNumber()
+
+	var value interface{}
+	err := decoder.Decode(&value)
+	if err != nil {
+		return "", fmt.Errorf("response is not JSON: %v", err)
+	}
+
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	for _, segment := range strings.Split(path, ".") {
+		match := pathSegmentPattern.FindStringSubmatch(segment)
+		if match == nil {
+			return "", fmt.Errorf("invalid path segment %q", segment)
+		}
+
+		if match[1] != "" {
+			object, ok := value.(map[string]interface{})
+			if !ok {
+				return "", fmt.Errorf("%q is not an object", match[1])
+			}
+			value, ok = object[match[1]]
+			if !ok {
+				return "", fmt.Errorf("%q not found", match[1])
+			}
+		}
+
+		for _, index := range pathIndexPattern.FindAllStringSubmatch(match[2], -1) {
+			i, _ := strconv.Atoi(index[1])
+			array, ok := value.([]interface{})
+			if !ok || i >= len(array) {
+				return "", fmt.Errorf("index %d not found in %q", i, segment)
+			}
+			value = array[i]
+		}
+	}
+
+	switch value := value.(type) {
+	case string:
+		return value, nil
+	case json.Number:
+		return value.String(), nil
+	default:
+		return "", fmt.Errorf("value at %q is not a string or number", path)
+	}
+}
@@ -0,0 +1,13 @@
+package webhookclient_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestWebhookclient(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Webhookclient Suite")
+}
@@ -0,0 +1,178 @@
+package webhookclient_test
+
+import (
+	"net/http"
+
+	"github.com/nickwei84/sms-resource/lib/sms"
+	"github.com/nickwei84/sms-resource/lib/webhookclient"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+)
+
+var _ = Describe("Webhookclient", func() {
+	var (
+		server *ghttp.Server
+		config webhookclient.Config
+	)
+
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+		config = webhookclient.Config{URL: server.URL() + "/send"}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	newClient := func() webhookclient.Client {
+		client, err := webhookclient.NewClient(config)
+		Expect(err).NotTo(HaveOccurred())
+		return client
+	}
+
+	Describe("NewClient", func() {
+		It("should return an error when the body is not a valid template", func() {
+			config.Body = `{"to": {{.Recipient}`
+			_, err := webhookclient.NewClient(config)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("PublishToPhone", func() {
+		It("should post the phone number and message as JSON by default", func() {
+			server.AppendHandlers(ghttp.CombineHandlers(
+				ghttp.VerifyRequest("POST", "/send"),
+				ghttp.VerifyContentType("application/json"),
+				ghttp.VerifyJSON(`{"to": "+14151234567", "message": "say \"hello\""}`),
+				ghttp.RespondWith(http.StatusAccepted, ""),
+			))
+
+			messageID, err := newClient().PublishToPhone("+14151234567", `say "hello"`, sms.MessageAttributes{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(messageID).To(BeEmpty())
+		})
+
+		It("should render the configured body with the method and headers", func() {
+			config.Method = "PUT"
+			config.Headers = map[string]string{"X-Api-Version": "2"}
+			config.Body = `{"recipients": {{json .Recipients}}, "text": {{json .Message}}, "from": {{json .Attributes.SenderID}}}`
+			server.AppendHandlers(ghttp.CombineHandlers(
+				ghttp.VerifyRequest("PUT", "/send"),
+				ghttp.VerifyHeaderKV("X-Api-Version", "2"),
+				ghttp.VerifyJSON(`{"recipients": ["+14151234567"], "text": "hello", "from": "Concourse"}`),
+				ghttp.RespondWith(http.StatusOK, ""),
+			))
+
+			_, err := newClient().PublishToPhone("+14151234567", "hello", sms.MessageAttributes{SenderID: "Concourse"})
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should authenticate with basic auth", func() {
+			config.BasicUsername = "concourse"
+			config.BasicPassword = "password123"
+			server.AppendHandlers(ghttp.CombineHandlers(
+				ghttp.VerifyBasicAuth("concourse", "password123"),
+				ghttp.RespondWith(http.StatusOK, ""),
+			))
+
+			_, err := newClient().PublishToPhone("+14151234567", "hello", sms.MessageAttributes{})
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should authenticate with a bearer token", func() {
+			config.BearerToken = "token123"
+			server.AppendHandlers(ghttp.CombineHandlers(
+				ghttp.VerifyHeaderKV("Authorization", "Bearer token123"),
+				ghttp.RespondWith(http.StatusOK, ""),
+			))
+
+			_, err := newClient().PublishToPhone("+14151234567", "hello", sms.MessageAttributes{})
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should sign the body with the HMAC secret", func() {
+			config.HMACSecret = "secret123"
+			body := `{"to": "+14151234567", "message": "hello"}`
+			server.AppendHandlers(ghttp.CombineHandlers(
+				ghttp.VerifyHeaderKV("X-Signature-256", webhookclient.Sign("secret123", []byte(body))),
+				ghttp.VerifyBody([]byte(body)),
+				ghttp.RespondWith(http.StatusOK, ""),
+			))
+
+			_, err := newClient().PublishToPhone("+14151234567", "hello", sms.MessageAttributes{})
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should read the message ID from the response", func() {
+			config.MessageIDPath = "$.data.messages[0].id"
+			server.AppendHandlers(ghttp.RespondWith(http.StatusCreated, `{"data": {"messages": [{"id": "msg-123"}]}}`))
+
+			messageID, err := newClient().PublishToPhone("+14151234567", "hello", sms.MessageAttributes{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(messageID).To(Equal("msg-123"))
+		})
+
+		It("should return an empty ID and a message ID error when the message ID is not in the response", func() {
+			config.MessageIDPath = "id"
+			server.AppendHandlers(ghttp.RespondWith(http.StatusOK, `{"status": "queued"}`))
+
+			messageID, err := newClient().PublishToPhone("+14151234567", "hello", sms.MessageAttributes{})
+			Expect(messageID).To(BeEmpty())
+			Expect(err).To(BeAssignableToTypeOf(sms.MessageIDError{}))
+			Expect(err).To(MatchError(`error reading message ID for +14151234567: webhook response: "id" not found`))
+		})
+
+		It("should only accept the configured success codes", func() {
+			config.SuccessCodes = []int{http.StatusCreated}
+			server.AppendHandlers(ghttp.RespondWith(http.StatusOK, ""))
+
+			_, err := newClient().PublishToPhone("+14151234567", "hello", sms.MessageAttributes{})
+			Expect(err).To(MatchError("error publishing message to +14151234567: webhook responded with status 200: OK"))
+		})
+
+		It("should return the response body of a failed request", func() {
+			server.AppendHandlers(ghttp.RespondWith(http.StatusBadRequest, "invalid recipient\n"))
+
+			_, err := newClient().PublishToPhone("+14151234567", "hello", sms.MessageAttributes{})
+			Expect(err).To(MatchError("error publishing message to +14151234567: webhook responded with status 400: invalid recipient"))
+		})
+
+		It("should only report rate limiting, server and connection errors as retryable", func() {
+			Expect(webhookclient.Error{StatusCode: http.StatusTooManyRequests}.Retryable()).To(BeTrue())
+			Expect(webhookclient.Error{StatusCode: http.StatusBadGateway}.Retryable()).To(BeTrue())
+			Expect(webhookclient.Error{}.Retryable()).To(BeTrue())
+			Expect(webhookclient.Error{StatusCode: http.StatusUnauthorized}.Retryable()).To(BeFalse())
+		})
+
+		It("should not report sends that got no response as retryable", func() {
+			Expect(webhookclient.Error{StatusCode: http.StatusTooManyRequests}.RetryableSend()).To(BeTrue())
+			Expect(webhookclient.Error{StatusCode: http.StatusBadGateway}.RetryableSend()).To(BeTrue())
+			Expect(webhookclient.Error{}.RetryableSend()).To(BeFalse())
+		})
+	})
+
+	Describe("Extract", func() {
+		It("should return strings and numbers", func() {
+			Expect(webhookclient.Extract([]byte(`{"sid": "SM1"}`), "sid")).To(Equal("SM1"))
+			Expect(webhookclient.Extract([]byte(`{"result": [{"id": 12345678901234}]}`), "result[0].id")).To(Equal("12345678901234"))
+		})
+
+		It("should return an error when the value is not a string or number", func() {
+			_, err := webhookclient.Extract([]byte(`{"data": {"id": null}}`), "data")
+			Expect(err).To(MatchError(`value at "data" is not a string or number`))
+		})
+
+		It("should return an error when the response is not JSON", func() {
+			_, err := webhookclient.Extract([]byte("OK"), "id")
+			Expect(err).To(MatchError(HavePrefix("response is not JSON: ")))
+		})
+	})
+
+	Describe("topics", func() {
+		It("should not be supported", func() {
+			_, err := newClient().CreateTopic("concourse")
+			Expect(err).To(MatchError("topics are not supported by the webhook provider"))
+		})
+	})
+})
@@ -1,65 +1,978 @@
 package application
 
-import "github.com/nickwei84/sms-resource/out/models"
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nickwei84/sms-resource/lib/awsclient"
+	"github.com/nickwei84/sms-resource/lib/delivery"
+	"github.com/nickwei84/sms-resource/lib/phonenumber"
+	"github.com/nickwei84/sms-resource/lib/segmenter"
+	"github.com/nickwei84/sms-resource/lib/sms"
+	"github.com/nickwei84/sms-resource/lib/statestore"
+	"github.com/nickwei84/sms-resource/lib/twilioclient"
+	"github.com/nickwei84/sms-resource/lib/webhookclient"
+	"github.com/nickwei84/sms-resource/out/models"
+)
+
+var now = time.Now
 
 //go:generate counterfeiter . SMSService
 type SMSService interface {
 	CreateTopic(topic string) (string, error)
-	GetExistingSubscribers(topicID string) ([]string, error)
-	CreateNewSubscriptions(topicID string, newSubscribers []string) error
-	PublishMessage(topicID string, message string) error
+	FindTopic(topic string) (string, error)
+	GetExistingSubscribers(topicID string) ([]sms.Subscription, error)
+	CreateNewSubscriptions(topicID string, newSubscribers []string) ([]sms.Subscription, error)
+	RemoveSubscriptions(subscriptions []sms.Subscription) error
+	PublishMessage(topicID string, message string, attributes sms.MessageAttributes) (string, error)
+	PublishToPhone(phoneNumber string, message string, attributes sms.MessageAttributes) (string, error)
+	IsOptedOut(phoneNumber string) (bool, error)
+	OptIn(phoneNumber string) error
+	WaitForDelivery(messageIDs []string, perMessage int, since time.Time, timeout time.Duration) ([]sms.DeliveryStatus, error)
+}
+
+//go:generate counterfeiter . ReplyListener
+type ReplyListener interface {
+	Receive(wait time.Duration) ([]sms.Reply, error)
+	Delete(replies []sms.Reply) error
+}
+
+//go:generate counterfeiter . StateStore
+type StateStore interface {
+	Get(key string) ([]byte, bool, error)
+	Put(key string, value []byte) error
+}
+
+func AWSConfig(source models.Source) awsclient.Config {
+	return awsclient.Config{
+		AccessKeyID:     source.AWSAccessKeyID,
+		SecretAccessKey: source.AWSSecretAccessKey,
+		SessionToken:    source.AWSSessionToken,
+		AssumeRoleArn:   source.AssumeRoleArn,
+		ExternalID:      source.AssumeRoleExternalID,
+		RoleSessionName: source.AssumeRoleSessionName,
+		Region:          source.Region,
+		Endpoint:        source.Endpoint,
+		DisableSSL:      source.DisableSSL,
+	}
+}
+
+// NewStateStore returns nil when source.state is not set.
+func NewStateStore(source models.Source) StateStore {
+	state := source.State
+	config := AWSConfig(source)
+	config.Endpoint = state.Endpoint
+	if state.Region != "" {
+		config.Region = state.Region
+	}
+
+	switch state.Backend {
+	case models.StateBackendS3:
+		return statestore.NewS3Store(awsclient.NewSession(config), state.Bucket, state.KeyPrefix)
+	case models.StateBackendDynamoDB:
+		return statestore.NewDynamoDBStore(awsclient.NewSession(config), state.Table)
+	case models.StateBackendFile:
+		return statestore.NewFileStore(state.Path)
+	default:
+		return nil
+	}
+}
+
+func NewSMSService(source models.Source) (SMSService, error) {
+	switch {
+	case source.IsTwilio():
+		from, err := phonenumber.Normalize(source.TwilioFromNumber, source.DefaultCountryCode)
+		if err != nil {
+			from = source.TwilioFromNumber
+		}
+
+		return twilioclient.NewClient(twilioclient.Config{
+			AccountSID: source.TwilioAccountSID,
+			AuthToken:  source.TwilioAuthToken,
+			From:       from,
+			BaseURL:    source.Endpoint,
+		}), nil
+	case source.IsWebhook():
+		client, err := webhookclient.NewClient(webhookclient.Config{
+			URL:           source.Webhook.URL,
+			Method:        source.Webhook.Method,
+			Headers:       source.Webhook.Headers,
+			BasicUsername: source.Webhook.Username,
+			BasicPassword: source.Webhook.Password,
+			BearerToken:   source.Webhook.BearerToken,
+			HMACSecret:    source.Webhook.HMACSecret,
+			HMACHeader:    source.Webhook.HMACHeader,
+			Body:          source.Webhook.Body,
+			SuccessCodes:  source.Webhook.SuccessCodes,
+			MessageIDPath: source.Webhook.MessageIDPath,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("source.webhook.body from stdin is not a valid template: %v", err)
+		}
+		return client, nil
+	default:
+		return awsclient.NewAWSClient(AWSConfig(source)), nil
+	}
+}
+
+type Provider struct {
+	Name    string
+	Service SMSService
 }
 
 type Application struct {
-	client SMSService
-	config models.SMSConfig
+	client    SMSService
+	providers []Provider
+	state     StateStore
+	onSent    func(models.Result) error
+	config    models.SMSConfig
 }
 
 func NewApplication(client SMSService, config models.SMSConfig) Application {
+	return NewFailoverApplication([]Provider{{Name: config.Source.ProviderName(), Service: client}}, config)
+}
+
+// Opt-outs are only checked with the first of the providers.
+func NewFailoverApplication(providers []Provider, config models.SMSConfig) Application {
 	return Application{
-		client: client,
-		config: config,
+		client:    providers[0].Service,
+		providers: providers,
+		config:    config,
 	}
 }
 
-func (a Application) Run() error {
-	topicArn, err := a.client.CreateTopic(a.config.Source.Topic)
+func (a Application) WithStateStore(state StateStore) Application {
+	a.state = state
+	return a
+}
+
+// OnSent is called as soon as any of the message is sent, before waiting for
+// delivery, so that a put interrupted afterwards can tell the message went out.
+func (a Application) OnSent(sent func(models.Result) error) Application {
+	a.onSent = sent
+	return a
+}
+
+func (a Application) Run() (models.Result, error) {
+	dedupeKey := a.dedupeKey()
+	if dedupeKey != "" {
+		lastSentAt, duplicate, err := a.checkDuplicate(dedupeKey)
+		if err != nil {
+			return models.Result{}, err
+		}
+		if duplicate {
+			return a.suppressDuplicate(lastSentAt), nil
+		}
+	}
+
+	message := a.config.Params.Message
+
+	ackCode := ""
+	if a.config.Params.Ack {
+		var err error
+		ackCode, err = newAckCode()
+		if err != nil {
+			return models.Result{}, err
+		}
+		message = fmt.Sprintf(ackInstructions, message, ackCode, ackCode)
+	}
+
+	parts, err := messageParts(message, a.config.Params.Overflow)
 	if err != nil {
-		return err
+		return models.Result{}, err
 	}
 
-	existingSubscribers, err := a.client.GetExistingSubscribers(topicArn)
+	optMetadata, optedOut, err := a.checkOptOuts()
 	if err != nil {
-		return err
+		return models.Result{Metadata: optMetadata}, err
+	}
+
+	quietMetadata, suppressed := a.checkQuietHours(optedOut)
+	skipped := optedOut.union(suppressed)
+
+	recipients := skipped.without(a.config.Params.Subscribers)
+	body := strings.Join(parts, "\n")
+	sent := func(result models.Result) error {
+		if a.onSent == nil {
+			return nil
+		}
+		result.Recipients = recipients
+		result.Body = body
+		result.AckCode = ackCode
+		return a.onSent(result)
+	}
+
+	var result models.Result
+	switch {
+	case a.config.IsDryRun():
+		result, err = a.dryRun(parts, skipped)
+	case a.config.Source.IsDirect():
+		result, err = a.publishToPhones(parts, skipped, sent)
+	default:
+		result, err = a.publishToTopic(parts, optedOut, sent)
+	}
+
+	result.Recipients = recipients
+	result.Body = body
+	result.AckCode = ackCode
+	result.Metadata = append(result.Metadata, optMetadata...)
+	result.Metadata = append(result.Metadata, quietMetadata...)
+
+	info := segmenter.Analyze(strings.Join(parts, ""))
+	result.Metadata = append(result.Metadata,
+		sms.MetadataItem{Name: "encoding", Value: string(info.Encoding)},
+		sms.MetadataItem{Name: "segments", Value: strconv.Itoa(segmentCount(parts))},
+	)
+	if ackCode != "" {
+		result.Metadata = append(result.Metadata, sms.MetadataItem{Name: "ack_code", Value: ackCode})
+	}
+
+	if err == nil && dedupeKey != "" && !a.config.IsDryRun() {
+		err = a.recordSent(dedupeKey, result)
+	}
+
+	return result, err
+}
+
+type sentRecord struct {
+	SentAt     time.Time `json:"sent_at"`
+	MessageIDs []string  `json:"message_ids"`
+}
+
+// dedupeKey is empty when duplicates are not suppressed, or in an offline dry run.
+func (a Application) dedupeKey() string {
+	if a.config.Params.DedupeWindow == 0 || a.state == nil || a.config.IsDryRunOffline() {
+		return ""
+	}
+
+	identity := a.config.Params.DedupeKey
+	if identity == "" {
+		destination := a.config.Source.Topic
+		if a.config.Source.IsDirect() {
+			subscribers := append([]string{}, a.config.Params.Subscribers...)
+			sort.Strings(subscribers)
+			destination = strings.Join(subscribers, ",")
+		}
+		identity = destination + "\n" + a.config.Params.Message
+	}
+
+	sum := sha256.Sum256([]byte(identity))
+	return "dedupe/" + hex.EncodeToString(sum[:])
+}
+
+func (a Application) checkDuplicate(key string) (time.Time, bool, error) {
+	value, found, err := a.state.Get(key)
+	if err != nil || !found {
+		return time.Time{}, false, err
+	}
+
+	var record sentRecord
+	err = json.Unmarshal(value, &record)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("error parsing state %s: %v", key, err)
 	}
 
-	newSubscribers := findNewSubscribers(existingSubscribers, a.config.Params.Subscribers)
+	return record.SentAt, now().Sub(record.SentAt) < time.Duration(a.config.Params.DedupeWindow), nil
+}
 
-	err = a.client.CreateNewSubscriptions(topicArn, newSubscribers)
+func (a Application) recordSent(key string, result models.Result) error {
+	value, err := json.Marshal(sentRecord{SentAt: result.SentAt.UTC(), MessageIDs: result.MessageIDs})
 	if err != nil {
 		return err
 	}
 
-	return a.client.PublishMessage(topicArn, a.config.Params.Message)
+	err = a.state.Put(key, value)
+	if err != nil {
+		return fmt.Errorf("the message was sent, but recording it for params.dedupe_window failed: %v", err)
+	}
+	return nil
+}
+
+func (a Application) suppressDuplicate(lastSentAt time.Time) models.Result {
+	return models.Result{
+		MessageIDs: []string{},
+		Recipients: []string{},
+		Body:       a.config.Params.Message,
+		Suppressed: true,
+		Metadata: []sms.MetadataItem{
+			{Name: "suppressed_duplicate", Value: "true"},
+			{Name: "last_sent_at", Value: lastSentAt.UTC().Format(time.RFC3339)},
+		},
+	}
+}
+
+// Without params.overflow, the message is sent as is and the carrier
+// concatenates the segments.
+func messageParts(message string, overflow string) ([]string, error) {
+	info := segmenter.Analyze(message)
+	if info.Segments == 1 {
+		return []string{message}, nil
+	}
+
+	switch overflow {
+	case models.OverflowTruncate:
+		return []string{segmenter.Truncate(message)}, nil
+	case models.OverflowSplit:
+		return segmenter.Split(message), nil
+	case models.OverflowFail:
+		return nil, fmt.Errorf("message is %d %s segments long and params.overflow is %q", info.Segments, info.Encoding, models.OverflowFail)
+	default:
+		return []string{message}, nil
+	}
+}
+
+func segmentCount(parts []string) int {
+	segments := 0
+	for _, part := range parts {
+		segments += segmenter.Analyze(part).Segments
+	}
+	return segments
 }
 
-func findNewSubscribers(existingSubscribers []string, subscribersFromInput []string) []string {
-	if len(existingSubscribers) == 0 {
-		return subscribersFromInput
+// Numbers that fail to be opted in are reported rather than failing the put, and
+// are treated as still opted out.
+func (a Application) checkOptOuts() ([]sms.MetadataItem, numberSet, error) {
+	metadata := []sms.MetadataItem{}
+	optedOut := numberSet{}
+
+	if a.config.IsDryRunOffline() {
+		return metadata, optedOut, nil
+	}
+
+	// Only the first provider is asked. With a chain of providers, it being
+	// down must not stop the others from sending, so once a lookup fails the
+	// remaining numbers are reported as unchecked and sent to.
+	unchecked := []string{}
+	isOptedOut := func(number string) (bool, error) {
+		if len(unchecked) > 0 {
+			unchecked = append(unchecked, number)
+			return false, nil
+		}
+
+		optedOut, err := a.client.IsOptedOut(number)
+		if err != nil && len(a.providers) > 1 {
+			unchecked = append(unchecked, number)
+			return false, nil
+		}
+		return optedOut, err
+	}
+
+	optedIn := []string{}
+	optInFailed := []string{}
+	for _, number := range a.config.Params.OptIn {
+		numberOptedOut, err := isOptedOut(number)
+		if err != nil {
+			return metadata, optedOut, err
+		}
+		if !numberOptedOut {
+			continue
+		}
+
+		if !a.config.IsDryRun() {
+			err = a.client.OptIn(number)
+			if err != nil {
+				optInFailed = append(optInFailed, number)
+				continue
+			}
+		}
+		optedIn = append(optedIn, number)
 	}
 
-	existingSubscribersMap := map[string]string{}
-	for _, existingSubscriber := range existingSubscribers {
-		existingSubscribersMap[existingSubscriber] = ""
+	if len(optedIn) > 0 {
+		metadata = append(metadata, sms.MetadataItem{Name: "opted_in", Value: maskedList(optedIn)})
+	}
+	if len(optInFailed) > 0 {
+		metadata = append(metadata, sms.MetadataItem{Name: "opt_in_failed", Value: maskedList(optInFailed)})
+	}
+
+	optIn := newNumberSet(a.config.Params.OptIn)
+	failed := newNumberSet(optInFailed)
+	optedOutList := []string{}
+	for _, subscriber := range a.config.Params.Subscribers {
+		if failed.has(subscriber) {
+			optedOutList = append(optedOutList, subscriber)
+			continue
+		}
+		if optIn.has(subscriber) {
+			continue
+		}
+
+		subscriberOptedOut, err := isOptedOut(subscriber)
+		if err != nil {
+			return metadata, optedOut, err
+		}
+		if subscriberOptedOut {
+			optedOutList = append(optedOutList, subscriber)
+		}
+	}
+
+	if len(unchecked) > 0 {
+		metadata = append(metadata, sms.MetadataItem{Name: "opt_out_unchecked", Value: maskedList(unchecked)})
+	}
+
+	if len(optedOutList) == 0 {
+		return metadata, optedOut, nil
+	}
+
+	metadata = append(metadata, sms.MetadataItem{Name: "opted_out", Value: maskedList(optedOutList)})
+
+	if a.config.Params.FailOnOptedOut {
+		return metadata, optedOut, fmt.Errorf("%d of %d subscribers have opted out of SMS messages: %s",
+			len(optedOutList), len(a.config.Params.Subscribers), maskedList(optedOutList))
+	}
+
+	return metadata, newNumberSet(optedOutList), nil
+}
+
+// Subscribers that have opted out are left out, as they are reported already.
+func (a Application) checkQuietHours(optedOut numberSet) ([]sms.MetadataItem, numberSet) {
+	suppressed := numberSet{}
+	if a.config.IsCritical() || len(a.config.Source.Contacts) == 0 {
+		return []sms.MetadataItem{}, suppressed
+	}
+
+	quiet := numberSet{}
+	at := now()
+	for _, contact := range a.config.Source.Contacts {
+		if contact.IsQuiet(at) {
+			quiet[comparableNumber(contact.Number)] = true
+		}
+	}
+
+	suppressedList := []string{}
+	for _, subscriber := range optedOut.without(a.config.Params.Subscribers) {
+		if quiet.has(subscriber) {
+			suppressedList = append(suppressedList, subscriber)
+			suppressed[comparableNumber(subscriber)] = true
+		}
+	}
+
+	if len(suppressedList) == 0 {
+		return []sms.MetadataItem{}, suppressed
+	}
+
+	return []sms.MetadataItem{{Name: "suppressed", Value: maskedList(suppressedList)}}, suppressed
+}
+
+// numberSet holds numbers in comparable form, so that subscriptions made before
+// numbers were normalized are found too.
+type numberSet map[string]bool
+
+func newNumberSet(numbers []string) numberSet {
+	set := numberSet{}
+	for _, number := range numbers {
+		set[comparableNumber(number)] = true
+	}
+	return set
+}
+
+func (s numberSet) union(other numberSet) numberSet {
+	union := numberSet{}
+	for number := range s {
+		union[number] = true
+	}
+	for number := range other {
+		union[number] = true
+	}
+	return union
+}
+
+func (s numberSet) has(number string) bool {
+	return s[comparableNumber(number)]
+}
+
+func (s numberSet) without(numbers []string) []string {
+	remaining := []string{}
+	for _, number := range numbers {
+		if !s.has(number) {
+			remaining = append(remaining, number)
+		}
+	}
+	return remaining
+}
+
+func (s numberSet) withoutSubscriptions(subscriptions []sms.Subscription) []sms.Subscription {
+	remaining := []sms.Subscription{}
+	for _, subscription := range subscriptions {
+		if !s.has(subscription.Endpoint) {
+			remaining = append(remaining, subscription)
+		}
+	}
+	return remaining
+}
+
+func (a Application) dryRun(parts []string, skipped numberSet) (models.Result, error) {
+	metadata := []sms.MetadataItem{}
+
+	if !a.config.Source.IsDirect() && !a.config.IsDryRunOffline() {
+		topicArn, err := a.client.FindTopic(a.config.Source.Topic)
+		if err != nil {
+			return models.Result{}, err
+		}
+
+		existingSubscriptions := []sms.Subscription{}
+		if topicArn != "" {
+			existingSubscriptions, err = a.client.GetExistingSubscribers(topicArn)
+			if err != nil {
+				return models.Result{}, err
+			}
+		}
+
+		diff := diffSubscribers(existingSubscriptions, a.config.Params.Subscribers)
+		diff.added = skipped.without(diff.added)
+		metadata = diff.metadata(a.config.Params.Reconcile)
 	}
 
-	newSubscribers := []string{}
+	recipients := skipped.without(a.config.Params.Subscribers)
+
+	return models.Result{
+		Metadata: append(metadata,
+			sms.MetadataItem{Name: "dry_run", Value: "true"},
+			sms.MetadataItem{Name: "recipient_count", Value: strconv.Itoa(len(recipients))},
+			sms.MetadataItem{Name: "recipients", Value: maskedList(recipients)},
+			sms.MetadataItem{Name: "message", Value: strings.Join(parts, "\n")},
+		),
+	}, nil
+}
+
+func (a Application) publishToTopic(parts []string, optedOut numberSet, sent func(models.Result) error) (models.Result, error) {
+	topicArn, err := a.client.CreateTopic(a.config.Source.Topic)
+	if err != nil {
+		return models.Result{}, err
+	}
+
+	existingSubscriptions, err := a.client.GetExistingSubscribers(topicArn)
+	if err != nil {
+		return models.Result{}, err
+	}
+
+	// Opted-out numbers are not subscribed, as SNS would drop every message to
+	// them, but their existing subscriptions are kept for when they opt in.
+	diff := diffSubscribers(existingSubscriptions, a.config.Params.Subscribers)
+	diff.added = optedOut.without(diff.added)
+
+	newSubscriptions, err := a.client.CreateNewSubscriptions(topicArn, diff.added)
+	if err != nil {
+		return models.Result{}, err
+	}
+
+	if a.config.Params.Reconcile && len(diff.removed) > 0 {
+		err = a.client.RemoveSubscriptions(diff.removed)
+		if err != nil {
+			return models.Result{}, err
+		}
+	}
+
+	recipients, pendingConfirmation := countSubscriptions(optedOut.withoutSubscriptions(append(diff.kept(a.config.Params.Reconcile), newSubscriptions...)))
+
+	result := models.Result{
+		MessageIDs:     []string{},
+		TopicArn:       topicArn,
+		RecipientCount: recipients,
+	}
+
+	sentAt := now()
+	result.SentAt = sentAt
+	for _, part := range parts {
+		messageID, err := a.client.PublishMessage(topicArn, part, a.config.MessageAttributes())
+		if err != nil {
+			result.Metadata = topicMetadata(result, recipients, pendingConfirmation, diff, a.config.Params.Reconcile)
+			if len(result.MessageIDs) > 0 {
+				// The put fails with the error from publishing either way.
+				sent(result)
+			}
+			return result, err
+		}
+		result.MessageIDs = append(result.MessageIDs, messageID)
+	}
+
+	result.Metadata = topicMetadata(result, recipients, pendingConfirmation, diff, a.config.Params.Reconcile)
+
+	err = sent(result)
+	if err != nil {
+		return result, err
+	}
+
+	if a.config.Params.WaitForDelivery > 0 {
+		return a.waitForDelivery(result, [][]string{result.MessageIDs}, recipients, sentAt)
+	}
+
+	return result, nil
+}
+
+// Only confirmed subscriptions receive messages published to the topic.
+func countSubscriptions(subscriptions []sms.Subscription) (int, int) {
+	confirmed := 0
+	pendingConfirmation := 0
+	for _, subscription := range subscriptions {
+		if subscription.PendingConfirmation {
+			pendingConfirmation++
+		} else {
+			confirmed++
+		}
+	}
+	return confirmed, pendingConfirmation
+}
+
+func topicMetadata(result models.Result, recipients int, pendingConfirmation int, diff subscriberDiff, reconcile bool) []sms.MetadataItem {
+	metadata := []sms.MetadataItem{
+		{Name: "topic_arn", Value: result.TopicArn},
+		{Name: "message_id", Value: strings.Join(result.MessageIDs, ",")},
+		{Name: "recipient_count", Value: strconv.Itoa(recipients)},
+	}
+	metadata = append(metadata, diff.metadata(reconcile)...)
+	return append(metadata, sms.MetadataItem{Name: "pending_confirmation", Value: strconv.Itoa(pendingConfirmation)})
+}
+
+// Sending to a subscriber goes on even when sending to an earlier one fails.
+func (a Application) publishToPhones(parts []string, skipped numberSet, sent func(models.Result) error) (models.Result, error) {
+	result := models.Result{MessageIDs: []string{}}
+	messageIDsByProvider := make([][]string, len(a.providers))
+	subscriberMetadata := []sms.MetadataItem{}
+	failures := []string{}
+	subscribers := skipped.without(a.config.Params.Subscribers)
+
+	sentAt := now()
+	result.SentAt = sentAt
+	anySent := false
+	for _, subscriber := range subscribers {
+		sentParts, err := a.publishPartsToPhone(subscriber, parts)
+		anySent = anySent || len(sentParts) > 0
+		providerNames := []string{}
+		messageIDUnknown := false
+		maybeSent := false
+		for _, part := range sentParts {
+			messageIDUnknown = messageIDUnknown || part.messageIDUnknown
+			maybeSent = maybeSent || part.maybeSent
+			if part.messageID != "" {
+				result.MessageIDs = append(result.MessageIDs, part.messageID)
+				messageIDsByProvider[part.provider] = append(messageIDsByProvider[part.provider], part.messageID)
+			}
+			name := a.providers[part.provider].Name
+			if len(providerNames) == 0 || providerNames[len(providerNames)-1] != name {
+				providerNames = append(providerNames, name)
+			}
+		}
+
+		if err != nil {
+			outcome := "failed"
+			if maybeSent {
+				outcome = "maybe sent via " + strings.Join(providerNames, ",")
+			}
+			subscriberMetadata = append(subscriberMetadata, sms.MetadataItem{Name: phonenumber.Mask(subscriber), Value: outcome})
+			failures = append(failures, err.Error())
+			continue
+		}
+
+		outcome := "sent"
+		if len(a.providers) > 1 {
+			outcome = "sent via " + strings.Join(providerNames, ",")
+		}
+		if messageIDUnknown {
+			outcome += " (message ID unknown)"
+		}
+		subscriberMetadata = append(subscriberMetadata, sms.MetadataItem{Name: phonenumber.Mask(subscriber), Value: outcome})
+	}
+
+	result.Metadata = append([]sms.MetadataItem{
+		{Name: "message_id", Value: strings.Join(result.MessageIDs, ",")},
+		{Name: "recipient_count", Value: strconv.Itoa(len(subscribers) - len(failures))},
+	}, subscriberMetadata...)
+
+	if anySent {
+		err := sent(result)
+		// A failure to send to some subscribers is reported instead.
+		if err != nil && len(failures) == 0 {
+			return result, err
+		}
+	}
+
+	if len(failures) > 0 {
+		return result, fmt.Errorf("failed to send message to %d of %d subscribers:\n%s",
+			len(failures), len(subscribers), strings.Join(failures, "\n"))
+	}
+
+	if a.config.Params.WaitForDelivery > 0 {
+		return a.waitForDelivery(result, messageIDsByProvider, 1, sentAt)
+	}
+
+	return result, nil
+}
+
+// Deliveries not reported in time are only reported as missing; a carrier
+// rejecting any of them fails the put.
+func (a Application) waitForDelivery(result models.Result, messageIDsByProvider [][]string, perMessage int, sentAt time.Time) (models.Result, error) {
+	timeout := time.Duration(a.config.Params.WaitForDelivery)
+	deadline := time.Now().Add(timeout)
+
+	statuses := []sms.DeliveryStatus{}
+	waited := false
+	for provider, messageIDs := range messageIDsByProvider {
+		if len(messageIDs) == 0 {
+			continue
+		}
+
+		wait := timeout
+		if waited {
+			wait = time.Until(deadline)
+			if wait < 0 {
+				wait = 0
+			}
+		}
+		waited = true
+
+		providerStatuses, err := a.providers[provider].Service.WaitForDelivery(messageIDs, perMessage, sentAt, wait)
+		if err != nil {
+			return result, err
+		}
+		statuses = append(statuses, providerStatuses...)
+	}
+
+	report := delivery.NewReport(statuses, perMessage*len(result.MessageIDs))
+	result.Metadata = append(result.Metadata, report.Metadata()...)
+	return result, report.Err()
+}
+
+type sentPart struct {
+	provider  int
+	messageID string
+
+	// messageIDUnknown is set when the provider accepted the part but could
+	// not report its message ID, and maybeSent when it may have accepted it.
+	messageIDUnknown bool
+	maybeSent        bool
+}
+
+// No part is ever sent twice: a part a provider may have accepted is not sent
+// through another, and a provider that fails is not tried for later parts.
+func (a Application) publishPartsToPhone(phoneNumber string, parts []string) ([]sentPart, error) {
+	sent := []sentPart{}
+	failures := []string{}
+	provider := 0
+	for _, part := range parts {
+		for {
+			messageID, err := a.providers[provider].Service.PublishToPhone(phoneNumber, part, a.config.MessageAttributes())
+			var messageIDErr sms.MessageIDError
+			if err == nil || errors.As(err, &messageIDErr) {
+				sent = append(sent, sentPart{provider: provider, messageID: messageID, messageIDUnknown: err != nil})
+				break
+			}
+
+			var maybeSentErr sms.MaybeSentError
+			if errors.As(err, &maybeSentErr) {
+				sent = append(sent, sentPart{provider: provider, maybeSent: true})
+				return sent, err
+			}
+
+			if len(a.providers) == 1 {
+				return sent, err
+			}
+
+			failures = append(failures, fmt.Sprintf("%s: %v", a.providers[provider].Name, err))
+			provider++
+			if provider == len(a.providers) {
+				return sent, fmt.Errorf("every provider failed: %s", strings.Join(failures, "; "))
+			}
+		}
+	}
+	return sent, nil
+}
+
+type subscriberDiff struct {
+	existing  []sms.Subscription
+	added     []string
+	removed   []sms.Subscription
+	unchanged []string
+}
+
+// Subscriptions pending confirmation have no ARN yet and cannot be removed.
+func diffSubscribers(subscriptions []sms.Subscription, subscribersFromInput []string) subscriberDiff {
+	diff := subscriberDiff{
+		added:     []string{},
+		removed:   []sms.Subscription{},
+		unchanged: []string{},
+	}
+
+	existingSubscriptions := []sms.Subscription{}
+	existingSubscribersMap := map[string]bool{}
+	for _, subscription := range subscriptions {
+		if subscription.Protocol != sms.ProtocolSMS {
+			continue
+		}
+		existingSubscriptions = append(existingSubscriptions, subscription)
+		existingSubscribersMap[comparableNumber(subscription.Endpoint)] = true
+	}
+	diff.existing = existingSubscriptions
+
+	subscribersFromInputMap := map[string]bool{}
 	for _, subscriberFromInput := range subscribersFromInput {
-		_, exist := existingSubscribersMap[subscriberFromInput]
-		if !exist {
-			newSubscribers = append(newSubscribers, subscriberFromInput)
+		number := comparableNumber(subscriberFromInput)
+		if subscribersFromInputMap[number] {
+			continue
+		}
+		subscribersFromInputMap[number] = true
+
+		if existingSubscribersMap[number] {
+			diff.unchanged = append(diff.unchanged, subscriberFromInput)
+		} else {
+			diff.added = append(diff.added, subscriberFromInput)
+		}
+	}
+
+	for _, existingSubscription := range existingSubscriptions {
+		if !subscribersFromInputMap[comparableNumber(existingSubscription.Endpoint)] && !existingSubscription.PendingConfirmation {
+			diff.removed = append(diff.removed, existingSubscription)
+		}
+	}
+
+	return diff
+}
+
+// comparableNumber lets subscriptions made before numbers were normalized, e.g.
+// "14151234567", match their E.164 form in the input.
+func comparableNumber(endpoint string) string {
+	number, err := phonenumber.Normalize(endpoint, "")
+	if err != nil {
+		return endpoint
+	}
+	return number
+}
+
+func (d subscriberDiff) kept(reconcile bool) []sms.Subscription {
+	if !reconcile {
+		return d.existing
+	}
+
+	removed := map[string]bool{}
+	for _, subscription := range d.removed {
+		removed[subscription.SubscriptionArn] = true
+	}
+
+	kept := []sms.Subscription{}
+	for _, subscription := range d.existing {
+		if !removed[subscription.SubscriptionArn] {
+			kept = append(kept, subscription)
+		}
+	}
+	return kept
+}
+
+func (d subscriberDiff) metadata(reconcile bool) []sms.MetadataItem {
+	metadata := []sms.MetadataItem{
+		{Name: "added", Value: maskedList(d.added)},
+	}
+
+	if reconcile {
+		removed := []string{}
+		for _, subscription := range d.removed {
+			removed = append(removed, subscription.Endpoint)
+		}
+		metadata = append(metadata, sms.MetadataItem{Name: "removed", Value: maskedList(removed)})
+	}
+
+	return append(metadata, sms.MetadataItem{Name: "unchanged", Value: maskedList(d.unchanged)})
+}
+
+func maskedList(numbers []string) string {
+	return strings.Join(phonenumber.MaskAll(numbers), ",")
+}
+
+const ackInstructions = "%s\nReply ACK %s to approve or NACK %s to reject."
+
+const ackCodeDigits = 4
+
+var ackReplyPattern = regexp.MustCompile(`^\s*(?i:(N?ACK))\s+(\d+)\s*$`)
+
+// Replies are accepted from a little before the message was sent, in case the
+// local clock is ahead of the one SNS timestamps replies with.
+const ackClockSkew = time.Minute
+
+// The code is random so that it cannot be replied to before it is sent.
+func newAckCode() (string, error) {
+	max := big.NewInt(1)
+	for i := 0; i < ackCodeDigits; i++ {
+		max.Mul(max, big.NewInt(10))
+	}
+
+	n, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		return "", fmt.Errorf("error generating acknowledgement code: %v", err)
+	}
+
+	return fmt.Sprintf("%0*d", ackCodeDigits, n.Int64()), nil
+}
+
+// Replies other than ACK or NACK with code from source.inbound_senders are left
+// in the queue.
+func (a Application) WaitForAck(listener ReplyListener, code string, sentAt time.Time, timeout time.Duration) (sms.Reply, error) {
+	senders, err := phonenumber.NormalizeAll(a.config.Source.InboundSenders, a.config.Source.DefaultCountryCode)
+	if err != nil {
+		return sms.Reply{}, fmt.Errorf("error in source.inbound_senders: %v", err)
+	}
+	if len(senders) == 0 {
+		return sms.Reply{}, fmt.Errorf("source.inbound_senders from stdin is either empty or missing")
+	}
+
+	authorized := map[string]bool{}
+	for _, sender := range senders {
+		authorized[sender] = true
+	}
+
+	deadline := now().Add(timeout)
+	for {
+		remaining := deadline.Sub(now())
+		if remaining <= 0 {
+			return sms.Reply{}, fmt.Errorf("no acknowledgement with code %s was received within %s", code, timeout)
+		}
+
+		replies, err := listener.Receive(remaining)
+		if err != nil {
+			return sms.Reply{}, err
+		}
+
+		for _, reply := range replies {
+			keyword, ok := ackKeyword(reply, code, authorized, sentAt)
+			if !ok {
+				continue
+			}
+
+			err = listener.Delete([]sms.Reply{reply})
+			if err != nil {
+				return sms.Reply{}, err
+			}
+
+			if keyword == "NACK" {
+				return reply, fmt.Errorf("message with code %s was refused by %s", code, phonenumber.Mask(reply.Sender))
+			}
+			return reply, nil
 		}
 	}
+}
+
+func ackKeyword(reply sms.Reply, code string, authorized map[string]bool, sentAt time.Time) (string, bool) {
+	sender, err := phonenumber.Normalize(reply.Sender, "")
+	if err != nil || !authorized[sender] {
+		return "", false
+	}
+
+	if reply.Timestamp.Before(sentAt.Add(-ackClockSkew)) {
+		return "", false
+	}
+
+	match := ackReplyPattern.FindStringSubmatch(reply.Body)
+	if match == nil || match[2] != code {
+		return "", false
+	}
 
-	return newSubscribers
+	return strings.ToUpper(match[1]), true
 }
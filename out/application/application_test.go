@@ -1,6 +1,11 @@
 package application_test
 
 import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/nickwei84/sms-resource/lib/sms"
 	"github.com/nickwei84/sms-resource/out/application"
 	"github.com/nickwei84/sms-resource/out/application/applicationfakes"
 	"github.com/nickwei84/sms-resource/out/models"
@@ -33,19 +38,28 @@ var _ = Describe("Application", func() {
 	})
 
 	Describe("Run", func() {
-		var runAppErr error
+		var (
+			result    models.Result
+			runAppErr error
+		)
 
 		BeforeEach(func() {
 			client = new(applicationfakes.FakeSMSService)
 			client.CreateTopicReturns("my-topic-arn", nil)
-			client.GetExistingSubscribersReturns([]string{}, nil)
-			client.CreateNewSubscriptionsReturns(nil)
-			client.PublishMessageReturns(nil)
+			client.GetExistingSubscribersReturns([]sms.Subscription{}, nil)
+			client.CreateNewSubscriptionsStub = func(topicID string, newSubscribers []string) ([]sms.Subscription, error) {
+				subscriptions := []sms.Subscription{}
+				for _, subscriber := range newSubscribers {
+					subscriptions = append(subscriptions, sms.Subscription{Endpoint: subscriber, Protocol: "sms", SubscriptionArn: subscriber + "-arn"})
+				}
+				return subscriptions, nil
+			}
+			client.PublishMessageReturns("message-id", nil)
 			app = application.NewApplication(client, config)
 		})
 
 		JustBeforeEach(func() {
-			runAppErr = app.Run()
+			result, runAppErr = app.Run()
 		})
 
 		It("should create the SMS topic from configuration", func() {
@@ -62,7 +76,7 @@ var _ = Describe("Application", func() {
 
 		Context("when there are no existing subscribers to the topic", func() {
 			BeforeEach(func() {
-				client.GetExistingSubscribersReturns([]string{}, nil)
+				client.GetExistingSubscribersReturns([]sms.Subscription{}, nil)
 			})
 
 			It("should subscribe all subscribers from configuration", func() {
@@ -77,10 +91,65 @@ var _ = Describe("Application", func() {
 			})
 		})
 
-		Context("when there are existing subscribers to the topic", func() {
+		Context("when an existing subscription to the topic is pending confirmation", func() {
+			BeforeEach(func() {
+				client.GetExistingSubscribersReturns([]sms.Subscription{
+					{Endpoint: "subscriber1", Protocol: "sms", SubscriptionArn: "PendingConfirmation", PendingConfirmation: true},
+				}, nil)
+			})
+
+			It("should not subscribe it again", func() {
+				Expect(runAppErr).NotTo(HaveOccurred())
+				_, arg2 := client.CreateNewSubscriptionsArgsForCall(0)
+				Expect(arg2).To(Equal([]string{
+					"subscriber2",
+				}))
+			})
+		})
+
+		Context("when an existing subscription to the topic was made with a number that is not in E.164 form", func() {
 			BeforeEach(func() {
-				client.GetExistingSubscribersReturns([]string{
+				e164Config := config
+				e164Config.Params.Subscribers = []string{"+14151234567", "+16501234567"}
+				app = application.NewApplication(client, e164Config)
+				client.GetExistingSubscribersReturns([]sms.Subscription{
+					{Endpoint: "14151234567", Protocol: "sms", SubscriptionArn: "subscriber1-arn"},
+				}, nil)
+			})
+
+			It("should treat it as the same subscriber", func() {
+				Expect(runAppErr).NotTo(HaveOccurred())
+				_, arg2 := client.CreateNewSubscriptionsArgsForCall(0)
+				Expect(arg2).To(Equal([]string{
+					"+16501234567",
+				}))
+			})
+		})
+
+		Context("when an existing subscription to the topic is not an SMS subscription", func() {
+			BeforeEach(func() {
+				client.GetExistingSubscribersReturns([]sms.Subscription{
+					{Endpoint: "subscriber1", Protocol: "http", SubscriptionArn: "http-arn"},
+				}, nil)
+			})
+
+			It("should still subscribe the phone number", func() {
+				Expect(runAppErr).NotTo(HaveOccurred())
+				_, arg2 := client.CreateNewSubscriptionsArgsForCall(0)
+				Expect(arg2).To(Equal([]string{
 					"subscriber1",
+					"subscriber2",
+				}))
+			})
+		})
+
+		Context("when there are existing subscribers to the topic", func() {
+			BeforeEach(func() {
+				client.GetExistingSubscribersReturns([]sms.Subscription{
+					{Endpoint: "subscriber1", Protocol: "sms", SubscriptionArn: "subscriber1-arn"},
+					{Endpoint: "subscriber3", Protocol: "sms", SubscriptionArn: "subscriber3-arn"},
+					{Endpoint: "subscriber4", Protocol: "sms", SubscriptionArn: "PendingConfirmation", PendingConfirmation: true},
+					{Endpoint: "ops@example.com", Protocol: "email", SubscriptionArn: "email-arn"},
 				}, nil)
 			})
 
@@ -93,14 +162,1206 @@ var _ = Describe("Application", func() {
 					"subscriber2",
 				}))
 			})
+
+			It("should not unsubscribe subscribers missing from configuration", func() {
+				Expect(runAppErr).NotTo(HaveOccurred())
+				Expect(client.RemoveSubscriptionsCallCount()).To(Equal(0))
+			})
+
+			It("should report the added and unchanged subscribers", func() {
+				Expect(runAppErr).NotTo(HaveOccurred())
+				Expect(result.Metadata).To(Equal([]sms.MetadataItem{
+					{Name: "topic_arn", Value: "my-topic-arn"},
+					{Name: "message_id", Value: "message-id"},
+					{Name: "recipient_count", Value: "3"},
+					{Name: "added", Value: "subscriber2"},
+					{Name: "unchanged", Value: "subscriber1"},
+					{Name: "pending_confirmation", Value: "1"},
+					{Name: "encoding", Value: "GSM-7"},
+					{Name: "segments", Value: "1"},
+				}))
+			})
+
+			It("should return the topic ARN and message ID", func() {
+				Expect(runAppErr).NotTo(HaveOccurred())
+				Expect(result.TopicArn).To(Equal("my-topic-arn"))
+				Expect(result.MessageIDs).To(Equal([]string{"message-id"}))
+			})
+
+			Context("when a new subscription is pending confirmation", func() {
+				BeforeEach(func() {
+					client.CreateNewSubscriptionsReturns([]sms.Subscription{
+						{Endpoint: "subscriber2", Protocol: "sms", SubscriptionArn: "pending confirmation", PendingConfirmation: true},
+					}, nil)
+				})
+
+				It("should count it as pending rather than as a recipient", func() {
+					Expect(runAppErr).NotTo(HaveOccurred())
+					Expect(result.Metadata).To(ContainElement(sms.MetadataItem{Name: "recipient_count", Value: "2"}))
+					Expect(result.Metadata).To(ContainElement(sms.MetadataItem{Name: "pending_confirmation", Value: "2"}))
+				})
+			})
+
+			Context("when reconcile is requested", func() {
+				BeforeEach(func() {
+					reconcileConfig := config
+					reconcileConfig.Params.Reconcile = true
+					app = application.NewApplication(client, reconcileConfig)
+				})
+
+				It("should unsubscribe subscribers missing from configuration", func() {
+					Expect(runAppErr).NotTo(HaveOccurred())
+					Expect(client.RemoveSubscriptionsCallCount()).To(Equal(1))
+					Expect(client.RemoveSubscriptionsArgsForCall(0)).To(Equal([]sms.Subscription{
+						{Endpoint: "subscriber3", Protocol: "sms", SubscriptionArn: "subscriber3-arn"},
+					}))
+				})
+
+				It("should report the added, removed and unchanged subscribers", func() {
+					Expect(runAppErr).NotTo(HaveOccurred())
+					Expect(result.Metadata).To(Equal([]sms.MetadataItem{
+						{Name: "topic_arn", Value: "my-topic-arn"},
+						{Name: "message_id", Value: "message-id"},
+						{Name: "recipient_count", Value: "2"},
+						{Name: "added", Value: "subscriber2"},
+						{Name: "removed", Value: "subscriber3"},
+						{Name: "unchanged", Value: "subscriber1"},
+						{Name: "pending_confirmation", Value: "1"},
+						{Name: "encoding", Value: "GSM-7"},
+						{Name: "segments", Value: "1"},
+					}))
+				})
+
+				Context("when unsubscribing fails", func() {
+					BeforeEach(func() {
+						client.RemoveSubscriptionsReturns(errors.New("error unsubscribing subscriber3: not found"))
+					})
+
+					It("should return the error without publishing", func() {
+						Expect(runAppErr).To(MatchError("error unsubscribing subscriber3: not found"))
+						Expect(client.PublishMessageCallCount()).To(Equal(0))
+					})
+				})
+			})
+
+			Context("when a dry run of reconcile is requested", func() {
+				BeforeEach(func() {
+					client.FindTopicReturns("my-topic-arn", nil)
+					dryRunConfig := config
+					dryRunConfig.Params.Reconcile = true
+					dryRunConfig.Params.DryRun = true
+					app = application.NewApplication(client, dryRunConfig)
+				})
+
+				It("should look up the topic without creating it", func() {
+					Expect(runAppErr).NotTo(HaveOccurred())
+					Expect(client.CreateTopicCallCount()).To(Equal(0))
+					Expect(client.FindTopicCallCount()).To(Equal(1))
+					Expect(client.FindTopicArgsForCall(0)).To(Equal("my-topic"))
+					Expect(client.GetExistingSubscribersArgsForCall(0)).To(Equal("my-topic-arn"))
+				})
+
+				It("should not change subscriptions or publish", func() {
+					Expect(runAppErr).NotTo(HaveOccurred())
+					Expect(client.CreateNewSubscriptionsCallCount()).To(Equal(0))
+					Expect(client.RemoveSubscriptionsCallCount()).To(Equal(0))
+					Expect(client.PublishMessageCallCount()).To(Equal(0))
+				})
+
+				It("should report the diff", func() {
+					Expect(runAppErr).NotTo(HaveOccurred())
+					Expect(result.Metadata).To(Equal([]sms.MetadataItem{
+						{Name: "added", Value: "subscriber2"},
+						{Name: "removed", Value: "subscriber3"},
+						{Name: "unchanged", Value: "subscriber1"},
+						{Name: "dry_run", Value: "true"},
+						{Name: "recipient_count", Value: "2"},
+						{Name: "recipients", Value: "subscriber1,subscriber2"},
+						{Name: "message", Value: "hello"},
+						{Name: "encoding", Value: "GSM-7"},
+						{Name: "segments", Value: "1"},
+					}))
+				})
+
+				Context("when the topic does not exist yet", func() {
+					BeforeEach(func() {
+						client.FindTopicReturns("", nil)
+					})
+
+					It("should report every subscriber as added", func() {
+						Expect(runAppErr).NotTo(HaveOccurred())
+						Expect(client.GetExistingSubscribersCallCount()).To(Equal(0))
+						Expect(result.Metadata).To(ContainElement(sms.MetadataItem{Name: "added", Value: "subscriber1,subscriber2"}))
+					})
+				})
+
+				Context("when the dry run is requested in source configuration", func() {
+					BeforeEach(func() {
+						dryRunConfig := config
+						dryRunConfig.Source.DryRun = true
+						app = application.NewApplication(client, dryRunConfig)
+					})
+
+					It("should not change subscriptions or publish", func() {
+						Expect(runAppErr).NotTo(HaveOccurred())
+						Expect(client.CreateTopicCallCount()).To(Equal(0))
+						Expect(client.CreateNewSubscriptionsCallCount()).To(Equal(0))
+						Expect(client.PublishMessageCallCount()).To(Equal(0))
+						Expect(result.Metadata).To(ContainElement(sms.MetadataItem{Name: "dry_run", Value: "true"}))
+					})
+				})
+
+				Context("when the dry run is offline", func() {
+					BeforeEach(func() {
+						dryRunConfig := config
+						dryRunConfig.Params.DryRunOffline = true
+						app = application.NewApplication(client, dryRunConfig)
+					})
+
+					It("should not make any calls", func() {
+						Expect(runAppErr).NotTo(HaveOccurred())
+						Expect(client.Invocations()).To(BeEmpty())
+					})
+
+					It("should report what would be sent and to whom", func() {
+						Expect(runAppErr).NotTo(HaveOccurred())
+						Expect(result.Metadata).To(Equal([]sms.MetadataItem{
+							{Name: "dry_run", Value: "true"},
+							{Name: "recipient_count", Value: "2"},
+							{Name: "recipients", Value: "subscriber1,subscriber2"},
+							{Name: "message", Value: "hello"},
+							{Name: "encoding", Value: "GSM-7"},
+							{Name: "segments", Value: "1"},
+						}))
+					})
+				})
+			})
+		})
+
+		It("should not wait for delivery by default", func() {
+			Expect(runAppErr).NotTo(HaveOccurred())
+			Expect(client.WaitForDeliveryCallCount()).To(Equal(0))
+		})
+
+		Context("when waiting for delivery is requested", func() {
+			BeforeEach(func() {
+				waitConfig := config
+				waitConfig.Params.WaitForDelivery = models.Duration(time.Minute)
+				app = application.NewApplication(client, waitConfig)
+				client.WaitForDeliveryReturns([]sms.DeliveryStatus{
+					{MessageID: "message-id", Destination: "subscriber1", Status: "SUCCESS"},
+					{MessageID: "message-id", Destination: "subscriber2", Status: "SUCCESS"},
+				}, nil)
+			})
+
+			It("should wait for a delivery to every confirmed subscriber", func() {
+				Expect(runAppErr).NotTo(HaveOccurred())
+				Expect(client.WaitForDeliveryCallCount()).To(Equal(1))
+				messageIDs, perMessage, since, timeout := client.WaitForDeliveryArgsForCall(0)
+				Expect(messageIDs).To(Equal([]string{"message-id"}))
+				Expect(perMessage).To(Equal(2))
+				Expect(since).To(BeTemporally("~", time.Now(), time.Second))
+				Expect(timeout).To(Equal(time.Minute))
+			})
+
+			It("should report when the message was sent, before waiting", func() {
+				Expect(runAppErr).NotTo(HaveOccurred())
+				_, _, since, _ := client.WaitForDeliveryArgsForCall(0)
+				Expect(result.SentAt).To(Equal(since))
+			})
+
+			Context("when told about sent messages", func() {
+				var sent []models.Result
+
+				BeforeEach(func() {
+					sent = nil
+					app = app.OnSent(func(result models.Result) error {
+						Expect(client.WaitForDeliveryCallCount()).To(Equal(0))
+						sent = append(sent, result)
+						return nil
+					})
+				})
+
+				It("should report the message before waiting for its delivery", func() {
+					Expect(runAppErr).NotTo(HaveOccurred())
+					Expect(sent).To(HaveLen(1))
+					Expect(sent[0].MessageIDs).To(Equal([]string{"message-id"}))
+					Expect(sent[0].Body).To(Equal(result.Body))
+				})
+
+				Context("when a carrier rejects the message", func() {
+					BeforeEach(func() {
+						client.WaitForDeliveryReturns([]sms.DeliveryStatus{
+							{MessageID: "message-id", Destination: "subscriber1", Status: "FAILURE"},
+						}, nil)
+					})
+
+					It("should still have reported the message as sent", func() {
+						Expect(runAppErr).To(HaveOccurred())
+						Expect(sent).To(HaveLen(1))
+					})
+				})
+
+				Context("when nothing is sent", func() {
+					BeforeEach(func() {
+						client.PublishMessageReturns("", errors.New("error publishing message: throttled"))
+					})
+
+					It("should not report anything", func() {
+						Expect(runAppErr).To(HaveOccurred())
+						Expect(sent).To(BeEmpty())
+					})
+				})
+			})
+
+			It("should report the deliveries", func() {
+				Expect(runAppErr).NotTo(HaveOccurred())
+				Expect(result.Metadata).To(ContainElement(sms.MetadataItem{Name: "delivered", Value: "2"}))
+				Expect(result.Metadata).To(ContainElement(sms.MetadataItem{Name: "delivery_failed", Value: "0"}))
+				Expect(result.Metadata).To(ContainElement(sms.MetadataItem{Name: "delivery_unknown", Value: "0"}))
+			})
+
+			Context("when a carrier rejects the message", func() {
+				BeforeEach(func() {
+					client.WaitForDeliveryReturns([]sms.DeliveryStatus{
+						{MessageID: "message-id", Destination: "+14151234567", Status: "FAILURE", ProviderResponse: "Unknown error attempting to reach phone"},
+					}, nil)
+				})
+
+				It("should return an error", func() {
+					Expect(runAppErr).To(MatchError("1 of 2 deliveries failed:\n  +*******4567: Unknown error attempting to reach phone"))
+					Expect(result.Metadata).To(ContainElement(sms.MetadataItem{Name: "delivery_unknown", Value: "1"}))
+				})
+			})
+
+			Context("when the delivery status logs cannot be read", func() {
+				BeforeEach(func() {
+					client.WaitForDeliveryReturns(nil, errors.New("error listing delivery status log groups: access denied"))
+				})
+
+				It("should return the error", func() {
+					Expect(runAppErr).To(MatchError("error listing delivery status log groups: access denied"))
+				})
+			})
+
+			Context("when the mode is direct", func() {
+				BeforeEach(func() {
+					waitConfig := config
+					waitConfig.Source.Mode = models.ModeDirect
+					waitConfig.Params.WaitForDelivery = models.Duration(time.Minute)
+					app = application.NewApplication(client, waitConfig)
+					client.PublishToPhoneStub = func(phoneNumber string, message string, attributes sms.MessageAttributes) (string, error) {
+						return phoneNumber + "-message-id", nil
+					}
+				})
+
+				It("should wait for a delivery for every message ID", func() {
+					Expect(runAppErr).NotTo(HaveOccurred())
+					messageIDs, perMessage, _, _ := client.WaitForDeliveryArgsForCall(0)
+					Expect(messageIDs).To(Equal([]string{"subscriber1-message-id", "subscriber2-message-id"}))
+					Expect(perMessage).To(Equal(1))
+				})
+			})
+
+			Context("when a dry run is requested", func() {
+				BeforeEach(func() {
+					dryRunConfig := config
+					dryRunConfig.Params.WaitForDelivery = models.Duration(time.Minute)
+					dryRunConfig.Params.DryRunOffline = true
+					app = application.NewApplication(client, dryRunConfig)
+				})
+
+				It("should not wait for delivery", func() {
+					Expect(runAppErr).NotTo(HaveOccurred())
+					Expect(client.WaitForDeliveryCallCount()).To(Equal(0))
+				})
+			})
+		})
+
+		It("should check whether each subscriber has opted out", func() {
+			Expect(runAppErr).NotTo(HaveOccurred())
+			Expect(client.IsOptedOutCallCount()).To(Equal(2))
+			Expect(client.IsOptedOutArgsForCall(0)).To(Equal("subscriber1"))
+			Expect(client.IsOptedOutArgsForCall(1)).To(Equal("subscriber2"))
+			Expect(client.OptInCallCount()).To(Equal(0))
+		})
+
+		Context("when a subscriber has opted out", func() {
+			BeforeEach(func() {
+				client.IsOptedOutStub = func(phoneNumber string) (bool, error) {
+					return phoneNumber == "+14151234567", nil
+				}
+				optOutConfig := config
+				optOutConfig.Params.Subscribers = []string{"+14151234567", "+16505550123"}
+				app = application.NewApplication(client, optOutConfig)
+			})
+
+			It("should not subscribe the opted-out number", func() {
+				Expect(runAppErr).NotTo(HaveOccurred())
+				_, newSubscribers := client.CreateNewSubscriptionsArgsForCall(0)
+				Expect(newSubscribers).To(Equal([]string{"+16505550123"}))
+			})
+
+			It("should leave the opted-out number out of the recipients and report it", func() {
+				Expect(runAppErr).NotTo(HaveOccurred())
+				Expect(result.Recipients).To(Equal([]string{"+16505550123"}))
+				Expect(result.Metadata).To(ContainElement(sms.MetadataItem{Name: "recipient_count", Value: "1"}))
+				Expect(result.Metadata).To(ContainElement(sms.MetadataItem{Name: "added", Value: "+*******0123"}))
+				Expect(result.Metadata).To(ContainElement(sms.MetadataItem{Name: "opted_out", Value: "+*******4567"}))
+			})
+
+			Context("when the opted-out number is already subscribed", func() {
+				BeforeEach(func() {
+					client.GetExistingSubscribersReturns([]sms.Subscription{
+						{Endpoint: "14151234567", Protocol: "sms", SubscriptionArn: "subscription-1"},
+					}, nil)
+				})
+
+				It("should not count it as a recipient", func() {
+					Expect(runAppErr).NotTo(HaveOccurred())
+					Expect(result.Metadata).To(ContainElement(sms.MetadataItem{Name: "recipient_count", Value: "1"}))
+					Expect(result.Metadata).To(ContainElement(sms.MetadataItem{Name: "unchanged", Value: "+*******4567"}))
+				})
+			})
+
+			Context("when failing on opted-out numbers is requested", func() {
+				BeforeEach(func() {
+					failConfig := config
+					failConfig.Params.Subscribers = []string{"+14151234567", "+16505550123"}
+					failConfig.Params.FailOnOptedOut = true
+					app = application.NewApplication(client, failConfig)
+				})
+
+				It("should return an error without sending anything", func() {
+					Expect(runAppErr).To(MatchError("1 of 2 subscribers have opted out of SMS messages: +*******4567"))
+					Expect(result.Metadata).To(Equal([]sms.MetadataItem{{Name: "opted_out", Value: "+*******4567"}}))
+					Expect(client.CreateTopicCallCount()).To(Equal(0))
+					Expect(client.PublishMessageCallCount()).To(Equal(0))
+				})
+			})
+
+			Context("when the number is opted in again", func() {
+				var optInConfig models.SMSConfig
+
+				BeforeEach(func() {
+					optInConfig = config
+					optInConfig.Params.Subscribers = []string{"+14151234567", "+16505550123"}
+					optInConfig.Params.OptIn = []string{"+14151234567"}
+					app = application.NewApplication(client, optInConfig)
+				})
+
+				It("should opt it in and send to it", func() {
+					Expect(runAppErr).NotTo(HaveOccurred())
+					Expect(client.OptInCallCount()).To(Equal(1))
+					Expect(client.OptInArgsForCall(0)).To(Equal("+14151234567"))
+					Expect(client.IsOptedOutCallCount()).To(Equal(2))
+					Expect(result.Recipients).To(Equal([]string{"+14151234567", "+16505550123"}))
+					Expect(result.Metadata).To(ContainElement(sms.MetadataItem{Name: "opted_in", Value: "+*******4567"}))
+					for _, item := range result.Metadata {
+						Expect(item.Name).NotTo(Equal("opted_out"))
+					}
+				})
+
+				Context("when opting in fails", func() {
+					BeforeEach(func() {
+						client.OptInReturns(errors.New("error opting in +14151234567: opted in within the last 30 days"))
+					})
+
+					It("should report the number as still opted out and send to the rest", func() {
+						Expect(runAppErr).NotTo(HaveOccurred())
+						Expect(result.Recipients).To(Equal([]string{"+16505550123"}))
+						Expect(result.Metadata).To(ContainElement(sms.MetadataItem{Name: "opt_in_failed", Value: "+*******4567"}))
+						Expect(result.Metadata).To(ContainElement(sms.MetadataItem{Name: "opted_out", Value: "+*******4567"}))
+						for _, item := range result.Metadata {
+							Expect(item.Name).NotTo(Equal("opted_in"))
+						}
+						Expect(client.PublishMessageCallCount()).To(Equal(1))
+					})
+				})
+
+				Context("when the number has not opted out", func() {
+					BeforeEach(func() {
+						client.IsOptedOutReturns(false, nil)
+						client.IsOptedOutStub = nil
+					})
+
+					It("should not opt it in", func() {
+						Expect(runAppErr).NotTo(HaveOccurred())
+						Expect(client.OptInCallCount()).To(Equal(0))
+						for _, item := range result.Metadata {
+							Expect(item.Name).NotTo(Equal("opted_in"))
+						}
+					})
+				})
+
+				Context("when a dry run is requested", func() {
+					BeforeEach(func() {
+						optInConfig.Params.DryRun = true
+						app = application.NewApplication(client, optInConfig)
+					})
+
+					It("should report the number without opting it in", func() {
+						Expect(runAppErr).NotTo(HaveOccurred())
+						Expect(client.OptInCallCount()).To(Equal(0))
+						Expect(result.Metadata).To(ContainElement(sms.MetadataItem{Name: "opted_in", Value: "+*******4567"}))
+					})
+				})
+			})
+		})
+
+		Context("when checking opt-outs fails", func() {
+			BeforeEach(func() {
+				client.IsOptedOutReturns(false, errors.New("error checking whether subscriber1 opted out: access denied"))
+			})
+
+			It("should return the error without sending anything", func() {
+				Expect(runAppErr).To(MatchError("error checking whether subscriber1 opted out: access denied"))
+				Expect(client.CreateTopicCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when an acknowledgement is requested", func() {
+			BeforeEach(func() {
+				ackConfig := config
+				ackConfig.Params.Ack = true
+				app = application.NewApplication(client, ackConfig)
+			})
+
+			It("should ask recipients to reply with a generated code", func() {
+				Expect(runAppErr).NotTo(HaveOccurred())
+				Expect(result.AckCode).To(MatchRegexp(`^\d{4}$`))
+				_, message, _ := client.PublishMessageArgsForCall(0)
+				Expect(message).To(Equal("hello\nReply ACK " + result.AckCode + " to approve or NACK " + result.AckCode + " to reject."))
+				Expect(result.Body).To(Equal(message))
+			})
+
+			It("should report the code", func() {
+				Expect(runAppErr).NotTo(HaveOccurred())
+				Expect(result.Metadata).To(ContainElement(sms.MetadataItem{Name: "ack_code", Value: result.AckCode}))
+			})
+		})
+
+		It("should not ask for an acknowledgement by default", func() {
+			Expect(runAppErr).NotTo(HaveOccurred())
+			Expect(result.AckCode).To(BeEmpty())
+			_, message, _ := client.PublishMessageArgsForCall(0)
+			Expect(message).To(Equal("hello"))
 		})
 
 		It("should publish the message from configuration", func() {
 			Expect(runAppErr).NotTo(HaveOccurred())
 			Expect(client.PublishMessageCallCount()).To(Equal(1))
-			arg1, arg2 := client.PublishMessageArgsForCall(0)
+			arg1, arg2, arg3 := client.PublishMessageArgsForCall(0)
 			Expect(arg1).To(Equal("my-topic-arn"))
 			Expect(arg2).To(Equal("hello"))
+			Expect(arg3).To(Equal(sms.MessageAttributes{}))
+		})
+
+		Context("when SMS delivery attributes are configured", func() {
+			BeforeEach(func() {
+				attributesConfig := config
+				attributesConfig.Source.SenderID = "Concourse"
+				attributesConfig.Params.SMSType = models.SMSTypeTransactional
+				attributesConfig.Params.MaxPrice = "0.50"
+				app = application.NewApplication(client, attributesConfig)
+			})
+
+			It("should publish the message with the attributes", func() {
+				Expect(runAppErr).NotTo(HaveOccurred())
+				_, _, arg3 := client.PublishMessageArgsForCall(0)
+				Expect(arg3).To(Equal(sms.MessageAttributes{
+					SenderID: "Concourse",
+					SMSType:  "Transactional",
+					MaxPrice: "0.50",
+				}))
+			})
+		})
+
+		Context("when the message does not fit in a single segment", func() {
+			var (
+				longMessage    string
+				overflowConfig models.SMSConfig
+			)
+
+			BeforeEach(func() {
+				longMessage = strings.Repeat("a", 200)
+				overflowConfig = config
+				overflowConfig.Params.Message = longMessage
+				app = application.NewApplication(client, overflowConfig)
+			})
+
+			It("should publish the message as is by default", func() {
+				Expect(runAppErr).NotTo(HaveOccurred())
+				Expect(client.PublishMessageCallCount()).To(Equal(1))
+				_, arg2, _ := client.PublishMessageArgsForCall(0)
+				Expect(arg2).To(Equal(longMessage))
+				Expect(result.Metadata).To(ContainElement(sms.MetadataItem{Name: "segments", Value: "2"}))
+			})
+
+			Context("when overflow is truncate", func() {
+				BeforeEach(func() {
+					overflowConfig.Params.Overflow = models.OverflowTruncate
+					app = application.NewApplication(client, overflowConfig)
+				})
+
+				It("should publish the message cut to a single segment", func() {
+					Expect(runAppErr).NotTo(HaveOccurred())
+					Expect(client.PublishMessageCallCount()).To(Equal(1))
+					_, arg2, _ := client.PublishMessageArgsForCall(0)
+					Expect(arg2).To(Equal(strings.Repeat("a", 157) + "..."))
+					Expect(result.Metadata).To(ContainElement(sms.MetadataItem{Name: "segments", Value: "1"}))
+				})
+			})
+
+			Context("when overflow is split", func() {
+				BeforeEach(func() {
+					overflowConfig.Params.Overflow = models.OverflowSplit
+					app = application.NewApplication(client, overflowConfig)
+				})
+
+				It("should publish each part in order", func() {
+					Expect(runAppErr).NotTo(HaveOccurred())
+					Expect(client.PublishMessageCallCount()).To(Equal(2))
+					_, arg2, _ := client.PublishMessageArgsForCall(0)
+					Expect(arg2).To(HavePrefix("(1/2) "))
+					_, arg2, _ = client.PublishMessageArgsForCall(1)
+					Expect(arg2).To(HavePrefix("(2/2) "))
+					Expect(result.Metadata).To(ContainElement(sms.MetadataItem{Name: "segments", Value: "2"}))
+				})
+
+				Context("when the mode is direct", func() {
+					BeforeEach(func() {
+						overflowConfig.Source.Mode = models.ModeDirect
+						app = application.NewApplication(client, overflowConfig)
+					})
+
+					It("should publish each part in order to each subscriber", func() {
+						Expect(runAppErr).NotTo(HaveOccurred())
+						Expect(client.PublishToPhoneCallCount()).To(Equal(4))
+						arg1, arg2, _ := client.PublishToPhoneArgsForCall(0)
+						Expect(arg1).To(Equal("subscriber1"))
+						Expect(arg2).To(HavePrefix("(1/2) "))
+						arg1, arg2, _ = client.PublishToPhoneArgsForCall(1)
+						Expect(arg1).To(Equal("subscriber1"))
+						Expect(arg2).To(HavePrefix("(2/2) "))
+						arg1, arg2, _ = client.PublishToPhoneArgsForCall(2)
+						Expect(arg1).To(Equal("subscriber2"))
+						Expect(arg2).To(HavePrefix("(1/2) "))
+					})
+				})
+			})
+
+			Context("when overflow is fail", func() {
+				BeforeEach(func() {
+					overflowConfig.Params.Overflow = models.OverflowFail
+					app = application.NewApplication(client, overflowConfig)
+				})
+
+				It("should return an error before making any calls", func() {
+					Expect(runAppErr).To(MatchError(`message is 2 GSM-7 segments long and params.overflow is "fail"`))
+					Expect(client.Invocations()).To(BeEmpty())
+				})
+			})
+
+			Context("when the message needs UCS-2 encoding", func() {
+				BeforeEach(func() {
+					overflowConfig.Params.Message = "build ✅"
+					app = application.NewApplication(client, overflowConfig)
+				})
+
+				It("should report the encoding", func() {
+					Expect(runAppErr).NotTo(HaveOccurred())
+					Expect(result.Metadata).To(ContainElement(sms.MetadataItem{Name: "encoding", Value: "UCS-2"}))
+				})
+			})
+		})
+
+		It("should not publish to phone numbers directly", func() {
+			Expect(runAppErr).NotTo(HaveOccurred())
+			Expect(client.PublishToPhoneCallCount()).To(Equal(0))
+		})
+
+		Context("when the mode is direct", func() {
+			BeforeEach(func() {
+				directConfig := config
+				directConfig.Source.Mode = models.ModeDirect
+				client.PublishToPhoneStub = func(phoneNumber string, message string, attributes sms.MessageAttributes) (string, error) {
+					return phoneNumber + "-message-id", nil
+				}
+				app = application.NewApplication(client, directConfig)
+			})
+
+			It("should not use a topic", func() {
+				Expect(runAppErr).NotTo(HaveOccurred())
+				Expect(client.CreateTopicCallCount()).To(Equal(0))
+				Expect(client.GetExistingSubscribersCallCount()).To(Equal(0))
+				Expect(client.CreateNewSubscriptionsCallCount()).To(Equal(0))
+				Expect(client.PublishMessageCallCount()).To(Equal(0))
+			})
+
+			It("should publish the message to each subscriber", func() {
+				Expect(runAppErr).NotTo(HaveOccurred())
+				Expect(client.PublishToPhoneCallCount()).To(Equal(2))
+				arg1, arg2, _ := client.PublishToPhoneArgsForCall(0)
+				Expect(arg1).To(Equal("subscriber1"))
+				Expect(arg2).To(Equal("hello"))
+				arg1, arg2, _ = client.PublishToPhoneArgsForCall(1)
+				Expect(arg1).To(Equal("subscriber2"))
+				Expect(arg2).To(Equal("hello"))
+			})
+
+			It("should report the result for each subscriber", func() {
+				Expect(runAppErr).NotTo(HaveOccurred())
+				Expect(result.Metadata).To(Equal([]sms.MetadataItem{
+					{Name: "message_id", Value: "subscriber1-message-id,subscriber2-message-id"},
+					{Name: "recipient_count", Value: "2"},
+					{Name: "subscriber1", Value: "sent"},
+					{Name: "subscriber2", Value: "sent"},
+					{Name: "encoding", Value: "GSM-7"},
+					{Name: "segments", Value: "1"},
+				}))
+			})
+
+			It("should return a message ID for each subscriber", func() {
+				Expect(runAppErr).NotTo(HaveOccurred())
+				Expect(result.TopicArn).To(BeEmpty())
+				Expect(result.MessageIDs).To(Equal([]string{"subscriber1-message-id", "subscriber2-message-id"}))
+			})
+
+			Context("when the subscribers are phone numbers", func() {
+				BeforeEach(func() {
+					directConfig := config
+					directConfig.Source.Mode = models.ModeDirect
+					directConfig.Params.Subscribers = []string{"+14151234567"}
+					app = application.NewApplication(client, directConfig)
+				})
+
+				It("should mask the numbers in the metadata", func() {
+					Expect(runAppErr).NotTo(HaveOccurred())
+					Expect(result.Metadata).To(ContainElement(sms.MetadataItem{Name: "+*******4567", Value: "sent"}))
+				})
+			})
+
+			Context("when a dry run is requested", func() {
+				BeforeEach(func() {
+					dryRunConfig := config
+					dryRunConfig.Source.Mode = models.ModeDirect
+					dryRunConfig.Params.DryRun = true
+					app = application.NewApplication(client, dryRunConfig)
+				})
+
+				It("should not publish to any subscriber", func() {
+					Expect(runAppErr).NotTo(HaveOccurred())
+					Expect(client.PublishToPhoneCallCount()).To(Equal(0))
+					Expect(client.Invocations()).To(HaveLen(1))
+					Expect(client.Invocations()).To(HaveKey("IsOptedOut"))
+					Expect(result.Metadata).To(Equal([]sms.MetadataItem{
+						{Name: "dry_run", Value: "true"},
+						{Name: "recipient_count", Value: "2"},
+						{Name: "recipients", Value: "subscriber1,subscriber2"},
+						{Name: "message", Value: "hello"},
+						{Name: "encoding", Value: "GSM-7"},
+						{Name: "segments", Value: "1"},
+					}))
+				})
+			})
+
+			Context("when publishing to a subscriber fails", func() {
+				BeforeEach(func() {
+					client.PublishToPhoneStub = func(phoneNumber string, message string, attributes sms.MessageAttributes) (string, error) {
+						if phoneNumber == "subscriber1" {
+							return "", errors.New("error publishing message to subscriber1: invalid parameter")
+						}
+						return phoneNumber + "-message-id", nil
+					}
+				})
+
+				It("should still publish to the remaining subscribers", func() {
+					Expect(client.PublishToPhoneCallCount()).To(Equal(2))
+					Expect(result.Metadata).To(Equal([]sms.MetadataItem{
+						{Name: "message_id", Value: "subscriber2-message-id"},
+						{Name: "recipient_count", Value: "1"},
+						{Name: "subscriber1", Value: "failed"},
+						{Name: "subscriber2", Value: "sent"},
+						{Name: "encoding", Value: "GSM-7"},
+						{Name: "segments", Value: "1"},
+					}))
+				})
+
+				It("should return an error listing the failed subscribers", func() {
+					Expect(runAppErr).To(MatchError("failed to send message to 1 of 2 subscribers:\nerror publishing message to subscriber1: invalid parameter"))
+				})
+			})
+		})
+
+		Context("when duplicates are suppressed", func() {
+			var (
+				state        *applicationfakes.FakeStateStore
+				dedupeConfig models.SMSConfig
+			)
+
+			BeforeEach(func() {
+				state = new(applicationfakes.FakeStateStore)
+				dedupeConfig = config
+				dedupeConfig.Params.DedupeWindow = models.Duration(time.Hour)
+				app = application.NewApplication(client, dedupeConfig).WithStateStore(state)
+			})
+
+			It("should send the message and record when it was sent", func() {
+				Expect(runAppErr).NotTo(HaveOccurred())
+				Expect(result.Suppressed).To(BeFalse())
+				Expect(client.PublishMessageCallCount()).To(Equal(1))
+
+				Expect(state.PutCallCount()).To(Equal(1))
+				key, value := state.PutArgsForCall(0)
+				getKey := state.GetArgsForCall(0)
+				Expect(key).To(Equal(getKey))
+				Expect(key).To(MatchRegexp(`^dedupe/[0-9a-f]{64}$`))
+				Expect(string(value)).To(ContainSubstring(`"message_ids":["message-id"]`))
+			})
+
+			Context("when the same message was sent within the window", func() {
+				BeforeEach(func() {
+					state.GetReturns([]byte(`{"sent_at":"`+time.Now().Add(-30*time.Minute).UTC().Format(time.RFC3339)+`"}`), true, nil)
+				})
+
+				It("should not send it again and report it as suppressed", func() {
+					Expect(runAppErr).NotTo(HaveOccurred())
+					Expect(result.Suppressed).To(BeTrue())
+					Expect(client.Invocations()).To(BeEmpty())
+					Expect(state.PutCallCount()).To(Equal(0))
+					Expect(result.Metadata).To(ContainElement(sms.MetadataItem{Name: "suppressed_duplicate", Value: "true"}))
+				})
+			})
+
+			Context("when the same message was sent before the window", func() {
+				BeforeEach(func() {
+					state.GetReturns([]byte(`{"sent_at":"`+time.Now().Add(-2*time.Hour).UTC().Format(time.RFC3339)+`"}`), true, nil)
+				})
+
+				It("should send it again", func() {
+					Expect(runAppErr).NotTo(HaveOccurred())
+					Expect(result.Suppressed).To(BeFalse())
+					Expect(client.PublishMessageCallCount()).To(Equal(1))
+					Expect(state.PutCallCount()).To(Equal(1))
+				})
+			})
+
+			Context("when a different message is sent", func() {
+				It("should use a different key", func() {
+					otherConfig := dedupeConfig
+					otherConfig.Params.Message = "goodbye"
+					_, err := application.NewApplication(client, otherConfig).WithStateStore(state).Run()
+					Expect(err).NotTo(HaveOccurred())
+					Expect(state.GetArgsForCall(1)).NotTo(Equal(state.GetArgsForCall(0)))
+				})
+			})
+
+			Context("when a dedupe key is given", func() {
+				It("should use the same key for different messages", func() {
+					keyedConfig := dedupeConfig
+					keyedConfig.Params.DedupeKey = "build-failed"
+					_, err := application.NewApplication(client, keyedConfig).WithStateStore(state).Run()
+					Expect(err).NotTo(HaveOccurred())
+
+					keyedConfig.Params.Message = "goodbye"
+					_, err = application.NewApplication(client, keyedConfig).WithStateStore(state).Run()
+					Expect(err).NotTo(HaveOccurred())
+					Expect(state.GetArgsForCall(2)).To(Equal(state.GetArgsForCall(1)))
+				})
+			})
+
+			Context("when the state cannot be read", func() {
+				BeforeEach(func() {
+					state.GetReturns(nil, false, errors.New("error getting state dedupe/abc from S3: AccessDenied"))
+				})
+
+				It("should not send anything", func() {
+					Expect(runAppErr).To(MatchError("error getting state dedupe/abc from S3: AccessDenied"))
+					Expect(client.PublishMessageCallCount()).To(Equal(0))
+				})
+			})
+
+			Context("when the sent message cannot be recorded", func() {
+				BeforeEach(func() {
+					state.PutReturns(errors.New("error putting state dedupe/abc to S3: AccessDenied"))
+				})
+
+				It("should return an error", func() {
+					Expect(runAppErr).To(MatchError("the message was sent, but recording it for params.dedupe_window failed: error putting state dedupe/abc to S3: AccessDenied"))
+					Expect(client.PublishMessageCallCount()).To(Equal(1))
+				})
+			})
+
+			Context("when a dry run is requested", func() {
+				BeforeEach(func() {
+					dedupeConfig.Params.DryRun = true
+					app = application.NewApplication(client, dedupeConfig).WithStateStore(state)
+				})
+
+				It("should check for a duplicate without recording anything", func() {
+					Expect(runAppErr).NotTo(HaveOccurred())
+					Expect(state.GetCallCount()).To(Equal(1))
+					Expect(state.PutCallCount()).To(Equal(0))
+				})
+			})
+		})
+
+		Context("when a subscriber is in their quiet hours", func() {
+			var quietConfig models.SMSConfig
+
+			BeforeEach(func() {
+				utc := time.Now().UTC()
+				quietConfig = config
+				quietConfig.Source.Mode = models.ModeDirect
+				quietConfig.Params.Subscribers = []string{"+14151234567", "+16505550123"}
+				quietConfig.Source.Contacts = []models.Contact{
+					{
+						Number:   "+14151234567",
+						TimeZone: "UTC",
+						QuietHours: models.QuietHours{
+							Start: utc.Add(-time.Hour).Format("15:04"),
+							End:   utc.Add(time.Hour).Format("15:04"),
+						},
+					},
+					{Number: "+16505550123", TimeZone: "America/Los_Angeles"},
+				}
+				app = application.NewApplication(client, quietConfig)
+			})
+
+			It("should not send a non-critical message to them and report them as suppressed", func() {
+				Expect(runAppErr).NotTo(HaveOccurred())
+				Expect(client.PublishToPhoneCallCount()).To(Equal(1))
+				phoneNumber, _, _ := client.PublishToPhoneArgsForCall(0)
+				Expect(phoneNumber).To(Equal("+16505550123"))
+				Expect(result.Recipients).To(Equal([]string{"+16505550123"}))
+				Expect(result.Metadata).To(ContainElement(sms.MetadataItem{Name: "suppressed", Value: "+*******4567"}))
+			})
+
+			Context("when the message is critical", func() {
+				BeforeEach(func() {
+					quietConfig.Params.Severity = models.SeverityCritical
+					app = application.NewApplication(client, quietConfig)
+				})
+
+				It("should send it to every subscriber", func() {
+					Expect(runAppErr).NotTo(HaveOccurred())
+					Expect(client.PublishToPhoneCallCount()).To(Equal(2))
+					for _, item := range result.Metadata {
+						Expect(item.Name).NotTo(Equal("suppressed"))
+					}
+				})
+			})
+
+			Context("when a dry run is requested", func() {
+				BeforeEach(func() {
+					quietConfig.Params.DryRunOffline = true
+					app = application.NewApplication(client, quietConfig)
+				})
+
+				It("should leave them out of the recipients", func() {
+					Expect(runAppErr).NotTo(HaveOccurred())
+					Expect(result.Metadata).To(ContainElement(sms.MetadataItem{Name: "recipients", Value: "+*******0123"}))
+					Expect(result.Metadata).To(ContainElement(sms.MetadataItem{Name: "suppressed", Value: "+*******4567"}))
+				})
+			})
+		})
+
+		Context("when there is a chain of providers", func() {
+			var (
+				fallback     *applicationfakes.FakeSMSService
+				lastFallback *applicationfakes.FakeSMSService
+				chainConfig  models.SMSConfig
+			)
+
+			newChainApplication := func() application.Application {
+				return application.NewFailoverApplication([]application.Provider{
+					{Name: "sns:us-east-1", Service: client},
+					{Name: "sns:us-west-2", Service: fallback},
+					{Name: "twilio:AC123", Service: lastFallback},
+				}, chainConfig)
+			}
+
+			BeforeEach(func() {
+				chainConfig = config
+				chainConfig.Source.Mode = models.ModeDirect
+				client.PublishToPhoneStub = func(phoneNumber string, message string, attributes sms.MessageAttributes) (string, error) {
+					if phoneNumber == "subscriber1" {
+						return "", errors.New("error publishing message to subscriber1: service unavailable")
+					}
+					return phoneNumber + "-primary-id", nil
+				}
+
+				fallback = new(applicationfakes.FakeSMSService)
+				fallback.PublishToPhoneStub = func(phoneNumber string, message string, attributes sms.MessageAttributes) (string, error) {
+					return phoneNumber + "-fallback-id", nil
+				}
+
+				lastFallback = new(applicationfakes.FakeSMSService)
+				app = newChainApplication()
+			})
+
+			It("should send through the next provider only to the subscribers the first one failed", func() {
+				Expect(runAppErr).NotTo(HaveOccurred())
+				Expect(client.PublishToPhoneCallCount()).To(Equal(2))
+				Expect(fallback.PublishToPhoneCallCount()).To(Equal(1))
+				phoneNumber, _, _ := fallback.PublishToPhoneArgsForCall(0)
+				Expect(phoneNumber).To(Equal("subscriber1"))
+				Expect(lastFallback.PublishToPhoneCallCount()).To(Equal(0))
+			})
+
+			It("should report the provider that sent to each subscriber", func() {
+				Expect(result.MessageIDs).To(Equal([]string{"subscriber1-fallback-id", "subscriber2-primary-id"}))
+				Expect(result.Metadata).To(ContainElement(sms.MetadataItem{Name: "subscriber1", Value: "sent via sns:us-west-2"}))
+				Expect(result.Metadata).To(ContainElement(sms.MetadataItem{Name: "subscriber2", Value: "sent via sns:us-east-1"}))
+			})
+
+			It("should only check opt-outs with the first provider", func() {
+				Expect(client.IsOptedOutCallCount()).To(Equal(2))
+				Expect(fallback.IsOptedOutCallCount()).To(Equal(0))
+			})
+
+			Context("when the first provider cannot check opt-outs", func() {
+				BeforeEach(func() {
+					client.IsOptedOutReturns(false, errors.New("error checking whether subscriber1 opted out: ServiceUnavailable"))
+				})
+
+				It("should report the numbers as unchecked and send through the next provider", func() {
+					Expect(runAppErr).NotTo(HaveOccurred())
+					Expect(client.IsOptedOutCallCount()).To(Equal(1))
+					Expect(fallback.PublishToPhoneCallCount()).To(Equal(1))
+					Expect(result.Metadata).To(ContainElement(sms.MetadataItem{Name: "opt_out_unchecked", Value: "subscriber1,subscriber2"}))
+					Expect(result.Metadata).To(ContainElement(sms.MetadataItem{Name: "subscriber1", Value: "sent via sns:us-west-2"}))
+				})
+			})
+
+			Context("when a provider fails after accepting part of the message", func() {
+				BeforeEach(func() {
+					chainConfig.Params.Subscribers = []string{"subscriber2"}
+					chainConfig.Params.Message = strings.Repeat("a", 200)
+					chainConfig.Params.Overflow = models.OverflowSplit
+					client.PublishToPhoneStub = func(phoneNumber string, message string, attributes sms.MessageAttributes) (string, error) {
+						if strings.HasPrefix(message, "(2/2)") {
+							return "", errors.New("error publishing message to subscriber2: throttled")
+						}
+						return "part-1-id", nil
+					}
+					app = newChainApplication()
+				})
+
+				It("should only send the remaining parts through the next provider", func() {
+					Expect(runAppErr).NotTo(HaveOccurred())
+					Expect(client.PublishToPhoneCallCount()).To(Equal(2))
+					Expect(fallback.PublishToPhoneCallCount()).To(Equal(1))
+					_, message, _ := fallback.PublishToPhoneArgsForCall(0)
+					Expect(message).To(HavePrefix("(2/2)"))
+					Expect(result.Metadata).To(ContainElement(sms.MetadataItem{Name: "subscriber2", Value: "sent via sns:us-east-1,sns:us-west-2"}))
+				})
+			})
+
+			Context("when a provider may have accepted a message before failing", func() {
+				BeforeEach(func() {
+					client.PublishToPhoneStub = func(phoneNumber string, message string, attributes sms.MessageAttributes) (string, error) {
+						if phoneNumber == "subscriber1" {
+							return "", sms.MaybeSentError{Err: errors.New("error publishing message to subscriber1: connection reset")}
+						}
+						return phoneNumber + "-primary-id", nil
+					}
+				})
+
+				It("should not send it through the next provider", func() {
+					Expect(fallback.PublishToPhoneCallCount()).To(Equal(0))
+					Expect(runAppErr).To(MatchError("failed to send message to 1 of 2 subscribers:\n" +
+						"error publishing message to subscriber1: connection reset (the message may have been sent)"))
+					Expect(result.Metadata).To(ContainElement(sms.MetadataItem{Name: "subscriber1", Value: "maybe sent via sns:us-east-1"}))
+				})
+			})
+
+			Context("when a provider accepts a message without reporting its message ID", func() {
+				BeforeEach(func() {
+					fallback.PublishToPhoneStub = func(phoneNumber string, message string, attributes sms.MessageAttributes) (string, error) {
+						return "", sms.MessageIDError{PhoneNumber: phoneNumber, Err: errors.New(`webhook response: "id" not found`)}
+					}
+				})
+
+				It("should count it as sent without trying the next provider", func() {
+					Expect(runAppErr).NotTo(HaveOccurred())
+					Expect(lastFallback.PublishToPhoneCallCount()).To(Equal(0))
+					Expect(result.MessageIDs).To(Equal([]string{"subscriber2-primary-id"}))
+					Expect(result.Metadata).To(ContainElement(sms.MetadataItem{Name: "subscriber1", Value: "sent via sns:us-west-2 (message ID unknown)"}))
+					Expect(result.Metadata).To(ContainElement(sms.MetadataItem{Name: "recipient_count", Value: "2"}))
+				})
+			})
+
+			Context("when every provider fails", func() {
+				BeforeEach(func() {
+					fallback.PublishToPhoneReturns("", errors.New("error publishing message to subscriber1: throttled"))
+					lastFallback.PublishToPhoneReturns("", errors.New("error publishing message to subscriber1: invalid number"))
+				})
+
+				It("should return the error from each provider", func() {
+					Expect(runAppErr).To(MatchError("failed to send message to 1 of 2 subscribers:\n" +
+						"every provider failed: sns:us-east-1: error publishing message to subscriber1: service unavailable; " +
+						"sns:us-west-2: error publishing message to subscriber1: throttled; " +
+						"twilio:AC123: error publishing message to subscriber1: invalid number"))
+					Expect(result.Metadata).To(ContainElement(sms.MetadataItem{Name: "subscriber1", Value: "failed"}))
+				})
+			})
+
+			Context("when waiting for delivery is requested", func() {
+				BeforeEach(func() {
+					chainConfig.Params.WaitForDelivery = models.Duration(time.Minute)
+					app = newChainApplication()
+				})
+
+				It("should wait for each provider to deliver the messages it sent", func() {
+					Expect(runAppErr).NotTo(HaveOccurred())
+					Expect(client.WaitForDeliveryCallCount()).To(Equal(1))
+					messageIDs, _, _, timeout := client.WaitForDeliveryArgsForCall(0)
+					Expect(messageIDs).To(Equal([]string{"subscriber2-primary-id"}))
+					Expect(timeout).To(Equal(time.Minute))
+
+					Expect(fallback.WaitForDeliveryCallCount()).To(Equal(1))
+					messageIDs, _, _, timeout = fallback.WaitForDeliveryArgsForCall(0)
+					Expect(messageIDs).To(Equal([]string{"subscriber1-fallback-id"}))
+					Expect(timeout).To(BeNumerically("<=", time.Minute))
+
+					Expect(lastFallback.WaitForDeliveryCallCount()).To(Equal(0))
+				})
+			})
+		})
+	})
+
+	Describe("WaitForAck", func() {
+		var (
+			listener *applicationfakes.FakeReplyListener
+			sentAt   time.Time
+			batches  [][]sms.Reply
+			reply    sms.Reply
+			waitErr  error
+		)
+
+		BeforeEach(func() {
+			client = new(applicationfakes.FakeSMSService)
+			listener = new(applicationfakes.FakeReplyListener)
+			sentAt = time.Now()
+			batches = [][]sms.Reply{}
+			listener.ReceiveStub = func(wait time.Duration) ([]sms.Reply, error) {
+				if len(batches) == 0 {
+					time.Sleep(time.Millisecond)
+					return []sms.Reply{}, nil
+				}
+				batch := batches[0]
+				batches = batches[1:]
+				return batch, nil
+			}
+
+			ackConfig := config
+			ackConfig.Source.InboundSenders = []string{"+14151234567", "16505550123"}
+			app = application.NewApplication(client, ackConfig)
+		})
+
+		JustBeforeEach(func() {
+			reply, waitErr = app.WaitForAck(listener, "4821", sentAt, 50*time.Millisecond)
+		})
+
+		Context("when an authorized number acknowledges the code", func() {
+			BeforeEach(func() {
+				batches = [][]sms.Reply{
+					{{ID: "reply-1", Sender: "+14151234567", Body: "DEPLOY prod", Timestamp: sentAt}},
+					{
+						{ID: "reply-2", Sender: "+14155550000", Body: "ACK 4821", Timestamp: sentAt},
+						{ID: "reply-3", Sender: "+14151234567", Body: "ACK 1234", Timestamp: sentAt},
+						{ID: "reply-4", Sender: "+16505550123", Body: " ack 4821 ", Timestamp: sentAt},
+					},
+				}
+			})
+
+			It("should return the acknowledgement", func() {
+				Expect(waitErr).NotTo(HaveOccurred())
+				Expect(reply.ID).To(Equal("reply-4"))
+				Expect(listener.ReceiveCallCount()).To(Equal(2))
+			})
+
+			It("should only delete the acknowledgement from the queue", func() {
+				Expect(waitErr).NotTo(HaveOccurred())
+				Expect(listener.DeleteCallCount()).To(Equal(1))
+				Expect(listener.DeleteArgsForCall(0)).To(Equal([]sms.Reply{reply}))
+			})
+
+			It("should wait no longer than the time left", func() {
+				Expect(listener.ReceiveArgsForCall(0)).To(BeNumerically("<=", 50*time.Millisecond))
+				Expect(listener.ReceiveArgsForCall(0)).To(BeNumerically(">", 0))
+			})
+		})
+
+		Context("when an authorized number refuses the code", func() {
+			BeforeEach(func() {
+				batches = [][]sms.Reply{
+					{{ID: "reply-1", Sender: "+14151234567", Body: "NACK 4821", Timestamp: sentAt}},
+				}
+			})
+
+			It("should return an error", func() {
+				Expect(waitErr).To(MatchError("message with code 4821 was refused by +*******4567"))
+				Expect(reply.ID).To(Equal("reply-1"))
+				Expect(listener.DeleteCallCount()).To(Equal(1))
+			})
+		})
+
+		Context("when the acknowledgement was sent before the message", func() {
+			BeforeEach(func() {
+				batches = [][]sms.Reply{
+					{{ID: "reply-1", Sender: "+14151234567", Body: "ACK 4821", Timestamp: sentAt.Add(-time.Hour)}},
+				}
+			})
+
+			It("should time out", func() {
+				Expect(waitErr).To(MatchError("no acknowledgement with code 4821 was received within 50ms"))
+				Expect(listener.DeleteCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when no acknowledgement arrives in time", func() {
+			It("should return an error", func() {
+				Expect(waitErr).To(MatchError("no acknowledgement with code 4821 was received within 50ms"))
+				Expect(listener.DeleteCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when receiving replies fails", func() {
+			BeforeEach(func() {
+				listener.ReceiveStub = nil
+				listener.ReceiveReturns(nil, errors.New("error receiving messages from queue-url: access denied"))
+			})
+
+			It("should return the error", func() {
+				Expect(waitErr).To(MatchError("error receiving messages from queue-url: access denied"))
+			})
+		})
+
+		Context("when deleting the acknowledgement fails", func() {
+			BeforeEach(func() {
+				batches = [][]sms.Reply{
+					{{ID: "reply-1", Sender: "+14151234567", Body: "ACK 4821", Timestamp: sentAt}},
+				}
+				listener.DeleteReturns(errors.New("error deleting messages from queue-url: access denied"))
+			})
+
+			It("should return the error", func() {
+				Expect(waitErr).To(MatchError("error deleting messages from queue-url: access denied"))
+			})
+		})
+
+		Context("when no senders are authorized", func() {
+			BeforeEach(func() {
+				app = application.NewApplication(client, config)
+			})
+
+			It("should return an error without listening", func() {
+				Expect(waitErr).To(MatchError("source.inbound_senders from stdin is either empty or missing"))
+				Expect(listener.ReceiveCallCount()).To(Equal(0))
+			})
 		})
 	})
 })
@@ -0,0 +1,120 @@
+// This file was generated by counterfeiter
+package applicationfakes
+
+import (
+	"sync"
+	"time"
+
+	"github.com/nickwei84/sms-resource/lib/sms"
+	"github.com/nickwei84/sms-resource/out/application"
+)
+
+type FakeReplyListener struct {
+	ReceiveStub        func(wait time.Duration) ([]sms.Reply, error)
+	receiveMutex       sync.RWMutex
+	receiveArgsForCall []struct {
+		wait time.Duration
+	}
+	receiveReturns struct {
+		result1 []sms.Reply
+		result2 error
+	}
+	DeleteStub        func(replies []sms.Reply) error
+	deleteMutex       sync.RWMutex
+	deleteArgsForCall []struct {
+		replies []sms.Reply
+	}
+	deleteReturns struct {
+		result1 error
+	}
+	invocations map[string][][]interface{}
+}
+
+func (fake *FakeReplyListener) Receive(wait time.Duration) ([]sms.Reply, error) {
+	fake.receiveMutex.Lock()
+	fake.receiveArgsForCall = append(fake.receiveArgsForCall, struct {
+		wait time.Duration
+	}{wait})
+	fake.guard("Receive")
+	fake.invocations["Receive"] = append(fake.invocations["Receive"], []interface{}{wait})
+	fake.receiveMutex.Unlock()
+	if fake.ReceiveStub != nil {
+		return fake.ReceiveStub(wait)
+	} else {
+		return fake.receiveReturns.result1, fake.receiveReturns.result2
+	}
+}
+
+func (fake *FakeReplyListener) ReceiveCallCount() int {
+	fake.receiveMutex.RLock()
+	defer fake.receiveMutex.RUnlock()
+	return len(fake.receiveArgsForCall)
+}
+
+func (fake *FakeReplyListener) ReceiveArgsForCall(i int) time.Duration {
+	fake.receiveMutex.RLock()
+	defer fake.receiveMutex.RUnlock()
+	return fake.receiveArgsForCall[i].wait
+}
+
+func (fake *FakeReplyListener) ReceiveReturns(result1 []sms.Reply, result2 error) {
+	fake.ReceiveStub = nil
+	fake.receiveReturns = struct {
+		result1 []sms.Reply
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeReplyListener) Delete(replies []sms.Reply) error {
+	var repliesCopy []sms.Reply
+	if replies != nil {
+		repliesCopy = make([]sms.Reply, len(replies))
+		copy(repliesCopy, replies)
+	}
+	fake.deleteMutex.Lock()
+	fake.deleteArgsForCall = append(fake.deleteArgsForCall, struct {
+		replies []sms.Reply
+	}{repliesCopy})
+	fake.guard("Delete")
+	fake.invocations["Delete"] = append(fake.invocations["Delete"], []interface{}{repliesCopy})
+	fake.deleteMutex.Unlock()
+	if fake.DeleteStub != nil {
+		return fake.DeleteStub(replies)
+	} else {
+		return fake.deleteReturns.result1
+	}
+}
+
+func (fake *FakeReplyListener) DeleteCallCount() int {
+	fake.deleteMutex.RLock()
+	defer fake.deleteMutex.RUnlock()
+	return len(fake.deleteArgsForCall)
+}
+
+func (fake *FakeReplyListener) DeleteArgsForCall(i int) []sms.Reply {
+	fake.deleteMutex.RLock()
+	defer fake.deleteMutex.RUnlock()
+	return fake.deleteArgsForCall[i].replies
+}
+
+func (fake *FakeReplyListener) DeleteReturns(result1 error) {
+	fake.DeleteStub = nil
+	fake.deleteReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeReplyListener) Invocations() map[string][][]interface{} {
+	return fake.invocations
+}
+
+func (fake *FakeReplyListener) guard(key string) {
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+}
+
+var _ application.ReplyListener = new(FakeReplyListener)
@@ -3,7 +3,9 @@ package applicationfakes
 
 import (
 	"sync"
+	"time"
 
+	"github.com/nickwei84/sms-resource/lib/sms"
 	"github.com/nickwei84/sms-resource/out/application"
 )
 
@@ -17,33 +19,93 @@ type FakeSMSService struct {
 		result1 string
 		result2 error
 	}
-	GetExistingSubscribersStub        func(topicID string) ([]string, error)
+	FindTopicStub        func(topic string) (string, error)
+	findTopicMutex       sync.RWMutex
+	findTopicArgsForCall []struct {
+		topic string
+	}
+	findTopicReturns struct {
+		result1 string
+		result2 error
+	}
+	GetExistingSubscribersStub        func(topicID string) ([]sms.Subscription, error)
 	getExistingSubscribersMutex       sync.RWMutex
 	getExistingSubscribersArgsForCall []struct {
 		topicID string
 	}
 	getExistingSubscribersReturns struct {
-		result1 []string
+		result1 []sms.Subscription
 		result2 error
 	}
-	CreateNewSubscriptionsStub        func(topicID string, newSubscribers []string) error
+	CreateNewSubscriptionsStub        func(topicID string, newSubscribers []string) ([]sms.Subscription, error)
 	createNewSubscriptionsMutex       sync.RWMutex
 	createNewSubscriptionsArgsForCall []struct {
 		topicID        string
 		newSubscribers []string
 	}
 	createNewSubscriptionsReturns struct {
+		result1 []sms.Subscription
+		result2 error
+	}
+	RemoveSubscriptionsStub        func(subscriptions []sms.Subscription) error
+	removeSubscriptionsMutex       sync.RWMutex
+	removeSubscriptionsArgsForCall []struct {
+		subscriptions []sms.Subscription
+	}
+	removeSubscriptionsReturns struct {
 		result1 error
 	}
-	PublishMessageStub        func(topicID string, message string) error
+	PublishMessageStub        func(topicID string, message string, attributes sms.MessageAttributes) (string, error)
 	publishMessageMutex       sync.RWMutex
 	publishMessageArgsForCall []struct {
-		topicID string
-		message string
+		topicID    string
+		message    string
+		attributes sms.MessageAttributes
 	}
 	publishMessageReturns struct {
+		result1 string
+		result2 error
+	}
+	PublishToPhoneStub        func(phoneNumber string, message string, attributes sms.MessageAttributes) (string, error)
+	publishToPhoneMutex       sync.RWMutex
+	publishToPhoneArgsForCall []struct {
+		phoneNumber string
+		message     string
+		attributes  sms.MessageAttributes
+	}
+	publishToPhoneReturns struct {
+		result1 string
+		result2 error
+	}
+	IsOptedOutStub        func(phoneNumber string) (bool, error)
+	isOptedOutMutex       sync.RWMutex
+	isOptedOutArgsForCall []struct {
+		phoneNumber string
+	}
+	isOptedOutReturns struct {
+		result1 bool
+		result2 error
+	}
+	OptInStub        func(phoneNumber string) error
+	optInMutex       sync.RWMutex
+	optInArgsForCall []struct {
+		phoneNumber string
+	}
+	optInReturns struct {
 		result1 error
 	}
+	WaitForDeliveryStub        func(messageIDs []string, perMessage int, since time.Time, timeout time.Duration) ([]sms.DeliveryStatus, error)
+	waitForDeliveryMutex       sync.RWMutex
+	waitForDeliveryArgsForCall []struct {
+		messageIDs []string
+		perMessage int
+		since      time.Time
+		timeout    time.Duration
+	}
+	waitForDeliveryReturns struct {
+		result1 []sms.DeliveryStatus
+		result2 error
+	}
 	invocations map[string][][]interface{}
 }
 
@@ -82,7 +144,42 @@ func (fake *FakeSMSService) CreateTopicReturns(result1 string, result2 error) {
 	}{result1, result2}
 }
 
-func (fake *FakeSMSService) GetExistingSubscribers(topicID string) ([]string, error) {
+func (fake *FakeSMSService) FindTopic(topic string) (string, error) {
+	fake.findTopicMutex.Lock()
+	fake.findTopicArgsForCall = append(fake.findTopicArgsForCall, struct {
+		topic string
+	}{topic})
+	fake.guard("FindTopic")
+	fake.invocations["FindTopic"] = append(fake.invocations["FindTopic"], []interface{}{topic})
+	fake.findTopicMutex.Unlock()
+	if fake.FindTopicStub != nil {
+		return fake.FindTopicStub(topic)
+	} else {
+		return fake.findTopicReturns.result1, fake.findTopicReturns.result2
+	}
+}
+
+func (fake *FakeSMSService) FindTopicCallCount() int {
+	fake.findTopicMutex.RLock()
+	defer fake.findTopicMutex.RUnlock()
+	return len(fake.findTopicArgsForCall)
+}
+
+func (fake *FakeSMSService) FindTopicArgsForCall(i int) string {
+	fake.findTopicMutex.RLock()
+	defer fake.findTopicMutex.RUnlock()
+	return fake.findTopicArgsForCall[i].topic
+}
+
+func (fake *FakeSMSService) FindTopicReturns(result1 string, result2 error) {
+	fake.FindTopicStub = nil
+	fake.findTopicReturns = struct {
+		result1 string
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeSMSService) GetExistingSubscribers(topicID string) ([]sms.Subscription, error) {
 	fake.getExistingSubscribersMutex.Lock()
 	fake.getExistingSubscribersArgsForCall = append(fake.getExistingSubscribersArgsForCall, struct {
 		topicID string
@@ -109,15 +206,15 @@ func (fake *FakeSMSService) GetExistingSubscribersArgsForCall(i int) string {
 	return fake.getExistingSubscribersArgsForCall[i].topicID
 }
 
-func (fake *FakeSMSService) GetExistingSubscribersReturns(result1 []string, result2 error) {
+func (fake *FakeSMSService) GetExistingSubscribersReturns(result1 []sms.Subscription, result2 error) {
 	fake.GetExistingSubscribersStub = nil
 	fake.getExistingSubscribersReturns = struct {
-		result1 []string
+		result1 []sms.Subscription
 		result2 error
 	}{result1, result2}
 }
 
-func (fake *FakeSMSService) CreateNewSubscriptions(topicID string, newSubscribers []string) error {
+func (fake *FakeSMSService) CreateNewSubscriptions(topicID string, newSubscribers []string) ([]sms.Subscription, error) {
 	var newSubscribersCopy []string
 	if newSubscribers != nil {
 		newSubscribersCopy = make([]string, len(newSubscribers))
@@ -134,7 +231,7 @@ func (fake *FakeSMSService) CreateNewSubscriptions(topicID string, newSubscriber
 	if fake.CreateNewSubscriptionsStub != nil {
 		return fake.CreateNewSubscriptionsStub(topicID, newSubscribers)
 	} else {
-		return fake.createNewSubscriptionsReturns.result1
+		return fake.createNewSubscriptionsReturns.result1, fake.createNewSubscriptionsReturns.result2
 	}
 }
 
@@ -150,26 +247,67 @@ func (fake *FakeSMSService) CreateNewSubscriptionsArgsForCall(i int) (string, []
 	return fake.createNewSubscriptionsArgsForCall[i].topicID, fake.createNewSubscriptionsArgsForCall[i].newSubscribers
 }
 
-func (fake *FakeSMSService) CreateNewSubscriptionsReturns(result1 error) {
+func (fake *FakeSMSService) CreateNewSubscriptionsReturns(result1 []sms.Subscription, result2 error) {
 	fake.CreateNewSubscriptionsStub = nil
 	fake.createNewSubscriptionsReturns = struct {
+		result1 []sms.Subscription
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeSMSService) RemoveSubscriptions(subscriptions []sms.Subscription) error {
+	var subscriptionsCopy []sms.Subscription
+	if subscriptions != nil {
+		subscriptionsCopy = make([]sms.Subscription, len(subscriptions))
+		copy(subscriptionsCopy, subscriptions)
+	}
+	fake.removeSubscriptionsMutex.Lock()
+	fake.removeSubscriptionsArgsForCall = append(fake.removeSubscriptionsArgsForCall, struct {
+		subscriptions []sms.Subscription
+	}{subscriptionsCopy})
+	fake.guard("RemoveSubscriptions")
+	fake.invocations["RemoveSubscriptions"] = append(fake.invocations["RemoveSubscriptions"], []interface{}{subscriptionsCopy})
+	fake.removeSubscriptionsMutex.Unlock()
+	if fake.RemoveSubscriptionsStub != nil {
+		return fake.RemoveSubscriptionsStub(subscriptions)
+	} else {
+		return fake.removeSubscriptionsReturns.result1
+	}
+}
+
+func (fake *FakeSMSService) RemoveSubscriptionsCallCount() int {
+	fake.removeSubscriptionsMutex.RLock()
+	defer fake.removeSubscriptionsMutex.RUnlock()
+	return len(fake.removeSubscriptionsArgsForCall)
+}
+
+func (fake *FakeSMSService) RemoveSubscriptionsArgsForCall(i int) []sms.Subscription {
+	fake.removeSubscriptionsMutex.RLock()
+	defer fake.removeSubscriptionsMutex.RUnlock()
+	return fake.removeSubscriptionsArgsForCall[i].subscriptions
+}
+
+func (fake *FakeSMSService) RemoveSubscriptionsReturns(result1 error) {
+	fake.RemoveSubscriptionsStub = nil
+	fake.removeSubscriptionsReturns = struct {
 		result1 error
 	}{result1}
 }
 
-func (fake *FakeSMSService) PublishMessage(topicID string, message string) error {
+func (fake *FakeSMSService) PublishMessage(topicID string, message string, attributes sms.MessageAttributes) (string, error) {
 	fake.publishMessageMutex.Lock()
 	fake.publishMessageArgsForCall = append(fake.publishMessageArgsForCall, struct {
-		topicID string
-		message string
-	}{topicID, message})
+		topicID    string
+		message    string
+		attributes sms.MessageAttributes
+	}{topicID, message, attributes})
 	fake.guard("PublishMessage")
-	fake.invocations["PublishMessage"] = append(fake.invocations["PublishMessage"], []interface{}{topicID, message})
+	fake.invocations["PublishMessage"] = append(fake.invocations["PublishMessage"], []interface{}{topicID, message, attributes})
 	fake.publishMessageMutex.Unlock()
 	if fake.PublishMessageStub != nil {
-		return fake.PublishMessageStub(topicID, message)
+		return fake.PublishMessageStub(topicID, message, attributes)
 	} else {
-		return fake.publishMessageReturns.result1
+		return fake.publishMessageReturns.result1, fake.publishMessageReturns.result2
 	}
 }
 
@@ -179,19 +317,169 @@ func (fake *FakeSMSService) PublishMessageCallCount() int {
 	return len(fake.publishMessageArgsForCall)
 }
 
-func (fake *FakeSMSService) PublishMessageArgsForCall(i int) (string, string) {
+func (fake *FakeSMSService) PublishMessageArgsForCall(i int) (string, string, sms.MessageAttributes) {
 	fake.publishMessageMutex.RLock()
 	defer fake.publishMessageMutex.RUnlock()
-	return fake.publishMessageArgsForCall[i].topicID, fake.publishMessageArgsForCall[i].message
+	return fake.publishMessageArgsForCall[i].topicID, fake.publishMessageArgsForCall[i].message, fake.publishMessageArgsForCall[i].attributes
 }
 
-func (fake *FakeSMSService) PublishMessageReturns(result1 error) {
+func (fake *FakeSMSService) PublishMessageReturns(result1 string, result2 error) {
 	fake.PublishMessageStub = nil
 	fake.publishMessageReturns = struct {
+		result1 string
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeSMSService) PublishToPhone(phoneNumber string, message string, attributes sms.MessageAttributes) (string, error) {
+	fake.publishToPhoneMutex.Lock()
+	fake.publishToPhoneArgsForCall = append(fake.publishToPhoneArgsForCall, struct {
+		phoneNumber string
+		message     string
+		attributes  sms.MessageAttributes
+	}{phoneNumber, message, attributes})
+	fake.guard("PublishToPhone")
+	fake.invocations["PublishToPhone"] = append(fake.invocations["PublishToPhone"], []interface{}{phoneNumber, message, attributes})
+	fake.publishToPhoneMutex.Unlock()
+	if fake.PublishToPhoneStub != nil {
+		return fake.PublishToPhoneStub(phoneNumber, message, attributes)
+	} else {
+		return fake.publishToPhoneReturns.result1, fake.publishToPhoneReturns.result2
+	}
+}
+
+func (fake *FakeSMSService) PublishToPhoneCallCount() int {
+	fake.publishToPhoneMutex.RLock()
+	defer fake.publishToPhoneMutex.RUnlock()
+	return len(fake.publishToPhoneArgsForCall)
+}
+
+func (fake *FakeSMSService) PublishToPhoneArgsForCall(i int) (string, string, sms.MessageAttributes) {
+	fake.publishToPhoneMutex.RLock()
+	defer fake.publishToPhoneMutex.RUnlock()
+	return fake.publishToPhoneArgsForCall[i].phoneNumber, fake.publishToPhoneArgsForCall[i].message, fake.publishToPhoneArgsForCall[i].attributes
+}
+
+func (fake *FakeSMSService) PublishToPhoneReturns(result1 string, result2 error) {
+	fake.PublishToPhoneStub = nil
+	fake.publishToPhoneReturns = struct {
+		result1 string
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeSMSService) IsOptedOut(phoneNumber string) (bool, error) {
+	fake.isOptedOutMutex.Lock()
+	fake.isOptedOutArgsForCall = append(fake.isOptedOutArgsForCall, struct {
+		phoneNumber string
+	}{phoneNumber})
+	fake.guard("IsOptedOut")
+	fake.invocations["IsOptedOut"] = append(fake.invocations["IsOptedOut"], []interface{}{phoneNumber})
+	fake.isOptedOutMutex.Unlock()
+	if fake.IsOptedOutStub != nil {
+		return fake.IsOptedOutStub(phoneNumber)
+	} else {
+		return fake.isOptedOutReturns.result1, fake.isOptedOutReturns.result2
+	}
+}
+
+func (fake *FakeSMSService) IsOptedOutCallCount() int {
+	fake.isOptedOutMutex.RLock()
+	defer fake.isOptedOutMutex.RUnlock()
+	return len(fake.isOptedOutArgsForCall)
+}
+
+func (fake *FakeSMSService) IsOptedOutArgsForCall(i int) string {
+	fake.isOptedOutMutex.RLock()
+	defer fake.isOptedOutMutex.RUnlock()
+	return fake.isOptedOutArgsForCall[i].phoneNumber
+}
+
+func (fake *FakeSMSService) IsOptedOutReturns(result1 bool, result2 error) {
+	fake.IsOptedOutStub = nil
+	fake.isOptedOutReturns = struct {
+		result1 bool
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeSMSService) OptIn(phoneNumber string) error {
+	fake.optInMutex.Lock()
+	fake.optInArgsForCall = append(fake.optInArgsForCall, struct {
+		phoneNumber string
+	}{phoneNumber})
+	fake.guard("OptIn")
+	fake.invocations["OptIn"] = append(fake.invocations["OptIn"], []interface{}{phoneNumber})
+	fake.optInMutex.Unlock()
+	if fake.OptInStub != nil {
+		return fake.OptInStub(phoneNumber)
+	} else {
+		return fake.optInReturns.result1
+	}
+}
+
+func (fake *FakeSMSService) OptInCallCount() int {
+	fake.optInMutex.RLock()
+	defer fake.optInMutex.RUnlock()
+	return len(fake.optInArgsForCall)
+}
+
+func (fake *FakeSMSService) OptInArgsForCall(i int) string {
+	fake.optInMutex.RLock()
+	defer fake.optInMutex.RUnlock()
+	return fake.optInArgsForCall[i].phoneNumber
+}
+
+func (fake *FakeSMSService) OptInReturns(result1 error) {
+	fake.OptInStub = nil
+	fake.optInReturns = struct {
 		result1 error
 	}{result1}
 }
 
+func (fake *FakeSMSService) WaitForDelivery(messageIDs []string, perMessage int, since time.Time, timeout time.Duration) ([]sms.DeliveryStatus, error) {
+	var messageIDsCopy []string
+	if messageIDs != nil {
+		messageIDsCopy = make([]string, len(messageIDs))
+		copy(messageIDsCopy, messageIDs)
+	}
+	fake.waitForDeliveryMutex.Lock()
+	fake.waitForDeliveryArgsForCall = append(fake.waitForDeliveryArgsForCall, struct {
+		messageIDs []string
+		perMessage int
+		since      time.Time
+		timeout    time.Duration
+	}{messageIDsCopy, perMessage, since, timeout})
+	fake.guard("WaitForDelivery")
+	fake.invocations["WaitForDelivery"] = append(fake.invocations["WaitForDelivery"], []interface{}{messageIDsCopy, perMessage, since, timeout})
+	fake.waitForDeliveryMutex.Unlock()
+	if fake.WaitForDeliveryStub != nil {
+		return fake.WaitForDeliveryStub(messageIDs, perMessage, since, timeout)
+	} else {
+		return fake.waitForDeliveryReturns.result1, fake.waitForDeliveryReturns.result2
+	}
+}
+
+func (fake *FakeSMSService) WaitForDeliveryCallCount() int {
+	fake.waitForDeliveryMutex.RLock()
+	defer fake.waitForDeliveryMutex.RUnlock()
+	return len(fake.waitForDeliveryArgsForCall)
+}
+
+func (fake *FakeSMSService) WaitForDeliveryArgsForCall(i int) ([]string, int, time.Time, time.Duration) {
+	fake.waitForDeliveryMutex.RLock()
+	defer fake.waitForDeliveryMutex.RUnlock()
+	return fake.waitForDeliveryArgsForCall[i].messageIDs, fake.waitForDeliveryArgsForCall[i].perMessage, fake.waitForDeliveryArgsForCall[i].since, fake.waitForDeliveryArgsForCall[i].timeout
+}
+
+func (fake *FakeSMSService) WaitForDeliveryReturns(result1 []sms.DeliveryStatus, result2 error) {
+	fake.WaitForDeliveryStub = nil
+	fake.waitForDeliveryReturns = struct {
+		result1 []sms.DeliveryStatus
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *FakeSMSService) Invocations() map[string][][]interface{} {
 	return fake.invocations
 }
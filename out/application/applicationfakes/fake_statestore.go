@@ -0,0 +1,122 @@
+// This file was generated by counterfeiter
+package applicationfakes
+
+import (
+	"sync"
+
+	"github.com/nickwei84/sms-resource/out/application"
+)
+
+type FakeStateStore struct {
+	GetStub        func(key string) ([]byte, bool, error)
+	getMutex       sync.RWMutex
+	getArgsForCall []struct {
+		key string
+	}
+	getReturns struct {
+		result1 []byte
+		result2 bool
+		result3 error
+	}
+	PutStub        func(key string, value []byte) error
+	putMutex       sync.RWMutex
+	putArgsForCall []struct {
+		key   string
+		value []byte
+	}
+	putReturns struct {
+		result1 error
+	}
+	invocations map[string][][]interface{}
+}
+
+func (fake *FakeStateStore) Get(key string) ([]byte, bool, error) {
+	fake.getMutex.Lock()
+	fake.getArgsForCall = append(fake.getArgsForCall, struct {
+		key string
+	}{key})
+	fake.guard("Get")
+	fake.invocations["Get"] = append(fake.invocations["Get"], []interface{}{key})
+	fake.getMutex.Unlock()
+	if fake.GetStub != nil {
+		return fake.GetStub(key)
+	} else {
+		return fake.getReturns.result1, fake.getReturns.result2, fake.getReturns.result3
+	}
+}
+
+func (fake *FakeStateStore) GetCallCount() int {
+	fake.getMutex.RLock()
+	defer fake.getMutex.RUnlock()
+	return len(fake.getArgsForCall)
+}
+
+func (fake *FakeStateStore) GetArgsForCall(i int) string {
+	fake.getMutex.RLock()
+	defer fake.getMutex.RUnlock()
+	return fake.getArgsForCall[i].key
+}
+
+func (fake *FakeStateStore) GetReturns(result1 []byte, result2 bool, result3 error) {
+	fake.GetStub = nil
+	fake.getReturns = struct {
+		result1 []byte
+		result2 bool
+		result3 error
+	}{result1, result2, result3}
+}
+
+func (fake *FakeStateStore) Put(key string, value []byte) error {
+	var valueCopy []byte
+	if value != nil {
+		valueCopy = make([]byte, len(value))
+		copy(valueCopy, value)
+	}
+	fake.putMutex.Lock()
+	fake.putArgsForCall = append(fake.putArgsForCall, struct {
+		key   string
+		value []byte
+	}{key, valueCopy})
+	fake.guard("Put")
+	fake.invocations["Put"] = append(fake.invocations["Put"], []interface{}{key, valueCopy})
+	fake.putMutex.Unlock()
+	if fake.PutStub != nil {
+		return fake.PutStub(key, value)
+	} else {
+		return fake.putReturns.result1
+	}
+}
+
+func (fake *FakeStateStore) PutCallCount() int {
+	fake.putMutex.RLock()
+	defer fake.putMutex.RUnlock()
+	return len(fake.putArgsForCall)
+}
+
+func (fake *FakeStateStore) PutArgsForCall(i int) (string, []byte) {
+	fake.putMutex.RLock()
+	defer fake.putMutex.RUnlock()
+	return fake.putArgsForCall[i].key, fake.putArgsForCall[i].value
+}
+
+func (fake *FakeStateStore) PutReturns(result1 error) {
+	fake.PutStub = nil
+	fake.putReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeStateStore) Invocations() map[string][][]interface{} {
+	return fake.invocations
+}
+
+func (fake *FakeStateStore) guard(key string) {
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+}
+
+var _ application.StateStore = new(FakeStateStore)
@@ -0,0 +1,196 @@
+package main_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// fakeSNS is a local stand-in for the SNS query API, answering the actions
+// the out command uses with canned XML responses. It also answers the
+// CloudWatch Logs calls that read the SMS delivery status logs, logging the
+// status in deliveries for each phone number published to directly. Phone
+// numbers in optedOut are reported as having opted out until opted in.
+type fakeSNS struct {
+	server *httptest.Server
+
+	mutex         sync.Mutex
+	requests      []url.Values
+	subscriptions []string
+	pageSize      int
+	throttled     map[string]int
+	deliveries    map[string]string
+	published     map[string]string
+	optedOut      map[string]bool
+}
+
+func newFakeSNS() *fakeSNS {
+	sns := &fakeSNS{pageSize: 100, throttled: map[string]int{}, deliveries: map[string]string{}, published: map[string]string{}, optedOut: map[string]bool{}}
+	sns.server = httptest.NewServer(http.HandlerFunc(sns.handle))
+	return sns
+}
+
+func (f *fakeSNS) URL() string {
+	return f.server.URL
+}
+
+func (f *fakeSNS) Close() {
+	f.server.Close()
+}
+
+func (f *fakeSNS) Requests() []url.Values {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return append([]url.Values{}, f.requests...)
+}
+
+func (f *fakeSNS) Actions() []string {
+	actions := []string{}
+	for _, request := range f.Requests() {
+		actions = append(actions, request.Get("Action"))
+	}
+	return actions
+}
+
+func (f *fakeSNS) RequestsFor(action string) []url.Values {
+	requests := []url.Values{}
+	for _, request := range f.Requests() {
+		if request.Get("Action") == action {
+			requests = append(requests, request)
+		}
+	}
+	return requests
+}
+
+func (f *fakeSNS) handle(w http.ResponseWriter, r *http.Request) {
+	if target := r.Header.Get("X-Amz-Target"); target != "" {
+		f.handleLogs(w, r, strings.TrimPrefix(target, "Logs_20140328."))
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.requests = append(f.requests, r.PostForm)
+
+	action := r.PostForm.Get("Action")
+	if f.throttled[action] > 0 {
+		f.throttled[action]--
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `<ErrorResponse><Error><Type>Sender</Type><Code>Throttling</Code><Message>Rate exceeded</Message></Error><RequestId>request-id</RequestId></ErrorResponse>`)
+		return
+	}
+
+	switch action {
+	case "CreateTopic":
+		writeSNSResponse(w, action, fmt.Sprintf("<TopicArn>%s</TopicArn>", topicArn(r.PostForm.Get("Name"))))
+	case "ListTopics":
+		writeSNSResponse(w, action, fmt.Sprintf("<Topics><member><TopicArn>%s</TopicArn></member></Topics>", topicArn("concourse")))
+	case "ListSubscriptionsByTopic":
+		writeSNSResponse(w, action, f.subscriptionsPage(r.PostForm.Get("TopicArn"), r.PostForm.Get("NextToken")))
+	case "Subscribe":
+		writeSNSResponse(w, action, "<SubscriptionArn>pending confirmation</SubscriptionArn>")
+	case "Publish":
+		messageID := fmt.Sprintf("message-%d", len(f.requests))
+		if phoneNumber := r.PostForm.Get("PhoneNumber"); phoneNumber != "" {
+			f.published[messageID] = phoneNumber
+		}
+		writeSNSResponse(w, action, fmt.Sprintf("<MessageId>%s</MessageId>", messageID))
+	case "CheckIfPhoneNumberIsOptedOut":
+		writeSNSResponse(w, action, fmt.Sprintf("<isOptedOut>%t</isOptedOut>", f.optedOut[r.PostForm.Get("phoneNumber")]))
+	case "OptInPhoneNumber":
+		delete(f.optedOut, r.PostForm.Get("phoneNumber"))
+		writeSNSResponse(w, action, "")
+	case "SetTopicAttributes", "Unsubscribe":
+		writeSNSResponse(w, action, "")
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, `<ErrorResponse><Error><Type>Sender</Type><Code>InvalidAction</Code><Message>unknown action %s</Message></Error><RequestId>request-id</RequestId></ErrorResponse>`, action)
+	}
+}
+
+func (f *fakeSNS) handleLogs(w http.ResponseWriter, r *http.Request, action string) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.requests = append(f.requests, url.Values{"Action": {action}, "Body": {string(body)}})
+
+	w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+	switch action {
+	case "DescribeLogGroups":
+		fmt.Fprint(w, `{"logGroups":[{"logGroupName":"sns/eu-west-1/123456789012/DirectPublishToPhoneNumber"}]}`)
+	case "FilterLogEvents":
+		var input struct {
+			FilterPattern string `json:"filterPattern"`
+		}
+		json.Unmarshal(body, &input)
+
+		if len(input.FilterPattern) > 1024 {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"__type":"InvalidParameterException","message":"filterPattern must be at most 1024 characters"}`)
+			return
+		}
+
+		events := []map[string]interface{}{}
+		for messageID, phoneNumber := range f.published {
+			status, ok := f.deliveries[phoneNumber]
+			if !ok || !strings.Contains(input.FilterPattern, strconv.Quote(messageID)) {
+				continue
+			}
+			message := fmt.Sprintf(`{"notification":{"messageId":%q},"delivery":{"destination":%q,"providerResponse":"%s response"},"status":%q}`,
+				messageID, phoneNumber, status, status)
+			events = append(events, map[string]interface{}{"message": message, "timestamp": 1792315800000})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"events": events})
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, `{"__type":"InvalidAction","message":"unknown action %s"}`, action)
+	}
+}
+
+func (f *fakeSNS) subscriptionsPage(topic string, nextToken string) string {
+	start, _ := strconv.Atoi(nextToken)
+	end := start + f.pageSize
+	if end > len(f.subscriptions) {
+		end = len(f.subscriptions)
+	}
+
+	result := "<Subscriptions>"
+	for i, endpoint := range f.subscriptions[start:end] {
+		result += fmt.Sprintf(`<member><Endpoint>%s</Endpoint><Protocol>sms</Protocol><SubscriptionArn>%s:subscription-%d</SubscriptionArn><TopicArn>%s</TopicArn></member>`,
+			endpoint, topic, start+i, topic)
+	}
+	result += "</Subscriptions>"
+
+	if end < len(f.subscriptions) {
+		result += fmt.Sprintf("<NextToken>%d</NextToken>", end)
+	}
+
+	return result
+}
+
+func topicArn(topic string) string {
+	return "arn:aws:sns:us-east-1:123456789012:" + topic
+}
+
+func writeSNSResponse(w http.ResponseWriter, action string, result string) {
+	w.Header().Set("Content-Type", "text/xml")
+	fmt.Fprintf(w, `<%[1]sResponse xmlns="http://sns.amazonaws.com/doc/2010-03-31/"><%[1]sResult>%[2]s</%[1]sResult><ResponseMetadata><RequestId>request-id</RequestId></ResponseMetadata></%[1]sResponse>`,
+		action, result)
+}
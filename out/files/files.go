@@ -0,0 +1,126 @@
+package files
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/nickwei84/sms-resource/lib/schedule"
+)
+
+// ReadMessage returns the contents of the message file at path, relative to
+// the build directory.
+func ReadMessage(buildDir string, path string) (string, error) {
+	contents, err := readFile(buildDir, path)
+	if err != nil {
+		return "", fmt.Errorf("error reading params.message_file: %v", err)
+	}
+
+	message := strings.TrimRight(string(contents), "\r\n")
+	if message == "" {
+		return "", fmt.Errorf("params.message_file %s is empty", path)
+	}
+
+	return message, nil
+}
+
+// ReadSubscribers returns the phone numbers listed in the subscribers file at
+// path, relative to the build directory. The file may contain a JSON array of
+// strings, or numbers separated by newlines and/or commas. Lines starting with
+// '#' are ignored.
+func ReadSubscribers(buildDir string, path string) ([]string, error) {
+	contents, err := readFile(buildDir, path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading params.subscribers_file: %v", err)
+	}
+
+	var subscribers []string
+	if strings.HasPrefix(strings.TrimSpace(string(contents)), "[") {
+		subscribers, err = parseJSONList(contents)
+	} else {
+		subscribers, err = parseCSVList(contents)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error parsing params.subscribers_file %s: %v", path, err)
+	}
+
+	if len(subscribers) == 0 {
+		return nil, fmt.Errorf("params.subscribers_file %s does not contain any subscribers", path)
+	}
+
+	return subscribers, nil
+}
+
+// ReadSchedule returns the on-call schedule in the JSON file at path, relative
+// to the build directory.
+func ReadSchedule(buildDir string, path string) (schedule.Schedule, error) {
+	contents, err := readFile(buildDir, path)
+	if err != nil {
+		return schedule.Schedule{}, fmt.Errorf("error reading source.schedule.file: %v", err)
+	}
+
+	var s schedule.Schedule
+	err = json.Unmarshal(contents, &s)
+	if err != nil {
+		return schedule.Schedule{}, fmt.Errorf("error parsing source.schedule.file %s: %v", path, err)
+	}
+
+	return s, nil
+}
+
+func readFile(buildDir string, path string) ([]byte, error) {
+	if buildDir == "" {
+		return nil, fmt.Errorf("build directory was not provided as an argument")
+	}
+
+	return ioutil.ReadFile(filepath.Join(buildDir, path))
+}
+
+func parseJSONList(contents []byte) ([]string, error) {
+	var list []string
+	err := json.Unmarshal(contents, &list)
+	if err != nil {
+		return nil, err
+	}
+
+	subscribers := []string{}
+	for _, subscriber := range list {
+		subscriber = strings.TrimSpace(subscriber)
+		if subscriber != "" {
+			subscribers = append(subscribers, subscriber)
+		}
+	}
+
+	return subscribers, nil
+}
+
+func parseCSVList(contents []byte) ([]string, error) {
+	reader := csv.NewReader(strings.NewReader(string(contents)))
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	subscribers := []string{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		for _, field := range record {
+			field = strings.TrimSpace(field)
+			if field != "" {
+				subscribers = append(subscribers, field)
+			}
+		}
+	}
+
+	return subscribers, nil
+}
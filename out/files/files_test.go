@@ -0,0 +1,126 @@
+package files_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/nickwei84/sms-resource/out/files"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Files", func() {
+	var buildDir string
+
+	BeforeEach(func() {
+		var err error
+		buildDir, err = ioutil.TempDir("", "sms-resource-files")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.MkdirAll(filepath.Join(buildDir, "output"), 0755)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(buildDir)
+	})
+
+	writeFile := func(path string, contents string) {
+		err := ioutil.WriteFile(filepath.Join(buildDir, path), []byte(contents), 0644)
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	Describe("ReadMessage", func() {
+		It("should read the message relative to the build directory", func() {
+			writeFile("output/message.txt", "3 specs failed\n")
+			message, err := files.ReadMessage(buildDir, "output/message.txt")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(message).To(Equal("3 specs failed"))
+		})
+
+		It("should return an error if the file does not exist", func() {
+			_, err := files.ReadMessage(buildDir, "output/missing.txt")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(HavePrefix("error reading params.message_file: "))
+		})
+
+		It("should return an error if the file is empty", func() {
+			writeFile("output/message.txt", "\n")
+			_, err := files.ReadMessage(buildDir, "output/message.txt")
+			Expect(err).To(MatchError("params.message_file output/message.txt is empty"))
+		})
+
+		It("should return an error if the build directory was not provided", func() {
+			_, err := files.ReadMessage("", "output/message.txt")
+			Expect(err).To(MatchError("error reading params.message_file: build directory was not provided as an argument"))
+		})
+	})
+
+	Describe("ReadSubscribers", func() {
+		It("should read a newline separated list", func() {
+			writeFile("output/on-call", "# primary\n14151234567\n\n 16501234567 \n")
+			subscribers, err := files.ReadSubscribers(buildDir, "output/on-call")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(subscribers).To(Equal([]string{"14151234567", "16501234567"}))
+		})
+
+		It("should read a comma separated list", func() {
+			writeFile("output/on-call", "14151234567, 16501234567,\n17071234567")
+			subscribers, err := files.ReadSubscribers(buildDir, "output/on-call")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(subscribers).To(Equal([]string{"14151234567", "16501234567", "17071234567"}))
+		})
+
+		It("should read a JSON list", func() {
+			writeFile("output/on-call", `["14151234567", "16501234567"]`)
+			subscribers, err := files.ReadSubscribers(buildDir, "output/on-call")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(subscribers).To(Equal([]string{"14151234567", "16501234567"}))
+		})
+
+		It("should return an error if the JSON list is malformed", func() {
+			writeFile("output/on-call", `["14151234567", 16501234567]`)
+			_, err := files.ReadSubscribers(buildDir, "output/on-call")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(HavePrefix("error parsing params.subscribers_file output/on-call: "))
+		})
+
+		It("should return an error if the file does not list any subscribers", func() {
+			writeFile("output/on-call", "# nobody\n")
+			_, err := files.ReadSubscribers(buildDir, "output/on-call")
+			Expect(err).To(MatchError("params.subscribers_file output/on-call does not contain any subscribers"))
+		})
+
+		It("should return an error if the file does not exist", func() {
+			_, err := files.ReadSubscribers(buildDir, "output/missing")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(HavePrefix("error reading params.subscribers_file: "))
+		})
+	})
+
+	Describe("ReadSchedule", func() {
+		It("should read the schedule relative to the build directory", func() {
+			writeFile("output/schedule.json", `{
+  "time_zone": "Europe/London",
+  "layers": [{"members": ["+447700900123", "+447700900456"], "start": "2026-01-05", "handoff": "09:00"}]
+}`)
+			s, err := files.ReadSchedule(buildDir, "output/schedule.json")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(s.TimeZone).To(Equal("Europe/London"))
+			Expect(s.Layers).To(HaveLen(1))
+			Expect(s.Layers[0].Members).To(Equal([]string{"+447700900123", "+447700900456"}))
+		})
+
+		It("should return an error if the file is not JSON", func() {
+			writeFile("output/schedule.json", "layers: []\n")
+			_, err := files.ReadSchedule(buildDir, "output/schedule.json")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(HavePrefix("error parsing source.schedule.file output/schedule.json: "))
+		})
+
+		It("should return an error if the file does not exist", func() {
+			_, err := files.ReadSchedule(buildDir, "output/missing.json")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(HavePrefix("error reading source.schedule.file: "))
+		})
+	})
+})
@@ -0,0 +1,124 @@
+package idempotency
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/nickwei84/sms-resource/out/application"
+)
+
+var now = time.Now
+
+// The status of a put, as recorded before it sends anything, once it has sent
+// the message, and when it finishes.
+const (
+	statusStarted   = "started"
+	statusSent      = "sent"
+	statusCompleted = "completed"
+	statusFailed    = "failed"
+)
+
+type record struct {
+	Status     string          `json:"status"`
+	UpdatedAt  time.Time       `json:"updated_at"`
+	MessageIDs []string        `json:"message_ids,omitempty"`
+	Output     json.RawMessage `json:"output,omitempty"`
+}
+
+// Key identifies a put within a build. Concourse does not tell resources the
+// name of the step, so without an explicit step key the put is identified by
+// a hash of its params instead.
+func Key(buildID string, stepKey string, params interface{}) (string, error) {
+	if stepKey == "" {
+		encoded, err := json.Marshal(params)
+		if err != nil {
+			return "", fmt.Errorf("error marshalling params for the idempotency key: %v", err)
+		}
+		sum := sha256.Sum256(encoded)
+		stepKey = hex.EncodeToString(sum[:8])
+	}
+
+	return fmt.Sprintf("idempotency/%s/%s", buildID, stepKey), nil
+}
+
+// Guard records a put in a state store before and after it sends anything, so
+// that a put replayed by Concourse, for example after its worker was lost,
+// does not send the message again.
+type Guard struct {
+	store application.StateStore
+	key   string
+}
+
+func NewGuard(store application.StateStore, key string) Guard {
+	return Guard{
+		store: store,
+		key:   key,
+	}
+}
+
+// Begin returns the output of an earlier attempt of the put that sent the
+// message, or records that this attempt has started when there is none. An
+// earlier attempt that started but never recorded sending may have sent the
+// message, so the put fails rather than risk sending it twice. An earlier
+// attempt that failed before sending anything is retried.
+func (g Guard) Begin() ([]byte, bool, error) {
+	value, found, err := g.store.Get(g.key)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if found {
+		var previous record
+		err = json.Unmarshal(value, &previous)
+		if err != nil {
+			return nil, false, fmt.Errorf("error parsing state %s: %v", g.key, err)
+		}
+
+		switch previous.Status {
+		case statusCompleted, statusSent:
+			return previous.Output, true, nil
+		case statusStarted:
+			return nil, false, fmt.Errorf("an earlier attempt of this put started at %s and did not finish, so the message may have been sent already; "+
+				"set params.idempotency_key to a new value to send it anyway", previous.UpdatedAt.Format(time.RFC3339))
+		}
+	}
+
+	return nil, false, g.put(record{Status: statusStarted, UpdatedAt: now().UTC()})
+}
+
+// Sent records the message IDs and output of the put as soon as it has sent the
+// message, before it waits for anything, to be returned to replays of it if the
+// put then fails or is interrupted.
+func (g Guard) Sent(messageIDs []string, output []byte) error {
+	err := g.put(record{Status: statusSent, UpdatedAt: now().UTC(), MessageIDs: messageIDs, Output: output})
+	if err != nil {
+		return fmt.Errorf("the message was sent, but recording the put for idempotency failed: %v", err)
+	}
+	return nil
+}
+
+// Complete records the output of the put, to be returned to replays of it.
+func (g Guard) Complete(output []byte) error {
+	err := g.put(record{Status: statusCompleted, UpdatedAt: now().UTC(), Output: output})
+	if err != nil {
+		return fmt.Errorf("the message was sent, but recording the put for idempotency failed: %v", err)
+	}
+	return nil
+}
+
+// Fail records that the put failed without sending anything, so that it runs
+// again when retried.
+func (g Guard) Fail() error {
+	return g.put(record{Status: statusFailed, UpdatedAt: now().UTC()})
+}
+
+func (g Guard) put(r record) error {
+	value, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	return g.store.Put(g.key, value)
+}
@@ -0,0 +1,141 @@
+package idempotency_test
+
+import (
+	"errors"
+
+	"github.com/nickwei84/sms-resource/out/application/applicationfakes"
+	"github.com/nickwei84/sms-resource/out/idempotency"
+	"github.com/nickwei84/sms-resource/out/models"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Idempotency", func() {
+	Describe("Key", func() {
+		It("should identify the put by the build and the step key", func() {
+			key, err := idempotency.Key("1234", "notify-on-call", models.Params{Message: "hello"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(key).To(Equal("idempotency/1234/notify-on-call"))
+		})
+
+		It("should identify the put by its params without a step key", func() {
+			key, err := idempotency.Key("1234", "", models.Params{Message: "hello"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(key).To(MatchRegexp(`^idempotency/1234/[0-9a-f]{16}$`))
+
+			sameKey, err := idempotency.Key("1234", "", models.Params{Message: "hello"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(sameKey).To(Equal(key))
+
+			otherKey, err := idempotency.Key("1234", "", models.Params{Message: "goodbye"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(otherKey).NotTo(Equal(key))
+		})
+	})
+
+	Describe("Guard", func() {
+		var (
+			state *applicationfakes.FakeStateStore
+			guard idempotency.Guard
+		)
+
+		BeforeEach(func() {
+			state = new(applicationfakes.FakeStateStore)
+			guard = idempotency.NewGuard(state, "idempotency/1234/notify")
+		})
+
+		Context("when the put has not run before", func() {
+			It("should record that it started", func() {
+				_, replayed, err := guard.Begin()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(replayed).To(BeFalse())
+
+				Expect(state.PutCallCount()).To(Equal(1))
+				key, value := state.PutArgsForCall(0)
+				Expect(key).To(Equal("idempotency/1234/notify"))
+				Expect(string(value)).To(ContainSubstring(`"status":"started"`))
+			})
+
+			It("should record the message IDs and output once it has sent the message", func() {
+				Expect(guard.Sent([]string{"message-1"}, []byte(`{"Version":{"MessageID":"message-1"}}`))).To(Succeed())
+
+				_, value := state.PutArgsForCall(0)
+				Expect(string(value)).To(ContainSubstring(`"status":"sent"`))
+				Expect(string(value)).To(ContainSubstring(`"message_ids":["message-1"]`))
+				Expect(string(value)).To(ContainSubstring(`"output":{"Version":{"MessageID":"message-1"}}`))
+			})
+
+			It("should record the output once it completes", func() {
+				Expect(guard.Complete([]byte(`{"Version":{"MessageID":"message-1"}}`))).To(Succeed())
+
+				_, value := state.PutArgsForCall(0)
+				Expect(string(value)).To(ContainSubstring(`"status":"completed"`))
+				Expect(string(value)).To(ContainSubstring(`"output":{"Version":{"MessageID":"message-1"}}`))
+			})
+		})
+
+		Context("when an earlier attempt completed", func() {
+			BeforeEach(func() {
+				state.GetReturns([]byte(`{"status":"completed","updated_at":"2026-10-18T09:30:00Z","output":{"Version":{"MessageID":"message-1"}}}`), true, nil)
+			})
+
+			It("should return its output without recording anything", func() {
+				output, replayed, err := guard.Begin()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(replayed).To(BeTrue())
+				Expect(output).To(MatchJSON(`{"Version":{"MessageID":"message-1"}}`))
+				Expect(state.PutCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when an earlier attempt did not finish", func() {
+			BeforeEach(func() {
+				state.GetReturns([]byte(`{"status":"started","updated_at":"2026-10-18T09:30:00Z"}`), true, nil)
+			})
+
+			It("should return an error", func() {
+				_, _, err := guard.Begin()
+				Expect(err).To(MatchError("an earlier attempt of this put started at 2026-10-18T09:30:00Z and did not finish, so the message may have been sent already; " +
+					"set params.idempotency_key to a new value to send it anyway"))
+			})
+		})
+
+		Context("when an earlier attempt sent the message but did not complete", func() {
+			BeforeEach(func() {
+				state.GetReturns([]byte(`{"status":"sent","updated_at":"2026-10-18T09:30:00Z","message_ids":["message-1"],"output":{"Version":{"MessageID":"message-1"}}}`), true, nil)
+			})
+
+			It("should return its output without recording anything", func() {
+				output, replayed, err := guard.Begin()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(replayed).To(BeTrue())
+				Expect(output).To(MatchJSON(`{"Version":{"MessageID":"message-1"}}`))
+				Expect(state.PutCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when an earlier attempt failed", func() {
+			BeforeEach(func() {
+				state.GetReturns([]byte(`{"status":"failed","updated_at":"2026-10-18T09:30:00Z"}`), true, nil)
+			})
+
+			It("should run the put again", func() {
+				_, replayed, err := guard.Begin()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(replayed).To(BeFalse())
+				Expect(state.PutCallCount()).To(Equal(1))
+			})
+		})
+
+		Context("when the state cannot be read", func() {
+			BeforeEach(func() {
+				state.GetReturns(nil, false, errors.New("error getting state idempotency/1234/notify from S3: AccessDenied"))
+			})
+
+			It("should return the error", func() {
+				_, _, err := guard.Begin()
+				Expect(err).To(MatchError("error getting state idempotency/1234/notify from S3: AccessDenied"))
+			})
+		})
+	})
+})
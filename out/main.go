@@ -5,18 +5,22 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
-	"github.com/nickwei84/sms-resource/lib/awsclient"
+	"github.com/nickwei84/sms-resource/lib/phonenumber"
+	"github.com/nickwei84/sms-resource/lib/sms"
 	"github.com/nickwei84/sms-resource/out/application"
+	"github.com/nickwei84/sms-resource/out/files"
+	"github.com/nickwei84/sms-resource/out/idempotency"
+	"github.com/nickwei84/sms-resource/out/message"
 	"github.com/nickwei84/sms-resource/out/models"
+	"github.com/nickwei84/sms-resource/out/retry"
 )
 
 func main() {
-	var (
-		config models.SMSConfig
-		client application.SMSService
-	)
+	var config models.SMSConfig
 
 	err := getStdinInput(&config)
 	if err != nil {
@@ -28,22 +32,183 @@ func main() {
 		exitWithErr(err)
 	}
 
-	client = awsclient.NewAWSClient(config.Source.AWSAccessKeyID, config.Source.AWSSecretAccessKey)
-	app := application.NewApplication(client, config)
+	givenParams := config.Params
 
-	err = app.Run()
+	err = readParamsFiles(&config, buildDir())
 	if err != nil {
 		exitWithErr(err)
 	}
 
-	stdoutOutput, err := generateStdoutOutput()
+	sendingAt := time.Now()
+	onCall, err := resolveOnCall(config.Source, buildDir(), sendingAt)
 	if err != nil {
 		exitWithErr(err)
 	}
 
+	// The on-call numbers are valid already, and normalizing them together with
+	// the subscribers leaves out the ones that are subscribers too.
+	config.Params.Subscribers, err = phonenumber.NormalizeAll(append(config.Params.Subscribers, onCall...), config.Source.DefaultCountryCode)
+	if err != nil {
+		exitWithErr(fmt.Errorf("error in params.subscribers: %v", err))
+	}
+
+	if len(config.Params.Subscribers) == 0 {
+		exitWithErr(fmt.Errorf("no one is on call in source.schedule at %s and params.subscribers is empty", sendingAt.UTC().Format(time.RFC3339)))
+	}
+
+	config.Params.OptIn, err = phonenumber.NormalizeAll(config.Params.OptIn, config.Source.DefaultCountryCode)
+	if err != nil {
+		exitWithErr(fmt.Errorf("error in params.opt_in: %v", err))
+	}
+
+	for i := range config.Source.Contacts {
+		config.Source.Contacts[i].Number, _ = phonenumber.Normalize(config.Source.Contacts[i].Number, config.Source.DefaultCountryCode)
+	}
+
+	renderer := message.NewRenderer(message.NewBuildEnvironment(os.Getenv), time.Now)
+	config.Params.Message, err = renderer.Render(config.Params.Message)
+	if err != nil {
+		exitWithErr(err)
+	}
+
+	providers, clients, err := newProviders(config.Source)
+	if err != nil {
+		exitWithErr(err)
+	}
+
+	state := application.NewStateStore(config.Source)
+	guard, err := newGuard(state, config, givenParams)
+	if err != nil {
+		exitWithErr(err)
+	}
+
+	if guard != nil {
+		previousOutput, replayed, err := guard.Begin()
+		if err != nil {
+			exitWithErr(err)
+		}
+		if replayed {
+			fmt.Fprintln(os.Stderr, "this put already sent the message in an earlier attempt, returning its version instead of sending it again")
+			fmt.Println(string(previousOutput))
+			return
+		}
+	}
+
+	app := application.NewFailoverApplication(providers, config).WithStateStore(state)
+
+	sent := false
+	if guard != nil {
+		app = app.OnSent(func(result models.Result) error {
+			sent = true
+			// Replayed only if this attempt does not complete.
+			result.Metadata = append(result.Metadata, sms.MetadataItem{Name: "earlier_attempt_incomplete", Value: "true"})
+			output, err := generateStdoutOutput(result, false)
+			if err != nil {
+				return err
+			}
+			return guard.Sent(result.MessageIDs, output)
+		})
+	}
+
+	result, err := app.Run()
+	if err != nil {
+		// A put that sent any of the message keeps the record of it, so that
+		// a retry does not send it again. The put fails with the error from
+		// sending either way, so an error recording the failure is left out.
+		if guard != nil && !sent {
+			guard.Fail()
+		}
+		exitWithErr(err)
+	}
+
+	attempts := 0
+	for _, client := range clients {
+		attempts += client.Attempts()
+	}
+	result.Metadata = append(result.Metadata, sms.MetadataItem{Name: "attempts", Value: strconv.Itoa(attempts)})
+
+	if config.Source.Schedule.IsConfigured() {
+		result.Metadata = append(result.Metadata, sms.MetadataItem{Name: "on_call", Value: strings.Join(phonenumber.MaskAll(onCall), ",")})
+	}
+
+	stdoutOutput, err := generateStdoutOutput(result, config.IsDryRun())
+	if err != nil {
+		exitWithErr(err)
+	}
+
+	if guard != nil {
+		err = guard.Complete(stdoutOutput)
+		if err != nil {
+			exitWithErr(err)
+		}
+	}
+
 	fmt.Println(string(stdoutOutput))
 }
 
+func resolveOnCall(source models.Source, buildDir string, at time.Time) ([]string, error) {
+	if !source.Schedule.IsConfigured() {
+		return nil, nil
+	}
+
+	if source.Schedule.File != "" {
+		var err error
+		source.Schedule.Schedule, err = files.ReadSchedule(buildDir, source.Schedule.File)
+		if err != nil {
+			return nil, err
+		}
+
+		err = source.Schedule.Check(source.DefaultCountryCode)
+		if err != nil {
+			return nil, fmt.Errorf("error in source.schedule.file %s: %v", source.Schedule.File, err)
+		}
+	}
+
+	return phonenumber.NormalizeAll(source.Schedule.OnCall(at), source.DefaultCountryCode)
+}
+
+// Dry runs are never guarded.
+func newGuard(state application.StateStore, config models.SMSConfig, givenParams models.Params) (*idempotency.Guard, error) {
+	buildID := os.Getenv("BUILD_ID")
+	if state == nil || buildID == "" || config.IsDryRun() {
+		return nil, nil
+	}
+
+	key, err := idempotency.Key(buildID, config.Params.IdempotencyKey, givenParams)
+	if err != nil {
+		return nil, err
+	}
+
+	guard := idempotency.NewGuard(state, key)
+	return &guard, nil
+}
+
+func newProviders(source models.Source) ([]application.Provider, []*retry.SMSService, error) {
+	sources := []models.Source{source}
+	if len(source.Providers) > 0 {
+		var err error
+		sources, err = source.ProviderChain()
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	providers := []application.Provider{}
+	clients := []*retry.SMSService{}
+	for _, providerSource := range sources {
+		smsService, err := application.NewSMSService(providerSource)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		client := retry.NewSMSService(smsService, retry.NewPolicy(providerSource))
+		providers = append(providers, application.Provider{Name: providerSource.ProviderName(), Service: client})
+		clients = append(clients, client)
+	}
+
+	return providers, clients, nil
+}
+
 func exitWithErr(err interface{}) {
 	fmt.Fprintf(os.Stderr, "%v\n", err)
 	os.Exit(1)
@@ -63,12 +228,62 @@ func getStdinInput(config *models.SMSConfig) error {
 	return nil
 }
 
-func generateStdoutOutput() ([]byte, error) {
+func buildDir() string {
+	if len(os.Args) < 2 {
+		return ""
+	}
+
+	return os.Args[1]
+}
+
+func readParamsFiles(config *models.SMSConfig, buildDir string) error {
+	var err error
+
+	if config.Params.MessageFile != "" {
+		config.Params.Message, err = files.ReadMessage(buildDir, config.Params.MessageFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	if config.Params.SubscribersFile != "" {
+		config.Params.Subscribers, err = files.ReadSubscribers(buildDir, config.Params.SubscribersFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func generateStdoutOutput(result models.Result, dryRun bool) ([]byte, error) {
+	sentAt := result.SentAt
+	if sentAt.IsZero() {
+		sentAt = time.Now()
+	}
+
 	output := models.OutputJSON{
 		Version: models.Version{
-			Time: time.Now().UTC(),
+			Time:       sentAt.UTC(),
+			MessageID:  strings.Join(result.MessageIDs, ","),
+			TopicArn:   result.TopicArn,
+			Recipients: strings.Join(phonenumber.MaskAll(result.Recipients), ","),
+			Body:       result.Body,
+			AckCode:    result.AckCode,
 		},
-		Metadata: []models.MetadataItem{},
+		Metadata: result.Metadata,
+	}
+
+	if result.TopicArn != "" {
+		output.Version.RecipientCount = strconv.Itoa(result.RecipientCount)
+	}
+
+	if dryRun {
+		output.Version.DryRun = "true"
+	}
+
+	if result.Suppressed {
+		output.Version.Suppressed = "true"
 	}
 
 	stdoutOutput, err := json.Marshal(output)
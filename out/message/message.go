@@ -0,0 +1,112 @@
+package message
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
+)
+
+type BuildEnvironment struct {
+	BuildID           string
+	BuildName         string
+	BuildJobName      string
+	BuildPipelineName string
+	BuildTeamName     string
+	ATCExternalURL    string
+}
+
+func NewBuildEnvironment(getenv func(string) string) BuildEnvironment {
+	return BuildEnvironment{
+		BuildID:           getenv("BUILD_ID"),
+		BuildName:         getenv("BUILD_NAME"),
+		BuildJobName:      getenv("BUILD_JOB_NAME"),
+		BuildPipelineName: getenv("BUILD_PIPELINE_NAME"),
+		BuildTeamName:     getenv("BUILD_TEAM_NAME"),
+		ATCExternalURL:    getenv("ATC_EXTERNAL_URL"),
+	}
+}
+
+// BuildURL returns the link to the build in the Concourse web UI. One-off
+// builds that do not belong to a job are linked by their build ID.
+func (e BuildEnvironment) BuildURL() string {
+	externalURL := strings.TrimSuffix(e.ATCExternalURL, "/")
+
+	if e.BuildJobName == "" {
+		return fmt.Sprintf("%s/builds/%s", externalURL, url.PathEscape(e.BuildID))
+	}
+
+	return fmt.Sprintf("%s/teams/%s/pipelines/%s/jobs/%s/builds/%s",
+		externalURL,
+		url.PathEscape(e.BuildTeamName),
+		url.PathEscape(e.BuildPipelineName),
+		url.PathEscape(e.BuildJobName),
+		url.PathEscape(e.BuildName),
+	)
+}
+
+type Renderer struct {
+	env BuildEnvironment
+	now func() time.Time
+}
+
+func NewRenderer(env BuildEnvironment, now func() time.Time) Renderer {
+	return Renderer{
+		env: env,
+		now: now,
+	}
+}
+
+// Render executes text as a Go text/template. The build environment fields are
+// available as the template data, e.g. {{.BuildJobName}}. A template that
+// renders to nothing but whitespace is an error, as there is nothing to send.
+func (r Renderer) Render(text string) (string, error) {
+	tmpl, err := template.New("message").Option("missingkey=error").Funcs(r.funcs()).Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("error parsing params.message as a template: %v", err)
+	}
+
+	var rendered bytes.Buffer
+	err = tmpl.Execute(&rendered, r.env)
+	if err != nil {
+		return "", fmt.Errorf("error rendering params.message template: %v", err)
+	}
+
+	if strings.TrimSpace(rendered.String()) == "" {
+		return "", fmt.Errorf("params.message from stdin renders to an empty message")
+	}
+
+	return rendered.String(), nil
+}
+
+func (r Renderer) funcs() template.FuncMap {
+	return template.FuncMap{
+		"buildURL": r.env.BuildURL,
+		"now":      r.now,
+		"date":     date,
+		"truncate": truncate,
+		"upper":    strings.ToUpper,
+		"lower":    strings.ToLower,
+	}
+}
+
+// date formats t using a Go reference-time layout, e.g. {{now | date "15:04 MST"}}.
+func date(layout string, t time.Time) string {
+	return t.Format(layout)
+}
+
+// truncate shortens s to at most length characters, marking the cut with "...".
+func truncate(length int, s string) string {
+	runes := []rune(s)
+	if length < 0 || len(runes) <= length {
+		return s
+	}
+
+	if length <= 3 {
+		return string(runes[:length])
+	}
+
+	return string(runes[:length-3]) + "..."
+}
@@ -0,0 +1,103 @@
+package message_test
+
+import (
+	"time"
+
+	"github.com/nickwei84/sms-resource/out/message"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Renderer", func() {
+	var (
+		env      message.BuildEnvironment
+		renderer message.Renderer
+	)
+
+	BeforeEach(func() {
+		env = message.NewBuildEnvironment(func(key string) string {
+			return map[string]string{
+				"BUILD_ID":            "1234",
+				"BUILD_NAME":          "42",
+				"BUILD_JOB_NAME":      "deploy",
+				"BUILD_PIPELINE_NAME": "prod",
+				"BUILD_TEAM_NAME":     "main",
+				"ATC_EXTERNAL_URL":    "https://ci.example.com/",
+			}[key]
+		})
+	})
+
+	JustBeforeEach(func() {
+		renderer = message.NewRenderer(env, func() time.Time {
+			return time.Date(2016, time.May, 4, 13, 30, 0, 0, time.UTC)
+		})
+	})
+
+	Describe("Render", func() {
+		It("should return a message without template actions unchanged", func() {
+			rendered, err := renderer.Render("hello")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rendered).To(Equal("hello"))
+		})
+
+		It("should expose the build environment to the template", func() {
+			rendered, err := renderer.Render("{{.BuildPipelineName}}/{{.BuildJobName}} #{{.BuildName}} ({{.BuildID}}) on {{.BuildTeamName}} at {{.ATCExternalURL}}")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rendered).To(Equal("prod/deploy #42 (1234) on main at https://ci.example.com/"))
+		})
+
+		It("should render the build URL", func() {
+			rendered, err := renderer.Render("{{buildURL}}")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rendered).To(Equal("https://ci.example.com/teams/main/pipelines/prod/jobs/deploy/builds/42"))
+		})
+
+		Context("when the build is a one-off build", func() {
+			BeforeEach(func() {
+				env.BuildJobName = ""
+				env.BuildPipelineName = ""
+			})
+
+			It("should render the build URL from the build ID", func() {
+				rendered, err := renderer.Render("{{buildURL}}")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(rendered).To(Equal("https://ci.example.com/builds/1234"))
+			})
+		})
+
+		It("should truncate text", func() {
+			rendered, err := renderer.Render(`{{truncate 8 "a very long job name"}}|{{"short" | truncate 8}}`)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rendered).To(Equal("a ver...|short"))
+		})
+
+		It("should change the case of text", func() {
+			rendered, err := renderer.Render("{{upper .BuildJobName}} {{lower \"FAILED\"}}")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rendered).To(Equal("DEPLOY failed"))
+		})
+
+		It("should format the current time", func() {
+			rendered, err := renderer.Render(`{{now | date "2006-01-02 15:04 MST"}}`)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rendered).To(Equal("2016-05-04 13:30 UTC"))
+		})
+
+		It("should return an error if the template cannot be parsed", func() {
+			_, err := renderer.Render("{{.BuildJobName")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(HavePrefix("error parsing params.message as a template: "))
+		})
+
+		It("should return an error if the template cannot be executed", func() {
+			_, err := renderer.Render("{{.UnknownField}}")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(HavePrefix("error rendering params.message template: "))
+		})
+
+		It("should return an error if the template renders to an empty message", func() {
+			_, err := renderer.Render("{{if .BuildJobName}}{{end}} \n")
+			Expect(err).To(MatchError("params.message from stdin renders to an empty message"))
+		})
+	})
+})
@@ -1,22 +1,110 @@
 package models
 
 import (
+	"encoding/json"
 	"fmt"
+	"net/url"
+	"regexp"
 	"time"
+
+	"github.com/nickwei84/sms-resource/lib/phonenumber"
+	"github.com/nickwei84/sms-resource/lib/schedule"
+	"github.com/nickwei84/sms-resource/lib/sms"
 )
 
 type OutputJSON struct {
 	Version  Version
-	Metadata []MetadataItem
+	Metadata []sms.MetadataItem
 }
 
+// Recipients holds the masked phone numbers separated by commas, as Concourse
+// version values must be strings.
 type Version struct {
-	Time time.Time
+	Time       time.Time
+	MessageID  string `json:",omitempty"`
+	TopicArn   string `json:",omitempty"`
+	Recipients string `json:",omitempty"`
+	// RecipientCount is the number of confirmed subscriptions a message
+	// published to a topic was delivered to.
+	RecipientCount string `json:",omitempty"`
+	ReplyID        string `json:",omitempty"`
+	Sender         string `json:",omitempty"`
+	Body           string `json:",omitempty"`
+	AckCode        string `json:",omitempty"`
+	DryRun         string `json:",omitempty"`
+	Suppressed     string `json:",omitempty"`
+}
+
+func (v Version) IsReply() bool {
+	return v.ReplyID != ""
+}
+
+// A message split into parts, or sent to each phone number directly, has one
+// message ID per publish, and Body has the parts separated by newlines.
+type Result struct {
+	MessageIDs     []string
+	TopicArn       string
+	Recipients     []string
+	RecipientCount int
+	Body           string
+	AckCode        string
+	Suppressed     bool
+	Metadata       []sms.MetadataItem
+
+	// SentAt is when the first part of the message was published, which is
+	// zero when nothing was sent.
+	SentAt time.Time
 }
 
-type MetadataItem struct {
-	Name  string
-	Value string
+const (
+	ModeTopic  = "topic"
+	ModeDirect = "direct"
+)
+
+const (
+	ProviderSNS     = "sns"
+	ProviderTwilio  = "twilio"
+	ProviderWebhook = "webhook"
+)
+
+const (
+	SMSTypeTransactional = "Transactional"
+	SMSTypePromotional   = "Promotional"
+)
+
+const (
+	OverflowTruncate = "truncate"
+	OverflowSplit    = "split"
+	OverflowFail     = "fail"
+)
+
+const (
+	SeverityNormal   = "normal"
+	SeverityCritical = "critical"
+)
+
+const (
+	StateBackendS3       = "s3"
+	StateBackendDynamoDB = "dynamodb"
+	StateBackendFile     = "file"
+)
+
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value string
+	err := json.Unmarshal(data, &value)
+	if err != nil {
+		return fmt.Errorf("duration must be a string such as \"30s\", got %s", data)
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+
+	*d = Duration(duration)
+	return nil
 }
 
 type SMSConfig struct {
@@ -25,40 +113,655 @@ type SMSConfig struct {
 }
 
 type Source struct {
-	AWSAccessKeyID     string `json:"aws_access_key_id"`
-	AWSSecretAccessKey string `json:"aws_secret_access_key"`
-	Topic              string `json:"topic"`
+	AWSAccessKeyID        string    `json:"aws_access_key_id"`
+	AWSSecretAccessKey    string    `json:"aws_secret_access_key"`
+	AWSSessionToken       string    `json:"aws_session_token"`
+	AssumeRoleArn         string    `json:"assume_role_arn"`
+	AssumeRoleExternalID  string    `json:"assume_role_external_id"`
+	AssumeRoleSessionName string    `json:"assume_role_session_name"`
+	Topic                 string    `json:"topic"`
+	Mode                  string    `json:"mode"`
+	Region                string    `json:"region"`
+	Endpoint              string    `json:"endpoint"`
+	DisableSSL            bool      `json:"disable_ssl"`
+	SenderID              string    `json:"sender_id"`
+	DefaultCountryCode    string    `json:"default_country_code"`
+	DryRun                bool      `json:"dry_run"`
+	DryRunOffline         bool      `json:"dry_run_offline"`
+	MaxRetries            *int      `json:"max_retries"`
+	RetryBaseDelay        Duration  `json:"retry_base_delay"`
+	RetryJitter           Duration  `json:"retry_jitter"`
+	RetryDeadline         Duration  `json:"retry_deadline"`
+	InboundQueueURL       string    `json:"inbound_queue_url"`
+	InboundSenders        []string  `json:"inbound_senders"`
+	InboundKeyword        string    `json:"inbound_keyword"`
+	InboundTrigger        bool      `json:"inbound_trigger"`
+	Provider              string    `json:"provider"`
+	TwilioAccountSID      string    `json:"twilio_account_sid"`
+	TwilioAuthToken       string    `json:"twilio_auth_token"`
+	TwilioFromNumber      string    `json:"twilio_from_number"`
+	Webhook               Webhook   `json:"webhook"`
+	Contacts              []Contact `json:"contacts"`
+	State                 State     `json:"state"`
+	Schedule              Schedule  `json:"schedule"`
+
+	// Providers are tried in order when sending to a phone number fails. See
+	// ProviderChain.
+	Providers []json.RawMessage `json:"providers"`
+}
+
+type Webhook struct {
+	URL           string            `json:"url"`
+	Method        string            `json:"method"`
+	Headers       map[string]string `json:"headers"`
+	Username      string            `json:"username"`
+	Password      string            `json:"password"`
+	BearerToken   string            `json:"bearer_token"`
+	HMACSecret    string            `json:"hmac_secret"`
+	HMACHeader    string            `json:"hmac_header"`
+	Body          string            `json:"body"`
+	SuccessCodes  []int             `json:"success_codes"`
+	MessageIDPath string            `json:"message_id_path"`
+}
+
+// Region and Endpoint override the top-level ones for the S3 and DynamoDB
+// backends.
+type State struct {
+	Backend   string `json:"backend"`
+	Bucket    string `json:"bucket"`
+	KeyPrefix string `json:"key_prefix"`
+	Table     string `json:"table"`
+	Path      string `json:"path"`
+	Region    string `json:"region"`
+	Endpoint  string `json:"endpoint"`
+}
+
+func (s State) IsConfigured() bool {
+	return s.Backend != ""
+}
+
+func (s State) check() error {
+	switch s.Backend {
+	case "":
+		return nil
+	case StateBackendS3:
+		if s.Bucket == "" {
+			return fmt.Errorf("source.state.bucket from stdin is either empty or missing")
+		}
+	case StateBackendDynamoDB:
+		if s.Table == "" {
+			return fmt.Errorf("source.state.table from stdin is either empty or missing")
+		}
+	case StateBackendFile:
+		if s.Path == "" {
+			return fmt.Errorf("source.state.path from stdin is either empty or missing")
+		}
+	default:
+		return fmt.Errorf("source.state.backend from stdin must be one of %q, %q or %q", StateBackendS3, StateBackendDynamoDB, StateBackendFile)
+	}
+
+	if s.Endpoint != "" {
+		endpoint, err := url.Parse(s.Endpoint)
+		if err != nil || endpoint.Host == "" {
+			return fmt.Errorf("source.state.endpoint from stdin must be a URL including the scheme and host")
+		}
+	}
+
+	return nil
+}
+
+// Quiet hours are in the contact's time zone, or in UTC when it has none.
+type Contact struct {
+	Number     string     `json:"number"`
+	TimeZone   string     `json:"time_zone"`
+	QuietHours QuietHours `json:"quiet_hours"`
+}
+
+// A window that ends before it starts runs past midnight.
+type QuietHours struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+const clockLayout = "15:04"
+
+func (c Contact) IsQuiet(at time.Time) bool {
+	if c.QuietHours.Start == "" {
+		return false
+	}
+
+	location, err := time.LoadLocation(c.TimeZone)
+	if err != nil {
+		return false
+	}
+
+	start, _ := time.Parse(clockLayout, c.QuietHours.Start)
+	end, _ := time.Parse(clockLayout, c.QuietHours.End)
+
+	local := at.In(location)
+	minute := local.Hour()*60 + local.Minute()
+	startMinute := start.Hour()*60 + start.Minute()
+	endMinute := end.Hour()*60 + end.Minute()
+
+	if startMinute < endMinute {
+		return minute >= startMinute && minute < endMinute
+	}
+	return minute >= startMinute || minute < endMinute
+}
+
+func (c Contact) check(i int, defaultCountryCode string) error {
+	_, err := phonenumber.Normalize(c.Number, defaultCountryCode)
+	if err != nil {
+		return fmt.Errorf("source.contacts[%d].number from stdin is not a valid phone number: %v", i, err)
+	}
+
+	_, err = time.LoadLocation(c.TimeZone)
+	if err != nil {
+		return fmt.Errorf("source.contacts[%d].time_zone from stdin is not a valid IANA time zone: %v", i, err)
+	}
+
+	if c.QuietHours.Start == "" && c.QuietHours.End == "" {
+		return nil
+	}
+
+	start, startErr := time.Parse(clockLayout, c.QuietHours.Start)
+	end, endErr := time.Parse(clockLayout, c.QuietHours.End)
+	if startErr != nil || endErr != nil {
+		return fmt.Errorf("source.contacts[%d].quiet_hours from stdin must have a start and end like \"22:00\"", i)
+	}
+
+	if start.Equal(end) {
+		return fmt.Errorf("source.contacts[%d].quiet_hours from stdin cannot start and end at the same time", i)
+	}
+
+	return nil
+}
+
+type Schedule struct {
+	schedule.Schedule
+	File string `json:"file"`
+}
+
+func (s Schedule) IsConfigured() bool {
+	return s.File != "" || len(s.Layers) > 0 || len(s.Overrides) > 0
+}
+
+func (s Schedule) Check(defaultCountryCode string) error {
+	err := s.Schedule.Check()
+	if err != nil {
+		return err
+	}
+
+	_, err = phonenumber.NormalizeAll(s.Members(), defaultCountryCode)
+	return err
 }
 
 type Params struct {
-	Subscribers []string `json:"subscribers"`
-	Message     string   `json:"message"`
+	Subscribers     []string    `json:"subscribers"`
+	SubscribersFile string      `json:"subscribers_file"`
+	Message         string      `json:"message"`
+	MessageFile     string      `json:"message_file"`
+	Reconcile       bool        `json:"reconcile"`
+	DryRun          bool        `json:"dry_run"`
+	DryRunOffline   bool        `json:"dry_run_offline"`
+	SMSType         string      `json:"sms_type"`
+	MaxPrice        json.Number `json:"max_price"`
+	Overflow        string      `json:"overflow"`
+	WaitForDelivery Duration    `json:"wait_for_delivery"`
+	Ack             bool        `json:"ack"`
+	FailOnOptedOut  bool        `json:"fail_on_opted_out"`
+	OptIn           []string    `json:"opt_in"`
+	Severity        string      `json:"severity"`
+	DedupeWindow    Duration    `json:"dedupe_window"`
+	DedupeKey       string      `json:"dedupe_key"`
+	IdempotencyKey  string      `json:"idempotency_key"`
+}
+
+// Only SNS has topics, so other providers and provider chains are always direct.
+func (s Source) IsDirect() bool {
+	return s.Mode == ModeDirect || s.IsTwilio() || s.IsWebhook() || len(s.Providers) > 0
+}
+
+func (s Source) IsTwilio() bool {
+	return s.Provider == ProviderTwilio
+}
+
+func (s Source) IsWebhook() bool {
+	return s.Provider == ProviderWebhook
+}
+
+// Each entry of source.providers is applied over the top-level source, so the
+// settings it leaves out are inherited.
+func (s Source) ProviderChain() ([]Source, error) {
+	chain := []Source{}
+	for i, entry := range s.Providers {
+		provider := s.inherited()
+		err := json.Unmarshal(entry, &provider)
+		if err != nil {
+			return nil, fmt.Errorf("source.providers[%d] from stdin is not a valid provider: %v", i, err)
+		}
+
+		if provider.Providers != nil {
+			return nil, fmt.Errorf("source.providers[%d] from stdin cannot set providers", i)
+		}
+
+		chain = append(chain, provider)
+	}
+	return chain, nil
+}
+
+// inherited copies the source without sharing anything an entry could change in
+// place.
+func (s Source) inherited() Source {
+	inherited := s
+	inherited.Providers = nil
+	inherited.InboundSenders = append([]string(nil), s.InboundSenders...)
+	inherited.Webhook.SuccessCodes = append([]int(nil), s.Webhook.SuccessCodes...)
+
+	if s.MaxRetries != nil {
+		maxRetries := *s.MaxRetries
+		inherited.MaxRetries = &maxRetries
+	}
+
+	if s.Webhook.Headers != nil {
+		inherited.Webhook.Headers = map[string]string{}
+		for name, value := range s.Webhook.Headers {
+			inherited.Webhook.Headers[name] = value
+		}
+	}
+
+	return inherited
+}
+
+func (s Source) ProviderName() string {
+	switch {
+	case s.IsTwilio():
+		return ProviderTwilio + ":" + s.TwilioAccountSID
+	case s.IsWebhook():
+		webhookURL, err := url.Parse(s.Webhook.URL)
+		if err != nil {
+			return ProviderWebhook
+		}
+		return ProviderWebhook + ":" + webhookURL.Host
+	case s.Region != "":
+		return ProviderSNS + ":" + s.Region
+	default:
+		return ProviderSNS
+	}
+}
+
+func (s SMSConfig) IsDryRun() bool {
+	return s.Source.DryRun || s.Params.DryRun || s.IsDryRunOffline()
+}
+
+func (s SMSConfig) IsCritical() bool {
+	return s.Params.Severity == SeverityCritical
+}
+
+func (s SMSConfig) IsDryRunOffline() bool {
+	return s.Source.DryRunOffline || s.Params.DryRunOffline
+}
+
+func (s SMSConfig) MessageAttributes() sms.MessageAttributes {
+	return sms.MessageAttributes{
+		SenderID: s.Source.SenderID,
+		SMSType:  s.Params.SMSType,
+		MaxPrice: s.Params.MaxPrice.String(),
+	}
 }
 
 func (s SMSConfig) CheckInput() error {
-	if s.Source.AWSAccessKeyID == "" {
-		return fmt.Errorf("source.aws_access_key_id from stdin is either empty or missing")
+	err := s.Source.checkCredentials()
+	if err != nil {
+		return err
+	}
+
+	if s.Source.Endpoint != "" {
+		endpoint, err := url.Parse(s.Source.Endpoint)
+		if err != nil || endpoint.Host == "" {
+			return fmt.Errorf("source.endpoint from stdin must be a URL including the scheme and host")
+		}
+	}
+
+	if s.Source.Mode != "" && s.Source.Mode != ModeTopic && s.Source.Mode != ModeDirect {
+		return fmt.Errorf("source.mode from stdin must be either %q or %q", ModeTopic, ModeDirect)
+	}
+
+	err = s.Source.checkProvider()
+	if err != nil {
+		return err
+	}
+
+	chain, err := s.Source.checkProviderChain()
+	if err != nil {
+		return err
+	}
+
+	if !s.Source.IsDirect() {
+		if s.Source.Topic == "" {
+			return fmt.Errorf("source.topic from stdin is either empty or missing")
+		}
+
+		if len(s.Source.Topic) > 10 {
+			return fmt.Errorf("source.topic from stdin cannot exceed 10 characters")
+		}
+	}
+
+	if s.Source.IsDirect() && s.Params.Reconcile {
+		return fmt.Errorf("params.reconcile from stdin cannot be used when source.mode is %q", ModeDirect)
+	}
+
+	if s.Source.MaxRetries != nil && *s.Source.MaxRetries < 0 {
+		return fmt.Errorf("source.max_retries from stdin cannot be negative")
+	}
+
+	if s.Source.RetryBaseDelay < 0 || s.Source.RetryJitter < 0 || s.Source.RetryDeadline < 0 {
+		return fmt.Errorf("source.retry_base_delay, source.retry_jitter and source.retry_deadline from stdin cannot be negative")
+	}
+
+	if s.Source.SenderID != "" && !senderIDPattern.MatchString(s.Source.SenderID) {
+		return fmt.Errorf("source.sender_id from stdin must be 1 to 11 letters or digits")
+	}
+
+	if s.Source.DefaultCountryCode != "" && !phonenumber.IsCountryCode(s.Source.DefaultCountryCode) {
+		return fmt.Errorf("source.default_country_code from stdin is not a valid country calling code")
+	}
+
+	if s.Params.SMSType != "" && s.Params.SMSType != SMSTypeTransactional && s.Params.SMSType != SMSTypePromotional {
+		return fmt.Errorf("params.sms_type from stdin must be either %q or %q", SMSTypeTransactional, SMSTypePromotional)
+	}
+
+	if s.Params.MaxPrice != "" {
+		maxPrice, err := s.Params.MaxPrice.Float64()
+		if err != nil || maxPrice <= 0 {
+			return fmt.Errorf("params.max_price from stdin must be a positive amount in USD")
+		}
+	}
+
+	if s.Params.WaitForDelivery < 0 {
+		return fmt.Errorf("params.wait_for_delivery from stdin cannot be negative")
+	}
+
+	if s.Params.WaitForDelivery > 0 {
+		for _, source := range append([]Source{s.Source}, chain...) {
+			if source.IsWebhook() {
+				return fmt.Errorf("params.wait_for_delivery from stdin is not supported when source.provider is %q", ProviderWebhook)
+			}
+		}
 	}
 
-	if s.Source.AWSSecretAccessKey == "" {
-		return fmt.Errorf("source.aws_secret_access_key from stdin is either empty or missing")
+	if s.Params.Ack && (s.Source.InboundQueueURL == "" || len(s.Source.InboundSenders) == 0) {
+		return fmt.Errorf("params.ack from stdin requires source.inbound_queue_url and source.inbound_senders")
 	}
 
-	if s.Source.Topic == "" {
-		return fmt.Errorf("source.topic from stdin is either empty or missing")
+	// Truncating would cut off the instructions to reply with the code.
+	if s.Params.Ack && s.Params.Overflow == OverflowTruncate {
+		return fmt.Errorf("params.ack from stdin cannot be used with params.overflow %q", OverflowTruncate)
 	}
 
-	if len(s.Source.Topic) > 10 {
-		return fmt.Errorf("source.topic from stdin cannot exceed 10 characters")
+	if s.Source.InboundQueueURL != "" {
+		err = s.Source.checkInboundSenders()
+		if err != nil {
+			return err
+		}
 	}
 
-	if len(s.Params.Subscribers) == 0 {
+	err = s.Source.State.check()
+	if err != nil {
+		return err
+	}
+
+	if s.Params.DedupeWindow < 0 {
+		return fmt.Errorf("params.dedupe_window from stdin cannot be negative")
+	}
+
+	if s.Params.DedupeWindow > 0 && !s.Source.State.IsConfigured() {
+		return fmt.Errorf("params.dedupe_window from stdin requires source.state")
+	}
+
+	// A suppressed duplicate is never sent, so nobody could acknowledge it.
+	if s.Params.Ack && s.Params.DedupeWindow > 0 {
+		return fmt.Errorf("params.ack from stdin cannot be used with params.dedupe_window")
+	}
+
+	if s.Params.DedupeKey != "" && s.Params.DedupeWindow == 0 {
+		return fmt.Errorf("params.dedupe_key from stdin requires params.dedupe_window")
+	}
+
+	if s.Params.IdempotencyKey != "" && !s.Source.State.IsConfigured() {
+		return fmt.Errorf("params.idempotency_key from stdin requires source.state")
+	}
+
+	switch s.Params.Severity {
+	case "", SeverityNormal, SeverityCritical:
+	default:
+		return fmt.Errorf("params.severity from stdin must be either %q or %q", SeverityNormal, SeverityCritical)
+	}
+
+	if len(s.Source.Contacts) > 0 && !s.Source.IsDirect() {
+		return fmt.Errorf("source.contacts from stdin cannot be used when source.mode is %q", ModeTopic)
+	}
+
+	for i, contact := range s.Source.Contacts {
+		err = contact.check(i, s.Source.DefaultCountryCode)
+		if err != nil {
+			return err
+		}
+	}
+
+	switch s.Params.Overflow {
+	case "", OverflowTruncate, OverflowSplit, OverflowFail:
+	default:
+		return fmt.Errorf("params.overflow from stdin must be one of %q, %q or %q", OverflowTruncate, OverflowSplit, OverflowFail)
+	}
+
+	if s.Source.Schedule.IsConfigured() && !s.Source.IsDirect() {
+		return fmt.Errorf("source.schedule from stdin cannot be used when source.mode is %q", ModeTopic)
+	}
+
+	if s.Source.Schedule.File != "" && (len(s.Source.Schedule.Layers) > 0 || len(s.Source.Schedule.Overrides) > 0) {
+		return fmt.Errorf("source.schedule.file from stdin cannot be set together with source.schedule.layers or source.schedule.overrides")
+	}
+
+	if s.Source.Schedule.IsConfigured() && s.Source.Schedule.File == "" {
+		err = s.Source.Schedule.Check(s.Source.DefaultCountryCode)
+		if err != nil {
+			return fmt.Errorf("error in source.schedule: %v", err)
+		}
+	}
+
+	if len(s.Params.Subscribers) == 0 && s.Params.SubscribersFile == "" && !s.Source.Schedule.IsConfigured() {
 		return fmt.Errorf("params.subscribers from stdin is either empty or missing")
 	}
 
-	if s.Params.Message == "" {
+	if len(s.Params.Subscribers) > 0 && s.Params.SubscribersFile != "" {
+		return fmt.Errorf("params.subscribers and params.subscribers_file from stdin cannot both be set")
+	}
+
+	if s.Params.Message == "" && s.Params.MessageFile == "" {
 		return fmt.Errorf("params.message from stdin is either empty or missing")
 	}
 
+	if s.Params.Message != "" && s.Params.MessageFile != "" {
+		return fmt.Errorf("params.message and params.message_file from stdin cannot both be set")
+	}
+
+	return nil
+}
+
+func (s Source) CheckInbound() error {
+	err := s.checkCredentials()
+	if err != nil {
+		return err
+	}
+
+	queueURL, err := url.Parse(s.InboundQueueURL)
+	if err != nil || queueURL.Host == "" {
+		return fmt.Errorf("source.inbound_queue_url from stdin must be a URL including the scheme and host")
+	}
+
+	_, err = regexp.Compile(s.InboundKeyword)
+	if err != nil {
+		return fmt.Errorf("source.inbound_keyword from stdin is not a valid regular expression: %v", err)
+	}
+
+	return s.checkInboundSenders()
+}
+
+// An allow-list is required for the inbound queue, as anyone can text the
+// origination number.
+func (s Source) checkInboundSenders() error {
+	if len(s.InboundSenders) == 0 {
+		return fmt.Errorf("source.inbound_senders from stdin is either empty or missing")
+	}
+
+	_, err := phonenumber.NormalizeAll(s.InboundSenders, s.DefaultCountryCode)
+	if err != nil {
+		return fmt.Errorf("error in source.inbound_senders: %v", err)
+	}
+
+	return nil
+}
+
+func (s Source) checkProvider() error {
+	switch s.Provider {
+	case "", ProviderSNS:
+		return nil
+	case ProviderTwilio, ProviderWebhook:
+	default:
+		return fmt.Errorf("source.provider from stdin must be one of %q, %q or %q", ProviderSNS, ProviderTwilio, ProviderWebhook)
+	}
+
+	if s.Mode == ModeTopic {
+		return fmt.Errorf("source.mode from stdin must be %q when source.provider is %q", ModeDirect, s.Provider)
+	}
+
+	if s.IsWebhook() {
+		return s.Webhook.check()
+	}
+
+	if s.TwilioAccountSID == "" {
+		return fmt.Errorf("source.twilio_account_sid from stdin is either empty or missing")
+	}
+
+	if s.TwilioAuthToken == "" {
+		return fmt.Errorf("source.twilio_auth_token from stdin is either empty or missing")
+	}
+
+	if s.TwilioFromNumber == "" && s.SenderID == "" {
+		return fmt.Errorf("source.twilio_from_number from stdin is either empty or missing")
+	}
+
+	if s.TwilioFromNumber != "" {
+		_, err := phonenumber.Normalize(s.TwilioFromNumber, s.DefaultCountryCode)
+		if err != nil {
+			return fmt.Errorf("source.twilio_from_number from stdin is not a valid phone number: %v", err)
+		}
+	}
+
+	return nil
+}
+
+func (s Source) checkProviderChain() ([]Source, error) {
+	if len(s.Providers) == 0 {
+		return nil, nil
+	}
+
+	if s.Mode == ModeTopic {
+		return nil, fmt.Errorf("source.mode from stdin must be %q when source.providers is set", ModeDirect)
+	}
+
+	chain, err := s.ProviderChain()
+	if err != nil {
+		return nil, err
+	}
+
+	for i, provider := range chain {
+		if provider.Mode == ModeTopic {
+			return nil, fmt.Errorf("source.providers[%d].mode from stdin must be %q", i, ModeDirect)
+		}
+
+		err = provider.checkCredentials()
+		if err == nil {
+			err = provider.checkProvider()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error in source.providers[%d]: %v", i, err)
+		}
+	}
+
+	return chain, nil
+}
+
+var webhookMethods = map[string]bool{"POST": true, "PUT": true, "PATCH": true, "GET": true}
+
+func (w Webhook) check() error {
+	webhookURL, err := url.Parse(w.URL)
+	if err != nil || webhookURL.Host == "" {
+		return fmt.Errorf("source.webhook.url from stdin must be a URL including the scheme and host")
+	}
+
+	if w.Method != "" && !webhookMethods[w.Method] {
+		return fmt.Errorf("source.webhook.method from stdin must be one of POST, PUT, PATCH or GET")
+	}
+
+	authMethods := 0
+	for _, set := range []bool{w.Username != "", w.BearerToken != "", w.HMACSecret != ""} {
+		if set {
+			authMethods++
+		}
+	}
+	if authMethods > 1 {
+		return fmt.Errorf("only one of source.webhook.username, source.webhook.bearer_token and source.webhook.hmac_secret from stdin can be set")
+	}
+
+	for _, code := range w.SuccessCodes {
+		if code < 100 || code > 599 {
+			return fmt.Errorf("source.webhook.success_codes from stdin must be HTTP status codes, got %d", code)
+		}
+	}
+
+	return nil
+}
+
+var (
+	roleArnPattern         = regexp.MustCompile(`^arn:[\w-]+:iam::\d{12}:role/.+$`)
+	roleSessionNamePattern = regexp.MustCompile(`^[\w+=,.@-]{2,64}$`)
+	senderIDPattern        = regexp.MustCompile(`^[A-Za-z0-9]{1,11}$`)
+)
+
+// The AWS keys can be omitted altogether, in which case credentials are taken
+// from the worker's environment, but not half-specified.
+func (s Source) checkCredentials() error {
+	if s.AWSAccessKeyID != "" || s.AWSSecretAccessKey != "" || s.AWSSessionToken != "" {
+		if s.AWSAccessKeyID == "" {
+			return fmt.Errorf("source.aws_access_key_id from stdin is either empty or missing")
+		}
+
+		if s.AWSSecretAccessKey == "" {
+			return fmt.Errorf("source.aws_secret_access_key from stdin is either empty or missing")
+		}
+	}
+
+	if s.AssumeRoleArn == "" {
+		if s.AssumeRoleExternalID != "" {
+			return fmt.Errorf("source.assume_role_external_id from stdin requires source.assume_role_arn")
+		}
+
+		if s.AssumeRoleSessionName != "" {
+			return fmt.Errorf("source.assume_role_session_name from stdin requires source.assume_role_arn")
+		}
+
+		return nil
+	}
+
+	if !roleArnPattern.MatchString(s.AssumeRoleArn) {
+		return fmt.Errorf("source.assume_role_arn from stdin is not a valid IAM role ARN")
+	}
+
+	if s.AssumeRoleSessionName != "" && !roleSessionNamePattern.MatchString(s.AssumeRoleSessionName) {
+		return fmt.Errorf("source.assume_role_session_name from stdin must be 2 to 64 letters, digits or any of +=,.@_-")
+	}
+
 	return nil
 }
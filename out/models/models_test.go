@@ -1,6 +1,10 @@
 package models_test
 
 import (
+	"encoding/json"
+	"time"
+
+	"github.com/nickwei84/sms-resource/lib/schedule"
 	"github.com/nickwei84/sms-resource/out/models"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -39,6 +43,62 @@ var _ = Describe("SMSConfig", func() {
 			Expect(err).Should(MatchError("source.aws_secret_access_key from stdin is either empty or missing"))
 		})
 
+		It("should not return an error if AWS keys are omitted altogether", func() {
+			config.Source.AWSAccessKeyID = ""
+			config.Source.AWSSecretAccessKey = ""
+			err := config.CheckInput()
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should return an error if AWS session token is provided without keys", func() {
+			config.Source.AWSAccessKeyID = ""
+			config.Source.AWSSecretAccessKey = ""
+			config.Source.AWSSessionToken = "token"
+			err := config.CheckInput()
+			Expect(err).Should(MatchError("source.aws_access_key_id from stdin is either empty or missing"))
+		})
+
+		It("should not return an error if AWS session token is provided with keys", func() {
+			config.Source.AWSSessionToken = "token"
+			err := config.CheckInput()
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should return an error if assume role ARN is not an IAM role ARN", func() {
+			config.Source.AssumeRoleArn = "arn:aws:iam::123456789012:user/concourse"
+			err := config.CheckInput()
+			Expect(err).Should(MatchError("source.assume_role_arn from stdin is not a valid IAM role ARN"))
+		})
+
+		It("should not return an error if assume role options are valid", func() {
+			config.Source.AWSAccessKeyID = ""
+			config.Source.AWSSecretAccessKey = ""
+			config.Source.AssumeRoleArn = "arn:aws:iam::123456789012:role/sms-publisher"
+			config.Source.AssumeRoleExternalID = "external-id"
+			config.Source.AssumeRoleSessionName = "concourse"
+			err := config.CheckInput()
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should return an error if assume role session name is invalid", func() {
+			config.Source.AssumeRoleArn = "arn:aws:iam::123456789012:role/sms-publisher"
+			config.Source.AssumeRoleSessionName = "concourse build"
+			err := config.CheckInput()
+			Expect(err).Should(MatchError("source.assume_role_session_name from stdin must be 2 to 64 letters, digits or any of +=,.@_-"))
+		})
+
+		It("should return an error if external ID is provided without a role", func() {
+			config.Source.AssumeRoleExternalID = "external-id"
+			err := config.CheckInput()
+			Expect(err).Should(MatchError("source.assume_role_external_id from stdin requires source.assume_role_arn"))
+		})
+
+		It("should return an error if session name is provided without a role", func() {
+			config.Source.AssumeRoleSessionName = "concourse"
+			err := config.CheckInput()
+			Expect(err).Should(MatchError("source.assume_role_session_name from stdin requires source.assume_role_arn"))
+		})
+
 		It("should return an error if topic is missing", func() {
 			config.Source.Topic = ""
 			err := config.CheckInput()
@@ -51,21 +111,600 @@ var _ = Describe("SMSConfig", func() {
 			Expect(err).Should(MatchError("source.topic from stdin cannot exceed 10 characters"))
 		})
 
+		It("should return an error if endpoint is not a URL", func() {
+			config.Source.Endpoint = "localhost:4575"
+			err := config.CheckInput()
+			Expect(err).Should(MatchError("source.endpoint from stdin must be a URL including the scheme and host"))
+		})
+
+		It("should not return an error if endpoint is a URL", func() {
+			config.Source.Endpoint = "http://localhost:4575"
+			err := config.CheckInput()
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		Context("when the provider is Twilio", func() {
+			BeforeEach(func() {
+				config.Source = models.Source{
+					Provider:         models.ProviderTwilio,
+					TwilioAccountSID: "AC123",
+					TwilioAuthToken:  "token123",
+					TwilioFromNumber: "+14155550100",
+				}
+			})
+
+			It("should not require a topic", func() {
+				err := config.CheckInput()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(config.Source.IsDirect()).To(BeTrue())
+			})
+
+			It("should return an error if the mode is topic", func() {
+				config.Source.Mode = models.ModeTopic
+				err := config.CheckInput()
+				Expect(err).Should(MatchError(`source.mode from stdin must be "direct" when source.provider is "twilio"`))
+			})
+
+			It("should return an error if the account SID is missing", func() {
+				config.Source.TwilioAccountSID = ""
+				err := config.CheckInput()
+				Expect(err).Should(MatchError("source.twilio_account_sid from stdin is either empty or missing"))
+			})
+
+			It("should return an error if the auth token is missing", func() {
+				config.Source.TwilioAuthToken = ""
+				err := config.CheckInput()
+				Expect(err).Should(MatchError("source.twilio_auth_token from stdin is either empty or missing"))
+			})
+
+			It("should return an error if there is nothing to send from", func() {
+				config.Source.TwilioFromNumber = ""
+				err := config.CheckInput()
+				Expect(err).Should(MatchError("source.twilio_from_number from stdin is either empty or missing"))
+			})
+
+			It("should allow sending from a sender ID instead of a number", func() {
+				config.Source.TwilioFromNumber = ""
+				config.Source.SenderID = "Concourse"
+				err := config.CheckInput()
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("should return an error if the from number is not a phone number", func() {
+				config.Source.TwilioFromNumber = "twilio"
+				err := config.CheckInput()
+				Expect(err).Should(MatchError(HavePrefix("source.twilio_from_number from stdin is not a valid phone number: ")))
+			})
+		})
+
+		Context("when the provider is a webhook", func() {
+			BeforeEach(func() {
+				config.Source = models.Source{
+					Provider: models.ProviderWebhook,
+					Webhook:  models.Webhook{URL: "https://sms.example.com/send"},
+				}
+			})
+
+			It("should not require a topic", func() {
+				err := config.CheckInput()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(config.Source.IsDirect()).To(BeTrue())
+			})
+
+			It("should return an error if the URL is missing", func() {
+				config.Source.Webhook.URL = "sms.example.com/send"
+				err := config.CheckInput()
+				Expect(err).Should(MatchError("source.webhook.url from stdin must be a URL including the scheme and host"))
+			})
+
+			It("should return an error if the method is not supported", func() {
+				config.Source.Webhook.Method = "DELETE"
+				err := config.CheckInput()
+				Expect(err).Should(MatchError("source.webhook.method from stdin must be one of POST, PUT, PATCH or GET"))
+			})
+
+			It("should return an error if more than one authentication method is set", func() {
+				config.Source.Webhook.BearerToken = "token123"
+				config.Source.Webhook.HMACSecret = "secret123"
+				err := config.CheckInput()
+				Expect(err).Should(MatchError("only one of source.webhook.username, source.webhook.bearer_token and source.webhook.hmac_secret from stdin can be set"))
+			})
+
+			It("should return an error if a success code is not an HTTP status code", func() {
+				config.Source.Webhook.SuccessCodes = []int{200, 2000}
+				err := config.CheckInput()
+				Expect(err).Should(MatchError("source.webhook.success_codes from stdin must be HTTP status codes, got 2000"))
+			})
+
+			It("should return an error if waiting for delivery", func() {
+				config.Params.WaitForDelivery = models.Duration(time.Minute)
+				err := config.CheckInput()
+				Expect(err).Should(MatchError(`params.wait_for_delivery from stdin is not supported when source.provider is "webhook"`))
+			})
+		})
+
+		Context("when there is a chain of providers", func() {
+			BeforeEach(func() {
+				config.Source.Providers = []json.RawMessage{
+					json.RawMessage(`{"region": "us-west-2"}`),
+					json.RawMessage(`{"provider": "twilio", "twilio_account_sid": "AC123", "twilio_auth_token": "token123", "twilio_from_number": "+14155550100"}`),
+				}
+			})
+
+			It("should send to each phone number directly", func() {
+				err := config.CheckInput()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(config.Source.IsDirect()).To(BeTrue())
+			})
+
+			It("should return an error if the mode is topic", func() {
+				config.Source.Mode = models.ModeTopic
+				err := config.CheckInput()
+				Expect(err).Should(MatchError(`source.mode from stdin must be "direct" when source.providers is set`))
+			})
+
+			It("should return an error if a provider is invalid", func() {
+				config.Source.Providers[1] = json.RawMessage(`{"provider": "twilio"}`)
+				err := config.CheckInput()
+				Expect(err).Should(MatchError("error in source.providers[1]: source.twilio_account_sid from stdin is either empty or missing"))
+			})
+
+			It("should return an error if a provider sets providers", func() {
+				config.Source.Providers[0] = json.RawMessage(`{"providers": []}`)
+				err := config.CheckInput()
+				Expect(err).Should(MatchError("source.providers[0] from stdin cannot set providers"))
+			})
+
+			It("should return an error if waiting for delivery through a webhook", func() {
+				config.Source.Providers[1] = json.RawMessage(`{"provider": "webhook", "webhook": {"url": "https://sms.example.com"}}`)
+				config.Params.WaitForDelivery = models.Duration(time.Minute)
+				err := config.CheckInput()
+				Expect(err).Should(MatchError(`params.wait_for_delivery from stdin is not supported when source.provider is "webhook"`))
+			})
+		})
+
+		Context("when there are contacts", func() {
+			BeforeEach(func() {
+				config.Source.Mode = models.ModeDirect
+				config.Source.Contacts = []models.Contact{
+					{Number: "+14151234567", TimeZone: "America/New_York", QuietHours: models.QuietHours{Start: "22:00", End: "07:00"}},
+				}
+			})
+
+			It("should not return an error", func() {
+				err := config.CheckInput()
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("should return an error if the mode is topic", func() {
+				config.Source.Mode = models.ModeTopic
+				err := config.CheckInput()
+				Expect(err).Should(MatchError(`source.contacts from stdin cannot be used when source.mode is "topic"`))
+			})
+
+			It("should return an error if a number is invalid", func() {
+				config.Source.Contacts[0].Number = "call me"
+				err := config.CheckInput()
+				Expect(err).Should(MatchError(HavePrefix("source.contacts[0].number from stdin is not a valid phone number: ")))
+			})
+
+			It("should return an error if a time zone is unknown", func() {
+				config.Source.Contacts[0].TimeZone = "Mars/Olympus_Mons"
+				err := config.CheckInput()
+				Expect(err).Should(MatchError(HavePrefix("source.contacts[0].time_zone from stdin is not a valid IANA time zone: ")))
+			})
+
+			It("should return an error if the quiet hours are not times", func() {
+				config.Source.Contacts[0].QuietHours.End = "7am"
+				err := config.CheckInput()
+				Expect(err).Should(MatchError(`source.contacts[0].quiet_hours from stdin must have a start and end like "22:00"`))
+			})
+
+			It("should return an error if the quiet hours are empty", func() {
+				config.Source.Contacts[0].QuietHours.End = "22:00"
+				err := config.CheckInput()
+				Expect(err).Should(MatchError("source.contacts[0].quiet_hours from stdin cannot start and end at the same time"))
+			})
+		})
+
+		Context("when there is an on-call schedule", func() {
+			BeforeEach(func() {
+				config.Source.Mode = models.ModeDirect
+				config.Source.Schedule = models.Schedule{
+					Schedule: schedule.Schedule{
+						TimeZone: "Europe/London",
+						Layers: []schedule.Layer{
+							{Members: []string{"+447700900123", "+447700900456"}, Start: "2026-01-05", Handoff: "09:00"},
+						},
+					},
+				}
+				config.Params.Subscribers = nil
+			})
+
+			It("should not require subscribers", func() {
+				err := config.CheckInput()
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("should return an error if the mode is topic", func() {
+				config.Source.Mode = models.ModeTopic
+				err := config.CheckInput()
+				Expect(err).Should(MatchError(`source.schedule from stdin cannot be used when source.mode is "topic"`))
+			})
+
+			It("should return an error if the schedule is invalid", func() {
+				config.Source.Schedule.Layers[0].Handoff = "9am"
+				err := config.CheckInput()
+				Expect(err).Should(MatchError(`error in source.schedule: layers[0].handoff must be a time like "09:00"`))
+			})
+
+			It("should return an error if a member is not a phone number", func() {
+				config.Source.Schedule.Layers[0].Members[1] = "call me"
+				err := config.CheckInput()
+				Expect(err).Should(MatchError(HavePrefix("error in source.schedule: ")))
+			})
+
+			It("should return an error if a file is given as well", func() {
+				config.Source.Schedule.File = "rota/schedule.json"
+				err := config.CheckInput()
+				Expect(err).Should(MatchError("source.schedule.file from stdin cannot be set together with source.schedule.layers or source.schedule.overrides"))
+			})
+
+			It("should not check a schedule given as a file", func() {
+				config.Source.Schedule = models.Schedule{File: "rota/schedule.json"}
+				err := config.CheckInput()
+				Expect(err).NotTo(HaveOccurred())
+			})
+		})
+
+		Context("when duplicates are suppressed", func() {
+			BeforeEach(func() {
+				config.Source.State = models.State{Backend: models.StateBackendS3, Bucket: "sms-state"}
+				config.Params.DedupeWindow = models.Duration(time.Hour)
+			})
+
+			It("should not return an error", func() {
+				err := config.CheckInput()
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("should return an error if there is no state store", func() {
+				config.Source.State = models.State{}
+				err := config.CheckInput()
+				Expect(err).Should(MatchError("params.dedupe_window from stdin requires source.state"))
+			})
+
+			It("should return an error if an acknowledgement is requested", func() {
+				config.Source.InboundQueueURL = "https://sqs.us-east-1.amazonaws.com/123456789012/sms-replies"
+				config.Source.InboundSenders = []string{"+14151234567"}
+				config.Params.Ack = true
+				err := config.CheckInput()
+				Expect(err).Should(MatchError("params.ack from stdin cannot be used with params.dedupe_window"))
+			})
+
+			It("should return an error if a dedupe key is given without a window", func() {
+				config.Params.DedupeWindow = 0
+				config.Params.DedupeKey = "build-failed"
+				err := config.CheckInput()
+				Expect(err).Should(MatchError("params.dedupe_key from stdin requires params.dedupe_window"))
+			})
+
+			It("should return an error if the state backend is unknown", func() {
+				config.Source.State.Backend = "redis"
+				err := config.CheckInput()
+				Expect(err).Should(MatchError(`source.state.backend from stdin must be one of "s3", "dynamodb" or "file"`))
+			})
+
+			It("should return an error if the S3 bucket is missing", func() {
+				config.Source.State.Bucket = ""
+				err := config.CheckInput()
+				Expect(err).Should(MatchError("source.state.bucket from stdin is either empty or missing"))
+			})
+
+			It("should return an error if the DynamoDB table is missing", func() {
+				config.Source.State = models.State{Backend: models.StateBackendDynamoDB}
+				err := config.CheckInput()
+				Expect(err).Should(MatchError("source.state.table from stdin is either empty or missing"))
+			})
+
+			It("should return an error if the file path is missing", func() {
+				config.Source.State = models.State{Backend: models.StateBackendFile}
+				err := config.CheckInput()
+				Expect(err).Should(MatchError("source.state.path from stdin is either empty or missing"))
+			})
+		})
+
+		It("should return an error if an idempotency key is given without a state store", func() {
+			config.Params.IdempotencyKey = "notify-on-call"
+			err := config.CheckInput()
+			Expect(err).Should(MatchError("params.idempotency_key from stdin requires source.state"))
+		})
+
+		It("should return an error if the severity is unknown", func() {
+			config.Params.Severity = "urgent"
+			err := config.CheckInput()
+			Expect(err).Should(MatchError(`params.severity from stdin must be either "normal" or "critical"`))
+		})
+
+		It("should return an error if the provider is unknown", func() {
+			config.Source.Provider = "carrier-pigeon"
+			err := config.CheckInput()
+			Expect(err).Should(MatchError(`source.provider from stdin must be one of "sns", "twilio" or "webhook"`))
+		})
+
+		It("should return an error if mode is unknown", func() {
+			config.Source.Mode = "broadcast"
+			err := config.CheckInput()
+			Expect(err).Should(MatchError(`source.mode from stdin must be either "topic" or "direct"`))
+		})
+
+		It("should not return an error if topic is missing in direct mode", func() {
+			config.Source.Mode = "direct"
+			config.Source.Topic = ""
+			err := config.CheckInput()
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should return an error if reconcile is requested in direct mode", func() {
+			config.Source.Mode = "direct"
+			config.Params.Reconcile = true
+			err := config.CheckInput()
+			Expect(err).Should(MatchError(`params.reconcile from stdin cannot be used when source.mode is "direct"`))
+		})
+
+		It("should return an error if max retries is negative", func() {
+			maxRetries := -1
+			config.Source.MaxRetries = &maxRetries
+			err := config.CheckInput()
+			Expect(err).Should(MatchError("source.max_retries from stdin cannot be negative"))
+		})
+
+		It("should return an error if a retry duration is negative", func() {
+			config.Source.RetryDeadline = models.Duration(-time.Second)
+			err := config.CheckInput()
+			Expect(err).Should(MatchError("source.retry_base_delay, source.retry_jitter and source.retry_deadline from stdin cannot be negative"))
+		})
+
+		It("should return an error if wait for delivery is negative", func() {
+			config.Params.WaitForDelivery = models.Duration(-time.Second)
+			err := config.CheckInput()
+			Expect(err).Should(MatchError("params.wait_for_delivery from stdin cannot be negative"))
+		})
+
+		It("should return an error if an acknowledgement is requested without an inbound queue", func() {
+			config.Params.Ack = true
+			config.Source.InboundSenders = []string{"+14151234567"}
+			err := config.CheckInput()
+			Expect(err).Should(MatchError("params.ack from stdin requires source.inbound_queue_url and source.inbound_senders"))
+		})
+
+		It("should return an error if an acknowledgement is requested without inbound senders", func() {
+			config.Params.Ack = true
+			config.Source.InboundQueueURL = "https://sqs.us-east-1.amazonaws.com/123456789012/sms-replies"
+			err := config.CheckInput()
+			Expect(err).Should(MatchError("params.ack from stdin requires source.inbound_queue_url and source.inbound_senders"))
+		})
+
+		It("should return an error if an acknowledgement is requested with truncated messages", func() {
+			config.Params.Ack = true
+			config.Params.Overflow = models.OverflowTruncate
+			config.Source.InboundQueueURL = "https://sqs.us-east-1.amazonaws.com/123456789012/sms-replies"
+			config.Source.InboundSenders = []string{"+14151234567"}
+			err := config.CheckInput()
+			Expect(err).Should(MatchError(`params.ack from stdin cannot be used with params.overflow "truncate"`))
+		})
+
+		It("should return an error if there is an inbound queue without inbound senders", func() {
+			config.Source.InboundQueueURL = "https://sqs.us-east-1.amazonaws.com/123456789012/sms-replies"
+			err := config.CheckInput()
+			Expect(err).Should(MatchError("source.inbound_senders from stdin is either empty or missing"))
+		})
+
+		It("should return an error if sender ID is too long", func() {
+			config.Source.SenderID = "ConcourseCI1"
+			err := config.CheckInput()
+			Expect(err).Should(MatchError("source.sender_id from stdin must be 1 to 11 letters or digits"))
+		})
+
+		It("should return an error if sender ID is not alphanumeric", func() {
+			config.Source.SenderID = "CI-Alerts"
+			err := config.CheckInput()
+			Expect(err).Should(MatchError("source.sender_id from stdin must be 1 to 11 letters or digits"))
+		})
+
+		It("should return an error if default country code is not assigned", func() {
+			config.Source.DefaultCountryCode = "+80"
+			err := config.CheckInput()
+			Expect(err).Should(MatchError("source.default_country_code from stdin is not a valid country calling code"))
+		})
+
+		It("should return an error if SMS type is unknown", func() {
+			config.Params.SMSType = "Urgent"
+			err := config.CheckInput()
+			Expect(err).Should(MatchError(`params.sms_type from stdin must be either "Transactional" or "Promotional"`))
+		})
+
+		It("should return an error if max price is not positive", func() {
+			config.Params.MaxPrice = "-1"
+			err := config.CheckInput()
+			Expect(err).Should(MatchError("params.max_price from stdin must be a positive amount in USD"))
+		})
+
+		It("should not return an error if SMS delivery attributes are valid", func() {
+			config.Source.SenderID = "Concourse"
+			config.Params.SMSType = "Transactional"
+			config.Params.MaxPrice = "0.50"
+			err := config.CheckInput()
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should return an error if overflow is unknown", func() {
+			config.Params.Overflow = "wrap"
+			err := config.CheckInput()
+			Expect(err).Should(MatchError(`params.overflow from stdin must be one of "truncate", "split" or "fail"`))
+		})
+
 		It("should return an error if no subscribers are provided", func() {
 			config.Params.Subscribers = []string{}
 			err := config.CheckInput()
 			Expect(err).Should(MatchError("params.subscribers from stdin is either empty or missing"))
 		})
 
+		It("should not return an error if subscribers are provided by file", func() {
+			config.Params.Subscribers = []string{}
+			config.Params.SubscribersFile = "output/subscribers"
+			err := config.CheckInput()
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should return an error if subscribers are provided both inline and by file", func() {
+			config.Params.SubscribersFile = "output/subscribers"
+			err := config.CheckInput()
+			Expect(err).Should(MatchError("params.subscribers and params.subscribers_file from stdin cannot both be set"))
+		})
+
 		It("should return an error if message is missing", func() {
 			config.Params.Message = ""
 			err := config.CheckInput()
 			Expect(err).Should(MatchError("params.message from stdin is either empty or missing"))
 		})
 
+		It("should not return an error if message is provided by file", func() {
+			config.Params.Message = ""
+			config.Params.MessageFile = "output/message"
+			err := config.CheckInput()
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should return an error if message is provided both inline and by file", func() {
+			config.Params.MessageFile = "output/message"
+			err := config.CheckInput()
+			Expect(err).Should(MatchError("params.message and params.message_file from stdin cannot both be set"))
+		})
+
 		It("should not return an error if all fields are valid", func() {
 			err := config.CheckInput()
 			Expect(err).NotTo(HaveOccurred())
 		})
 	})
 })
+
+var _ = Describe("Source", func() {
+	Describe("CheckInbound", func() {
+		var source models.Source
+
+		BeforeEach(func() {
+			source = models.Source{
+				InboundQueueURL: "https://sqs.us-east-1.amazonaws.com/123456789012/replies",
+				InboundSenders:  []string{"+14151234567"},
+				InboundKeyword:  `^DEPLOY (\w+)$`,
+			}
+		})
+
+		It("should accept a valid configuration", func() {
+			Expect(source.CheckInbound()).To(Succeed())
+		})
+
+		It("should return an error if the queue URL is missing", func() {
+			source.InboundQueueURL = ""
+			Expect(source.CheckInbound()).To(MatchError("source.inbound_queue_url from stdin must be a URL including the scheme and host"))
+		})
+
+		It("should return an error if the keyword is not a valid regular expression", func() {
+			source.InboundKeyword = "DEPLOY ("
+			Expect(source.CheckInbound()).To(MatchError(HavePrefix("source.inbound_keyword from stdin is not a valid regular expression: ")))
+		})
+
+		It("should return an error if a sender is not a valid phone number", func() {
+			source.InboundSenders = []string{"abc"}
+			Expect(source.CheckInbound()).To(MatchError("error in source.inbound_senders: 1 invalid phone number(s):\n" +
+				`  "abc": contains characters other than digits`))
+		})
+
+		It("should return an error if there are no senders", func() {
+			source.InboundSenders = nil
+			Expect(source.CheckInbound()).To(MatchError("source.inbound_senders from stdin is either empty or missing"))
+		})
+
+		It("should return an error if the credentials are half specified", func() {
+			source.AWSAccessKeyID = "key123"
+			Expect(source.CheckInbound()).To(MatchError("source.aws_secret_access_key from stdin is either empty or missing"))
+		})
+	})
+
+	Describe("Contact", func() {
+		Describe("IsQuiet", func() {
+			It("should use the quiet hours in the contact's time zone", func() {
+				contact := models.Contact{TimeZone: "America/New_York", QuietHours: models.QuietHours{Start: "22:00", End: "07:00"}}
+				Expect(contact.IsQuiet(time.Date(2026, 10, 18, 3, 0, 0, 0, time.UTC))).To(BeTrue())
+				Expect(contact.IsQuiet(time.Date(2026, 10, 18, 10, 59, 0, 0, time.UTC))).To(BeTrue())
+				Expect(contact.IsQuiet(time.Date(2026, 10, 18, 11, 0, 0, 0, time.UTC))).To(BeFalse())
+				Expect(contact.IsQuiet(time.Date(2026, 10, 18, 15, 0, 0, 0, time.UTC))).To(BeFalse())
+			})
+
+			It("should handle quiet hours within a day", func() {
+				contact := models.Contact{QuietHours: models.QuietHours{Start: "12:00", End: "13:30"}}
+				Expect(contact.IsQuiet(time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC))).To(BeTrue())
+				Expect(contact.IsQuiet(time.Date(2026, 10, 18, 13, 30, 0, 0, time.UTC))).To(BeFalse())
+				Expect(contact.IsQuiet(time.Date(2026, 10, 18, 23, 0, 0, 0, time.UTC))).To(BeFalse())
+			})
+
+			It("should never be quiet without quiet hours", func() {
+				Expect(models.Contact{TimeZone: "Europe/London"}.IsQuiet(time.Now())).To(BeFalse())
+			})
+		})
+	})
+
+	Describe("ProviderChain", func() {
+		It("should inherit the settings a provider leaves out from the top-level source", func() {
+			maxRetries := 3
+			source := models.Source{
+				AWSAccessKeyID:     "key123",
+				AWSSecretAccessKey: "secretabc",
+				Region:             "us-east-1",
+				MaxRetries:         &maxRetries,
+				Providers: []json.RawMessage{
+					json.RawMessage(`{}`),
+					json.RawMessage(`{"region": "us-west-2", "max_retries": 1}`),
+				},
+			}
+
+			chain, err := source.ProviderChain()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(chain).To(HaveLen(2))
+			Expect(chain[0].ProviderName()).To(Equal("sns:us-east-1"))
+			Expect(chain[1].ProviderName()).To(Equal("sns:us-west-2"))
+			Expect(chain[1].AWSAccessKeyID).To(Equal("key123"))
+			Expect(*chain[1].MaxRetries).To(Equal(1))
+			Expect(*source.MaxRetries).To(Equal(3))
+			Expect(chain[1].Providers).To(BeNil())
+		})
+
+		It("should return an error if a provider is not an object", func() {
+			source := models.Source{Providers: []json.RawMessage{json.RawMessage(`"twilio"`)}}
+			_, err := source.ProviderChain()
+			Expect(err).To(MatchError(HavePrefix("source.providers[0] from stdin is not a valid provider: ")))
+		})
+	})
+})
+
+var _ = Describe("Duration", func() {
+	It("should unmarshal a duration string", func() {
+		var duration models.Duration
+		err := json.Unmarshal([]byte(`"1m30s"`), &duration)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(time.Duration(duration)).To(Equal(90 * time.Second))
+	})
+
+	It("should return an error if the duration is not a string", func() {
+		var duration models.Duration
+		err := json.Unmarshal([]byte(`30`), &duration)
+		Expect(err).To(MatchError(`duration must be a string such as "30s", got 30`))
+	})
+
+	It("should return an error if the duration cannot be parsed", func() {
+		var duration models.Duration
+		err := json.Unmarshal([]byte(`"soon"`), &duration)
+		Expect(err).To(HaveOccurred())
+	})
+})
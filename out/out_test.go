@@ -1,13 +1,20 @@
 package main_test
 
 import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"strconv"
 	"strings"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"github.com/onsi/gomega/gbytes"
 	"github.com/onsi/gomega/gexec"
+	"github.com/onsi/gomega/ghttp"
 )
 
 var _ = Describe("Out", func() {
@@ -80,5 +87,663 @@ var _ = Describe("Out", func() {
 				Eventually(session.Out).Should(gbytes.Say(""))
 			})
 		})
+
+		Context("because the message file does not exist in the build directory", func() {
+			var buildDir string
+
+			BeforeEach(func() {
+				var err error
+				buildDir, err = ioutil.TempDir("", "sms-resource-out")
+				Expect(err).NotTo(HaveOccurred())
+				cmd = exec.Command(pathToBuiltBinary, buildDir)
+			})
+
+			AfterEach(func() {
+				os.RemoveAll(buildDir)
+			})
+
+			It("should output an error to stderr", func() {
+				cmd.Stdin = strings.NewReader(`
+{
+	"source": {
+		"aws_access_key_id": "key123",
+		"aws_secret_access_key": "secret123",
+		"topic": "concourse"
+	},
+	"params": {
+		"subscribers": [
+			"14151234567"
+		],
+		"message_file": "output/message.txt"
+	}
+}
+`)
+				session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(session).Should(gexec.Exit(1))
+				Eventually(session.Err).Should(gbytes.Say("error reading params.message_file: "))
+				Eventually(session.Out).Should(gbytes.Say(""))
+			})
+		})
+
+		Context("because subscribers are not valid phone numbers", func() {
+			It("should output all invalid phone numbers to stderr", func() {
+				cmd.Stdin = strings.NewReader(`
+{
+	"source": {
+		"aws_access_key_id": "key123",
+		"aws_secret_access_key": "secret123",
+		"topic": "concourse"
+	},
+	"params": {
+		"subscribers": [
+			"1234567890",
+			"14151234567",
+			"call me"
+		],
+		"message": "hello!"
+	}
+}
+`)
+				session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(session).Should(gexec.Exit(1))
+				Eventually(session.Err).Should(gbytes.Say(`error in params.subscribers: 2 invalid phone number\(s\):`))
+				Eventually(session.Err).Should(gbytes.Say(`"1234567890": must have 10 digits after country code \+1`))
+				Eventually(session.Err).Should(gbytes.Say(`"call me": contains characters other than digits`))
+				Eventually(session.Out).Should(gbytes.Say(""))
+			})
+		})
+
+		Context("because the message is not a valid template", func() {
+			It("should output an error to stderr", func() {
+				cmd.Stdin = strings.NewReader(`
+{
+	"source": {
+		"aws_access_key_id": "key123",
+		"aws_secret_access_key": "secret123",
+		"topic": "concourse"
+	},
+	"params": {
+		"subscribers": [
+			"14151234567"
+		],
+		"message": "{{.BuildJobName} failed"
+	}
+}
+`)
+				session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(session).Should(gexec.Exit(1))
+				Eventually(session.Err).Should(gbytes.Say("error parsing params.message as a template: "))
+				Eventually(session.Out).Should(gbytes.Say(""))
+			})
+		})
+	})
+
+	Context("when stdin input is valid", func() {
+		var (
+			sns      *fakeSNS
+			source   string
+			params   string
+			exitCode int
+			session  *gexec.Session
+		)
+
+		BeforeEach(func() {
+			sns = newFakeSNS()
+			exitCode = 0
+			source = fmt.Sprintf(`
+		"aws_access_key_id": "key123",
+		"aws_secret_access_key": "secret123",
+		"topic": "concourse",
+		"region": "eu-west-1",
+		"endpoint": %q,
+		"disable_ssl": true`, sns.URL())
+			params = `
+		"subscribers": ["14151234567", "+1 (650) 123-4567", "+16501234567"],
+		"message": "hello!"`
+		})
+
+		AfterEach(func() {
+			sns.Close()
+		})
+
+		JustBeforeEach(func() {
+			cmd.Stdin = strings.NewReader(fmt.Sprintf(`{"source": {%s}, "params": {%s}}`, source, params))
+			var err error
+			session, err = gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(session).Should(gexec.Exit(exitCode))
+		})
+
+		It("should subscribe the subscribers and publish the message to the topic", func() {
+			Expect(sns.Actions()).To(Equal([]string{
+				"CheckIfPhoneNumberIsOptedOut",
+				"CheckIfPhoneNumberIsOptedOut",
+				"CreateTopic",
+				"SetTopicAttributes",
+				"ListSubscriptionsByTopic",
+				"Subscribe",
+				"Subscribe",
+				"Publish",
+			}))
+
+			subscribeRequests := sns.RequestsFor("Subscribe")
+			Expect(subscribeRequests[0].Get("Endpoint")).To(Equal("+14151234567"))
+			Expect(subscribeRequests[1].Get("Endpoint")).To(Equal("+16501234567"))
+
+			publishRequest := sns.RequestsFor("Publish")[0]
+			Expect(publishRequest.Get("TopicArn")).To(Equal("arn:aws:sns:us-east-1:123456789012:concourse"))
+			Expect(publishRequest.Get("Message")).To(Equal("hello!"))
+		})
+
+		It("should output the version to stdout", func() {
+			Expect(session.Out).To(gbytes.Say(`"Version":{"Time":"[^"]+","MessageID":"message-8",` +
+				`"TopicArn":"arn:aws:sns:us-east-1:123456789012:concourse","Recipients":"\+\*{7}4567,\+\*{7}4567","RecipientCount":"0","Body":"hello!"}`))
+		})
+
+		It("should output what was sent as metadata", func() {
+			Expect(session.Out).To(gbytes.Say(`"Metadata":\[` +
+				`{"Name":"topic_arn","Value":"arn:aws:sns:us-east-1:123456789012:concourse"},` +
+				`{"Name":"message_id","Value":"message-8"},` +
+				`{"Name":"recipient_count","Value":"0"},` +
+				`{"Name":"added","Value":"\+\*{7}4567,\+\*{7}4567"},` +
+				`{"Name":"unchanged","Value":""},` +
+				`{"Name":"pending_confirmation","Value":"2"},` +
+				`{"Name":"encoding","Value":"GSM-7"},` +
+				`{"Name":"segments","Value":"1"}`))
+		})
+
+		Context("when SMS delivery attributes are configured", func() {
+			BeforeEach(func() {
+				source += `,
+		"sender_id": "Concourse"`
+				params += `,
+		"sms_type": "Transactional",
+		"max_price": 0.5`
+			})
+
+			It("should publish the message with the SMS message attributes", func() {
+				publishRequest := sns.RequestsFor("Publish")[0]
+				attributes := map[string]string{}
+				for i := 1; i <= 3; i++ {
+					name := publishRequest.Get(fmt.Sprintf("MessageAttributes.entry.%d.Name", i))
+					attributes[name] = publishRequest.Get(fmt.Sprintf("MessageAttributes.entry.%d.Value.StringValue", i))
+				}
+				Expect(attributes).To(Equal(map[string]string{
+					"AWS.SNS.SMS.SenderID": "Concourse",
+					"AWS.SNS.SMS.SMSType":  "Transactional",
+					"AWS.SNS.SMS.MaxPrice": "0.5",
+				}))
+			})
+		})
+
+		It("should report the number of attempts", func() {
+			Expect(session.Out).To(gbytes.Say(`{"Name":"attempts","Value":"6"}`))
+		})
+
+		Context("when SNS throttles a request", func() {
+			BeforeEach(func() {
+				sns.throttled["Publish"] = 2
+				source += `,
+		"retry_base_delay": "1ms"`
+			})
+
+			It("should retry the request", func() {
+				Expect(sns.RequestsFor("Publish")).To(HaveLen(3))
+				Expect(sns.RequestsFor("Subscribe")).To(HaveLen(2))
+				Expect(session.Out).To(gbytes.Say(`{"Name":"attempts","Value":"8"}`))
+			})
+		})
+
+		Context("when a dry run is requested", func() {
+			BeforeEach(func() {
+				params = `
+		"subscribers": ["14151234567", "16505550123"],
+		"message": "{{upper \"hello\"}}!",
+		"dry_run": true`
+			})
+
+			It("should only make read-only calls", func() {
+				Expect(sns.Actions()).To(Equal([]string{
+					"CheckIfPhoneNumberIsOptedOut",
+					"CheckIfPhoneNumberIsOptedOut",
+					"ListTopics",
+					"ListSubscriptionsByTopic",
+				}))
+			})
+
+			It("should output a version marked as a dry run with what would be sent", func() {
+				Expect(session.Out).To(gbytes.Say(`"DryRun":"true"`))
+				Expect(session.Out).To(gbytes.Say(`{"Name":"recipients","Value":"\+\*{7}4567,\+\*{7}0123"},{"Name":"message","Value":"HELLO!"}`))
+			})
+		})
+
+		Context("when an acknowledgement is requested", func() {
+			BeforeEach(func() {
+				source += `,
+		"inbound_queue_url": "https://sqs.us-east-1.amazonaws.com/123456789012/sms-replies",
+		"inbound_senders": ["14151234567"]`
+				params += `,
+		"ack": true`
+			})
+
+			It("should ask for the code in the message and record it in the version", func() {
+				publishRequest := sns.RequestsFor("Publish")[0]
+				Expect(publishRequest.Get("Message")).To(MatchRegexp(`^hello!\nReply ACK (\d{4}) to approve or NACK (\d{4}) to reject\.$`))
+				code := publishRequest.Get("Message")[len("hello!\nReply ACK ") : len("hello!\nReply ACK ")+4]
+				Expect(session.Out).To(gbytes.Say(`"AckCode":"%s"`, code))
+			})
+		})
+
+		Context("when the topic has more than one page of subscriptions", func() {
+			BeforeEach(func() {
+				sns.pageSize = 1
+				sns.subscriptions = []string{"14151234567", "17071234567"}
+			})
+
+			It("should only subscribe the subscribers that are new", func() {
+				Expect(sns.RequestsFor("ListSubscriptionsByTopic")).To(HaveLen(2))
+
+				subscribeRequests := sns.RequestsFor("Subscribe")
+				Expect(subscribeRequests).To(HaveLen(1))
+				Expect(subscribeRequests[0].Get("Endpoint")).To(Equal("+16501234567"))
+			})
+		})
+
+		Context("when the provider is Twilio", func() {
+			var twilio *ghttp.Server
+
+			BeforeEach(func() {
+				twilio = ghttp.NewServer()
+				twilio.AppendHandlers(
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("POST", "/2010-04-01/Accounts/AC123/Messages.json"),
+						ghttp.VerifyBasicAuth("AC123", "token123"),
+						ghttp.VerifyForm(map[string][]string{"To": {"+14151234567"}, "From": {"+14155550100"}, "Body": {"hello!"}}),
+						ghttp.RespondWith(http.StatusCreated, `{"sid":"SM1","status":"queued"}`),
+					),
+					ghttp.CombineHandlers(
+						ghttp.VerifyFormKV("To", "+16501234567"),
+						ghttp.RespondWith(http.StatusCreated, `{"sid":"SM2","status":"queued"}`),
+					),
+				)
+				source = fmt.Sprintf(`
+		"provider": "twilio",
+		"twilio_account_sid": "AC123",
+		"twilio_auth_token": "token123",
+		"twilio_from_number": "(415) 555-0100",
+		"default_country_code": "1",
+		"endpoint": %q`, twilio.URL())
+			})
+
+			AfterEach(func() {
+				twilio.Close()
+			})
+
+			It("should send the message to each phone number through Twilio", func() {
+				Expect(twilio.ReceivedRequests()).To(HaveLen(2))
+				Expect(sns.Requests()).To(BeEmpty())
+				Expect(session.Out).To(gbytes.Say(`"MessageID":"SM1,SM2"`))
+			})
+		})
+
+		Context("when duplicates are suppressed", func() {
+			var stateDir string
+
+			BeforeEach(func() {
+				var err error
+				stateDir, err = ioutil.TempDir("", "sms-state")
+				Expect(err).NotTo(HaveOccurred())
+				source += fmt.Sprintf(`,
+		"state": {"backend": "file", "path": %q}`, stateDir)
+				params += `,
+		"dedupe_window": "1h"`
+			})
+
+			AfterEach(func() {
+				os.RemoveAll(stateDir)
+			})
+
+			It("should not send the same message again within the window", func() {
+				Expect(sns.RequestsFor("Publish")).To(HaveLen(1))
+
+				cmd = exec.Command(pathToBuiltBinary)
+				cmd.Stdin = strings.NewReader(fmt.Sprintf(`{"source": {%s}, "params": {%s}}`, source, params))
+				repeat, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(repeat).Should(gexec.Exit(0))
+
+				Expect(sns.RequestsFor("Publish")).To(HaveLen(1))
+				Expect(repeat.Out).To(gbytes.Say(`"Suppressed":"true"`))
+				Expect(repeat.Out).To(gbytes.Say(`{"Name":"suppressed_duplicate","Value":"true"}`))
+			})
+		})
+
+		Context("when a put is retried in the same build", func() {
+			var stateDir string
+
+			BeforeEach(func() {
+				var err error
+				stateDir, err = ioutil.TempDir("", "sms-state")
+				Expect(err).NotTo(HaveOccurred())
+				source += fmt.Sprintf(`,
+		"state": {"backend": "file", "path": %q}`, stateDir)
+				cmd.Env = append(os.Environ(), "BUILD_ID=1234")
+			})
+
+			AfterEach(func() {
+				os.RemoveAll(stateDir)
+			})
+
+			It("should return the version of the earlier attempt without sending the message again", func() {
+				Expect(sns.RequestsFor("Publish")).To(HaveLen(1))
+
+				cmd = exec.Command(pathToBuiltBinary)
+				cmd.Env = append(os.Environ(), "BUILD_ID=1234")
+				cmd.Stdin = strings.NewReader(fmt.Sprintf(`{"source": {%s}, "params": {%s}}`, source, params))
+				retried, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(retried).Should(gexec.Exit(0))
+
+				Expect(sns.RequestsFor("Publish")).To(HaveLen(1))
+				Expect(retried.Out.Contents()).To(MatchJSON(session.Out.Contents()))
+				Expect(retried.Err).To(gbytes.Say("this put already sent the message in an earlier attempt"))
+			})
+
+			It("should send the message again in another build", func() {
+				cmd = exec.Command(pathToBuiltBinary)
+				cmd.Env = append(os.Environ(), "BUILD_ID=1235")
+				cmd.Stdin = strings.NewReader(fmt.Sprintf(`{"source": {%s}, "params": {%s}}`, source, params))
+				next, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(next).Should(gexec.Exit(0))
+
+				Expect(sns.RequestsFor("Publish")).To(HaveLen(2))
+			})
+		})
+
+		Context("when there is a chain of providers", func() {
+			var twilio *ghttp.Server
+
+			BeforeEach(func() {
+				sns.throttled["Publish"] = 1
+				twilio = ghttp.NewServer()
+				twilio.AppendHandlers(ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", "/2010-04-01/Accounts/AC123/Messages.json"),
+					ghttp.VerifyFormKV("To", "+14151234567"),
+					ghttp.RespondWith(http.StatusCreated, `{"sid":"SM1","status":"queued"}`),
+				))
+				source += fmt.Sprintf(`,
+		"providers": [
+			{"max_retries": 0},
+			{
+				"provider": "twilio",
+				"twilio_account_sid": "AC123",
+				"twilio_auth_token": "token123",
+				"twilio_from_number": "+14155550100",
+				"endpoint": %q
+			}
+		]`, twilio.URL())
+			})
+
+			AfterEach(func() {
+				twilio.Close()
+			})
+
+			It("should send through Twilio only to the subscriber SNS failed", func() {
+				Expect(sns.RequestsFor("Publish")).To(HaveLen(2))
+				Expect(twilio.ReceivedRequests()).To(HaveLen(1))
+				Expect(session.Out).To(gbytes.Say(`{"Name":"\+\*{7}4567","Value":"sent via twilio:AC123"},{"Name":"\+\*{7}4567","Value":"sent via sns:eu-west-1"}`))
+				Expect(session.Out).To(gbytes.Say(`{"Name":"attempts","Value":"5"}`))
+			})
+		})
+
+		Context("when the provider is a webhook", func() {
+			var gateway *ghttp.Server
+
+			BeforeEach(func() {
+				gateway = ghttp.NewServer()
+				gateway.AppendHandlers(
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("POST", "/sms"),
+						ghttp.VerifyHeaderKV("Authorization", "Bearer token123"),
+						ghttp.VerifyJSON(`{"number": "+14151234567", "text": "hello!"}`),
+						ghttp.RespondWith(http.StatusOK, `{"id": "gw-1"}`),
+					),
+					ghttp.CombineHandlers(
+						ghttp.VerifyJSON(`{"number": "+16501234567", "text": "hello!"}`),
+						ghttp.RespondWith(http.StatusOK, `{"id": "gw-2"}`),
+					),
+				)
+				source = fmt.Sprintf(`
+		"provider": "webhook",
+		"webhook": {
+			"url": %q,
+			"bearer_token": "token123",
+			"body": "{\"number\": {{json .Recipient}}, \"text\": {{json .Message}}}",
+			"message_id_path": "id"
+		}`, gateway.URL()+"/sms")
+			})
+
+			AfterEach(func() {
+				gateway.Close()
+			})
+
+			It("should send the message to each phone number through the gateway", func() {
+				Expect(gateway.ReceivedRequests()).To(HaveLen(2))
+				Expect(sns.Requests()).To(BeEmpty())
+				Expect(session.Out).To(gbytes.Say(`"MessageID":"gw-1,gw-2"`))
+			})
+		})
+
+		Context("when the mode is direct", func() {
+			BeforeEach(func() {
+				source += `,
+		"mode": "direct"`
+			})
+
+			It("should publish the message to each phone number", func() {
+				Expect(sns.Actions()).To(Equal([]string{"CheckIfPhoneNumberIsOptedOut", "CheckIfPhoneNumberIsOptedOut", "Publish", "Publish"}))
+
+				publishRequests := sns.RequestsFor("Publish")
+				Expect(publishRequests[0].Get("PhoneNumber")).To(Equal("+14151234567"))
+				Expect(publishRequests[0].Get("Message")).To(Equal("hello!"))
+				Expect(publishRequests[0].Get("TopicArn")).To(BeEmpty())
+				Expect(publishRequests[1].Get("PhoneNumber")).To(Equal("+16501234567"))
+			})
+
+			Context("when there is an on-call schedule", func() {
+				BeforeEach(func() {
+					source += `,
+		"schedule": {
+			"time_zone": "Europe/London",
+			"layers": [{"members": ["+447700900123"], "start": "2026-01-05", "handoff": "09:00"}]
+		}`
+				})
+
+				It("should publish the message to the member on call as well as the subscribers", func() {
+					publishRequests := sns.RequestsFor("Publish")
+					Expect(publishRequests).To(HaveLen(3))
+					Expect(publishRequests[2].Get("PhoneNumber")).To(Equal("+447700900123"))
+					Expect(session.Out).To(gbytes.Say(`{"Name":"on_call","Value":"\+\*{8}0123"}`))
+				})
+			})
+
+			Context("when the on-call schedule is a file in the build directory", func() {
+				var buildDir string
+
+				BeforeEach(func() {
+					var err error
+					buildDir, err = ioutil.TempDir("", "sms-resource-out")
+					Expect(err).NotTo(HaveOccurred())
+					cmd = exec.Command(pathToBuiltBinary, buildDir)
+
+					Expect(os.MkdirAll(filepath.Join(buildDir, "rota"), 0755)).To(Succeed())
+					err = ioutil.WriteFile(filepath.Join(buildDir, "rota", "schedule.json"), []byte(`{
+  "layers": [{"members": ["+447700900123"], "start": "2026-01-05"}],
+  "overrides": [{"member": "+447700900456", "start": "2000-01-01T00:00:00Z", "end": "2100-01-01T00:00:00Z"}]
+}`), 0644)
+					Expect(err).NotTo(HaveOccurred())
+
+					source += `,
+		"schedule": {"file": "rota/schedule.json"}`
+					params = `
+		"message": "hello!"`
+				})
+
+				AfterEach(func() {
+					os.RemoveAll(buildDir)
+				})
+
+				It("should publish the message to the member on call", func() {
+					publishRequests := sns.RequestsFor("Publish")
+					Expect(publishRequests).To(HaveLen(1))
+					Expect(publishRequests[0].Get("PhoneNumber")).To(Equal("+447700900456"))
+				})
+
+				Context("when no one is on call", func() {
+					BeforeEach(func() {
+						err := ioutil.WriteFile(filepath.Join(buildDir, "rota", "schedule.json"), []byte(`{
+  "layers": [{"members": ["+447700900123"], "start": "2100-01-01"}]
+}`), 0644)
+						Expect(err).NotTo(HaveOccurred())
+						exitCode = 1
+					})
+
+					It("should fail without publishing anything", func() {
+						Expect(sns.RequestsFor("Publish")).To(BeEmpty())
+						Expect(session.Err).To(gbytes.Say(`no one is on call in source.schedule at \S+ and params.subscribers is empty`))
+					})
+				})
+			})
+
+			Context("when a subscriber has opted out", func() {
+				BeforeEach(func() {
+					sns.optedOut["+14151234567"] = true
+				})
+
+				It("should only publish to the other subscribers and report the opted-out number", func() {
+					publishRequests := sns.RequestsFor("Publish")
+					Expect(publishRequests).To(HaveLen(1))
+					Expect(publishRequests[0].Get("PhoneNumber")).To(Equal("+16501234567"))
+					Expect(session.Out).To(gbytes.Say(`"Recipients":"\+\*{7}4567"`))
+					Expect(session.Out).To(gbytes.Say(`{"Name":"opted_out","Value":"\+\*{7}4567"}`))
+				})
+
+				Context("when failing on opted-out numbers is requested", func() {
+					BeforeEach(func() {
+						params += `,
+		"fail_on_opted_out": true`
+						exitCode = 1
+					})
+
+					It("should fail without publishing anything", func() {
+						Expect(sns.RequestsFor("Publish")).To(BeEmpty())
+						Expect(session.Err).To(gbytes.Say(`1 of 2 subscribers have opted out of SMS messages: \+\*{7}4567`))
+					})
+				})
+
+				Context("when the number is opted in again", func() {
+					BeforeEach(func() {
+						params += `,
+		"opt_in": ["(415) 123-4567"]`
+						source += `,
+		"default_country_code": "1"`
+					})
+
+					It("should opt in the opted-out number before publishing to it", func() {
+						Expect(sns.Actions()).To(Equal([]string{"CheckIfPhoneNumberIsOptedOut", "OptInPhoneNumber", "CheckIfPhoneNumberIsOptedOut", "Publish", "Publish"}))
+						Expect(sns.RequestsFor("OptInPhoneNumber")[0].Get("phoneNumber")).To(Equal("+14151234567"))
+						Expect(session.Out).To(gbytes.Say(`{"Name":"opted_in","Value":"\+\*{7}4567"}`))
+					})
+				})
+			})
+
+			Context("when waiting for delivery is requested", func() {
+				BeforeEach(func() {
+					sns.deliveries["+14151234567"] = "SUCCESS"
+					sns.deliveries["+16501234567"] = "SUCCESS"
+					params += `,
+		"wait_for_delivery": "1m"`
+				})
+
+				It("should search the delivery status logs for the message IDs", func() {
+					Expect(sns.Actions()).To(Equal([]string{"CheckIfPhoneNumberIsOptedOut", "CheckIfPhoneNumberIsOptedOut", "Publish", "Publish", "DescribeLogGroups", "FilterLogEvents"}))
+
+					filterRequest := sns.RequestsFor("FilterLogEvents")[0]
+					Expect(filterRequest.Get("Body")).To(ContainSubstring(`"logGroupName":"sns/eu-west-1/123456789012/DirectPublishToPhoneNumber"`))
+					Expect(filterRequest.Get("Body")).To(ContainSubstring(`$.notification.messageId = \"message-3\" || $.notification.messageId = \"message-4\"`))
+				})
+
+				It("should report the deliveries", func() {
+					Expect(session.Out).To(gbytes.Say(`{"Name":"delivered","Value":"2"},{"Name":"delivery_failed","Value":"0"},{"Name":"delivery_unknown","Value":"0"}`))
+				})
+
+				Context("when there are too many messages to search for at once", func() {
+					BeforeEach(func() {
+						subscribers := []string{}
+						for i := 0; i < 30; i++ {
+							number := fmt.Sprintf("+1415555%04d", i)
+							subscribers = append(subscribers, strconv.Quote(number))
+							sns.deliveries[number] = "SUCCESS"
+						}
+						params = fmt.Sprintf(`
+		"subscribers": [%s],
+		"message": "hello!",
+		"wait_for_delivery": "1m"`, strings.Join(subscribers, ", "))
+					})
+
+					It("should search for them in batches", func() {
+						Expect(len(sns.RequestsFor("FilterLogEvents"))).To(BeNumerically(">", 1))
+						Expect(session.Out).To(gbytes.Say(`{"Name":"delivered","Value":"30"},{"Name":"delivery_failed","Value":"0"},{"Name":"delivery_unknown","Value":"0"}`))
+					})
+				})
+
+				Context("when a carrier rejects the message", func() {
+					BeforeEach(func() {
+						sns.deliveries["+16501234567"] = "FAILURE"
+						exitCode = 1
+					})
+
+					It("should fail listing the rejected deliveries", func() {
+						Expect(session.Err).To(gbytes.Say(`1 of 2 deliveries failed:\n  \+\*{7}4567: FAILURE response`))
+					})
+
+					Context("when the put is retried in the same build", func() {
+						var stateDir string
+
+						BeforeEach(func() {
+							var err error
+							stateDir, err = ioutil.TempDir("", "sms-state")
+							Expect(err).NotTo(HaveOccurred())
+							source += fmt.Sprintf(`,
+		"state": {"backend": "file", "path": %q}`, stateDir)
+							cmd.Env = append(os.Environ(), "BUILD_ID=1234")
+						})
+
+						AfterEach(func() {
+							os.RemoveAll(stateDir)
+						})
+
+						It("should return the version of the earlier attempt without sending the message again", func() {
+							Expect(sns.RequestsFor("Publish")).To(HaveLen(2))
+
+							cmd = exec.Command(pathToBuiltBinary)
+							cmd.Env = append(os.Environ(), "BUILD_ID=1234")
+							cmd.Stdin = strings.NewReader(fmt.Sprintf(`{"source": {%s}, "params": {%s}}`, source, params))
+							retried, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+							Expect(err).NotTo(HaveOccurred())
+							Eventually(retried).Should(gexec.Exit(0))
+
+							Expect(sns.RequestsFor("Publish")).To(HaveLen(2))
+							Expect(retried.Out).To(gbytes.Say(`"MessageID":"message-3,message-4"`))
+							Expect(retried.Out).To(gbytes.Say(`{"Name":"earlier_attempt_incomplete","Value":"true"}`))
+						})
+					})
+				})
+			})
+		})
 	})
 })
@@ -0,0 +1,13 @@
+package retry
+
+import "time"
+
+func SetClock(sleepFunc func(time.Duration), nowFunc func() time.Time) {
+	sleep = sleepFunc
+	now = nowFunc
+}
+
+func ResetClock() {
+	sleep = time.Sleep
+	now = time.Now
+}
@@ -0,0 +1,298 @@
+package retry
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/nickwei84/sms-resource/lib/sms"
+	"github.com/nickwei84/sms-resource/out/application"
+	"github.com/nickwei84/sms-resource/out/models"
+)
+
+var (
+	sleep = time.Sleep
+	now   = time.Now
+)
+
+type Policy struct {
+	MaxRetries int
+	BaseDelay  time.Duration
+	Jitter     time.Duration
+	Deadline   time.Duration
+}
+
+var DefaultPolicy = Policy{
+	MaxRetries: 3,
+	BaseDelay:  500 * time.Millisecond,
+	Jitter:     250 * time.Millisecond,
+	Deadline:   2 * time.Minute,
+}
+
+// NewPolicy returns the default policy with any retry settings from source
+// applied.
+func NewPolicy(source models.Source) Policy {
+	policy := DefaultPolicy
+
+	if source.MaxRetries != nil {
+		policy.MaxRetries = *source.MaxRetries
+	}
+
+	if source.RetryBaseDelay != 0 {
+		policy.BaseDelay = time.Duration(source.RetryBaseDelay)
+	}
+
+	if source.RetryJitter != 0 {
+		policy.Jitter = time.Duration(source.RetryJitter)
+	}
+
+	if source.RetryDeadline != 0 {
+		policy.Deadline = time.Duration(source.RetryDeadline)
+	}
+
+	return policy
+}
+
+// Error codes returned by AWS for failures that are worth retrying: throttling,
+// server-side errors and requests that never reached the service.
+var retryableCodes = map[string]bool{
+	"Throttling":                             true,
+	"ThrottlingException":                    true,
+	"ThrottledException":                     true,
+	"RequestThrottled":                       true,
+	"TooManyRequestsException":               true,
+	"ProvisionedThroughputExceededException": true,
+	"ServiceUnavailable":                     true,
+	"InternalError":                          true,
+	"InternalFailure":                        true,
+	"RequestTimeout":                         true,
+	"RequestTimeoutException":                true,
+	"RequestError":                           true,
+}
+
+// Error codes that are retryable for any call, but may come from a send that
+// reached the service before failing, so the message may have been accepted.
+var sentUnknownCodes = map[string]bool{
+	"RequestTimeout":          true,
+	"RequestTimeoutException": true,
+	"RequestError":            true,
+}
+
+// retryableError is implemented by the errors of providers other than AWS,
+// which know for themselves whether they are transient, and whether a send
+// that failed with them was rejected before the message was accepted.
+type retryableError interface {
+	Retryable() bool
+	RetryableSend() bool
+}
+
+// IsRetryable reports whether err, or a provider error it wraps, is transient.
+func IsRetryable(err error) bool {
+	var providerErr retryableError
+	if errors.As(err, &providerErr) {
+		return providerErr.Retryable()
+	}
+
+	var requestFailure awserr.RequestFailure
+	if errors.As(err, &requestFailure) {
+		if requestFailure.StatusCode() >= 500 || requestFailure.StatusCode() == 429 {
+			return true
+		}
+	}
+
+	var awsErr awserr.Error
+	if errors.As(err, &awsErr) {
+		return retryableCodes[awsErr.Code()]
+	}
+
+	return false
+}
+
+// IsRetryableSend reports whether a send that failed with err is transient and
+// was rejected before the message was accepted, so that sending it again
+// cannot send it twice. Throttling and server errors are; requests that failed
+// without a response, or timed out, may have been accepted and are not.
+func IsRetryableSend(err error) bool {
+	var providerErr retryableError
+	if errors.As(err, &providerErr) {
+		return providerErr.RetryableSend()
+	}
+
+	var awsErr awserr.Error
+	if errors.As(err, &awsErr) && sentUnknownCodes[awsErr.Code()] {
+		return false
+	}
+
+	return IsRetryable(err)
+}
+
+// SMSService retries the calls of the SMSService it wraps with exponential
+// backoff when they fail with a retryable error. Every retry shares the
+// policy's deadline, which starts when the SMSService is created.
+type SMSService struct {
+	client   application.SMSService
+	policy   Policy
+	deadline time.Time
+	attempts int
+}
+
+func NewSMSService(client application.SMSService, policy Policy) *SMSService {
+	return &SMSService{
+		client:   client,
+		policy:   policy,
+		deadline: now().Add(policy.Deadline),
+	}
+}
+
+// Attempts returns the number of calls made to the wrapped SMSService,
+// including retries.
+func (s *SMSService) Attempts() int {
+	return s.attempts
+}
+
+func (s *SMSService) do(call func() error) error {
+	return s.doIf(IsRetryable, call)
+}
+
+// doSend retries a call that sends a message only when it cannot have been
+// sent already.
+func (s *SMSService) doSend(call func() error) error {
+	return s.doIf(IsRetryableSend, call)
+}
+
+func (s *SMSService) doIf(isRetryable func(error) bool, call func() error) error {
+	var err error
+
+	for retry := 0; ; retry++ {
+		s.attempts++
+		err = call()
+		if err == nil || !isRetryable(err) {
+			return err
+		}
+
+		if retry >= s.policy.MaxRetries {
+			return fmt.Errorf("%w (gave up after %d attempts)", err, retry+1)
+		}
+
+		delay := s.delay(retry)
+		if now().Add(delay).After(s.deadline) {
+			return fmt.Errorf("%w (gave up after %d attempts, retry deadline of %s reached)", err, retry+1, s.policy.Deadline)
+		}
+
+		sleep(delay)
+	}
+}
+
+func (s *SMSService) delay(retry int) time.Duration {
+	delay := s.policy.BaseDelay << uint(retry)
+	if s.policy.Jitter > 0 {
+		delay += time.Duration(rand.Int63n(int64(s.policy.Jitter)))
+	}
+	return delay
+}
+
+func (s *SMSService) CreateTopic(topic string) (string, error) {
+	var topicArn string
+	err := s.do(func() error {
+		var err error
+		topicArn, err = s.client.CreateTopic(topic)
+		return err
+	})
+	return topicArn, err
+}
+
+func (s *SMSService) FindTopic(topic string) (string, error) {
+	var topicArn string
+	err := s.do(func() error {
+		var err error
+		topicArn, err = s.client.FindTopic(topic)
+		return err
+	})
+	return topicArn, err
+}
+
+func (s *SMSService) GetExistingSubscribers(topicID string) ([]sms.Subscription, error) {
+	var subscriptions []sms.Subscription
+	err := s.do(func() error {
+		var err error
+		subscriptions, err = s.client.GetExistingSubscribers(topicID)
+		return err
+	})
+	return subscriptions, err
+}
+
+// CreateNewSubscriptions retries the whole batch; subscribing a number that is
+// already subscribed is a no-op in SNS.
+func (s *SMSService) CreateNewSubscriptions(topicID string, newSubscribers []string) ([]sms.Subscription, error) {
+	var subscriptions []sms.Subscription
+	err := s.do(func() error {
+		var err error
+		subscriptions, err = s.client.CreateNewSubscriptions(topicID, newSubscribers)
+		return err
+	})
+	return subscriptions, err
+}
+
+func (s *SMSService) RemoveSubscriptions(subscriptions []sms.Subscription) error {
+	return s.do(func() error {
+		return s.client.RemoveSubscriptions(subscriptions)
+	})
+}
+
+func (s *SMSService) PublishMessage(topicID string, message string, attributes sms.MessageAttributes) (string, error) {
+	var messageID string
+	err := s.doSend(func() error {
+		var err error
+		messageID, err = s.client.PublishMessage(topicID, message, attributes)
+		return err
+	})
+	return messageID, err
+}
+
+// PublishToPhone returns an sms.MaybeSentError when the send failed in a way
+// that is not retried because the message may have been accepted, so that it
+// is not sent through another provider either.
+func (s *SMSService) PublishToPhone(phoneNumber string, message string, attributes sms.MessageAttributes) (string, error) {
+	var messageID string
+	err := s.doSend(func() error {
+		var err error
+		messageID, err = s.client.PublishToPhone(phoneNumber, message, attributes)
+		return err
+	})
+	if err != nil && IsRetryable(err) && !IsRetryableSend(err) {
+		return messageID, sms.MaybeSentError{Err: err}
+	}
+	return messageID, err
+}
+
+func (s *SMSService) IsOptedOut(phoneNumber string) (bool, error) {
+	var optedOut bool
+	err := s.do(func() error {
+		var err error
+		optedOut, err = s.client.IsOptedOut(phoneNumber)
+		return err
+	})
+	return optedOut, err
+}
+
+// OptIn is safe to retry; opting in a number that is already opted in is a
+// no-op in SNS.
+func (s *SMSService) OptIn(phoneNumber string) error {
+	return s.do(func() error {
+		return s.client.OptIn(phoneNumber)
+	})
+}
+
+// WaitForDelivery retries the whole wait, which only reads the delivery status
+// logs.
+func (s *SMSService) WaitForDelivery(messageIDs []string, perMessage int, since time.Time, timeout time.Duration) ([]sms.DeliveryStatus, error) {
+	var statuses []sms.DeliveryStatus
+	err := s.do(func() error {
+		var err error
+		statuses, err = s.client.WaitForDelivery(messageIDs, perMessage, since, timeout)
+		return err
+	})
+	return statuses, err
+}
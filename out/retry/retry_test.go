@@ -0,0 +1,234 @@
+package retry_test
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/nickwei84/sms-resource/lib/sms"
+	"github.com/nickwei84/sms-resource/lib/twilioclient"
+	"github.com/nickwei84/sms-resource/out/application/applicationfakes"
+	"github.com/nickwei84/sms-resource/out/models"
+	"github.com/nickwei84/sms-resource/out/retry"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Retry", func() {
+	Describe("NewPolicy", func() {
+		It("should return the default policy when source has no retry settings", func() {
+			Expect(retry.NewPolicy(models.Source{})).To(Equal(retry.DefaultPolicy))
+		})
+
+		It("should apply the retry settings from source", func() {
+			maxRetries := 0
+			Expect(retry.NewPolicy(models.Source{
+				MaxRetries:     &maxRetries,
+				RetryBaseDelay: models.Duration(time.Second),
+				RetryJitter:    models.Duration(time.Millisecond),
+				RetryDeadline:  models.Duration(time.Minute),
+			})).To(Equal(retry.Policy{
+				MaxRetries: 0,
+				BaseDelay:  time.Second,
+				Jitter:     time.Millisecond,
+				Deadline:   time.Minute,
+			}))
+		})
+	})
+
+	Describe("IsRetryable", func() {
+		It("should retry throttling errors", func() {
+			err := fmt.Errorf("error publishing message: %w", awserr.New("Throttling", "Rate exceeded", nil))
+			Expect(retry.IsRetryable(err)).To(BeTrue())
+		})
+
+		It("should retry server errors", func() {
+			err := awserr.NewRequestFailure(awserr.New("InternalFailure", "oops", nil), 503, "request-id")
+			Expect(retry.IsRetryable(err)).To(BeTrue())
+		})
+
+		It("should retry errors sending the request", func() {
+			err := awserr.New("RequestError", "send request failed", errors.New("connection reset"))
+			Expect(retry.IsRetryable(err)).To(BeTrue())
+		})
+
+		It("should not retry client errors", func() {
+			err := awserr.NewRequestFailure(awserr.New("InvalidParameter", "Invalid parameter: PhoneNumber", nil), 400, "request-id")
+			Expect(retry.IsRetryable(err)).To(BeFalse())
+		})
+
+		It("should retry provider errors that report themselves as retryable", func() {
+			err := fmt.Errorf("error publishing message to +14151234567: %w", twilioclient.Error{StatusCode: 429, Code: 20429, Message: "Too Many Requests"})
+			Expect(retry.IsRetryable(err)).To(BeTrue())
+			Expect(retry.IsRetryable(twilioclient.Error{StatusCode: 400, Code: 21211, Message: "Invalid 'To' Phone Number"})).To(BeFalse())
+		})
+
+		It("should not retry errors that are not from AWS", func() {
+			Expect(retry.IsRetryable(errors.New("something went wrong"))).To(BeFalse())
+		})
+	})
+
+	Describe("IsRetryableSend", func() {
+		It("should retry throttling and server errors", func() {
+			Expect(retry.IsRetryableSend(awserr.New("Throttling", "Rate exceeded", nil))).To(BeTrue())
+			Expect(retry.IsRetryableSend(awserr.NewRequestFailure(awserr.New("InternalFailure", "oops", nil), 503, "request-id"))).To(BeTrue())
+		})
+
+		It("should not retry requests that may have reached the service", func() {
+			err := fmt.Errorf("error publishing message: %w", awserr.New("RequestError", "send request failed", errors.New("connection reset")))
+			Expect(retry.IsRetryableSend(err)).To(BeFalse())
+			Expect(retry.IsRetryableSend(awserr.New("RequestTimeout", "read timed out", nil))).To(BeFalse())
+		})
+
+		It("should not retry provider requests that got no response", func() {
+			Expect(retry.IsRetryableSend(twilioclient.Error{StatusCode: 0, Message: "connection reset"})).To(BeFalse())
+			Expect(retry.IsRetryableSend(twilioclient.Error{StatusCode: 503, Code: 20503, Message: "Service Unavailable"})).To(BeTrue())
+		})
+
+		It("should not retry messages that were accepted without a message ID", func() {
+			Expect(retry.IsRetryableSend(sms.MessageIDError{PhoneNumber: "+14151234567", Err: errors.New(`"id" not found`)})).To(BeFalse())
+		})
+	})
+
+	Describe("SMSService", func() {
+		var (
+			client  *applicationfakes.FakeSMSService
+			policy  retry.Policy
+			service *retry.SMSService
+			clock   time.Time
+			delays  []time.Duration
+		)
+
+		throttled := fmt.Errorf("error publishing message: %w", awserr.New("Throttling", "Rate exceeded", nil))
+
+		BeforeEach(func() {
+			clock = time.Date(2016, time.May, 4, 13, 30, 0, 0, time.UTC)
+			delays = []time.Duration{}
+			retry.SetClock(func(d time.Duration) {
+				delays = append(delays, d)
+				clock = clock.Add(d)
+			}, func() time.Time {
+				return clock
+			})
+
+			client = new(applicationfakes.FakeSMSService)
+			policy = retry.Policy{
+				MaxRetries: 3,
+				BaseDelay:  time.Second,
+				Deadline:   time.Minute,
+			}
+		})
+
+		AfterEach(func() {
+			retry.ResetClock()
+		})
+
+		JustBeforeEach(func() {
+			service = retry.NewSMSService(client, policy)
+		})
+
+		It("should pass through successful calls", func() {
+			client.CreateTopicReturns("my-topic-arn", nil)
+			topicArn, err := service.CreateTopic("my-topic")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(topicArn).To(Equal("my-topic-arn"))
+			Expect(client.CreateTopicArgsForCall(0)).To(Equal("my-topic"))
+			Expect(service.Attempts()).To(Equal(1))
+		})
+
+		It("should retry retryable errors with exponential backoff", func() {
+			calls := 0
+			client.PublishMessageStub = func(topicID string, message string, attributes sms.MessageAttributes) (string, error) {
+				calls++
+				if calls < 3 {
+					return "", throttled
+				}
+				return "message-id", nil
+			}
+
+			messageID, err := service.PublishMessage("my-topic-arn", "hello", sms.MessageAttributes{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(messageID).To(Equal("message-id"))
+			Expect(client.PublishMessageCallCount()).To(Equal(3))
+			Expect(delays).To(Equal([]time.Duration{time.Second, 2 * time.Second}))
+			Expect(service.Attempts()).To(Equal(3))
+		})
+
+		It("should not retry fatal errors", func() {
+			client.PublishToPhoneReturns("", errors.New("error publishing message to +14151234567: invalid parameter"))
+			_, err := service.PublishToPhone("+14151234567", "hello", sms.MessageAttributes{})
+			Expect(err).To(MatchError("error publishing message to +14151234567: invalid parameter"))
+			Expect(client.PublishToPhoneCallCount()).To(Equal(1))
+			Expect(delays).To(BeEmpty())
+		})
+
+		It("should not retry a send that may have been accepted", func() {
+			client.PublishToPhoneReturns("", awserr.New("RequestError", "send request failed", errors.New("connection reset")))
+			_, err := service.PublishToPhone("+14151234567", "hello", sms.MessageAttributes{})
+			Expect(err).To(BeAssignableToTypeOf(sms.MaybeSentError{}))
+			Expect(client.PublishToPhoneCallCount()).To(Equal(1))
+		})
+
+		It("should report a provider request that got no response as maybe sent", func() {
+			client.PublishToPhoneReturns("", twilioclient.Error{StatusCode: 0, Message: "connection reset"})
+			_, err := service.PublishToPhone("+14151234567", "hello", sms.MessageAttributes{})
+			Expect(err).To(BeAssignableToTypeOf(sms.MaybeSentError{}))
+			Expect(client.PublishToPhoneCallCount()).To(Equal(1))
+		})
+
+		It("should retry other calls that failed without a response", func() {
+			client.IsOptedOutStub = func(phoneNumber string) (bool, error) {
+				if client.IsOptedOutCallCount() == 1 {
+					return false, awserr.New("RequestError", "send request failed", errors.New("connection reset"))
+				}
+				return false, nil
+			}
+			optedOut, err := service.IsOptedOut("+14151234567")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(optedOut).To(BeFalse())
+			Expect(client.IsOptedOutCallCount()).To(Equal(2))
+		})
+
+		It("should give up after the maximum number of retries", func() {
+			client.PublishMessageReturns("", throttled)
+			_, err := service.PublishMessage("my-topic-arn", "hello", sms.MessageAttributes{})
+			Expect(err).To(MatchError("error publishing message: Throttling: Rate exceeded (gave up after 4 attempts)"))
+			Expect(client.PublishMessageCallCount()).To(Equal(4))
+			Expect(retry.IsRetryable(err)).To(BeTrue())
+		})
+
+		It("should add jitter to the delay", func() {
+			policy.Jitter = 500 * time.Millisecond
+			service = retry.NewSMSService(client, policy)
+			client.CreateNewSubscriptionsReturns(nil, throttled)
+
+			service.CreateNewSubscriptions("my-topic-arn", []string{"+14151234567"})
+			Expect(delays).To(HaveLen(3))
+			Expect(delays[0]).To(BeNumerically(">=", time.Second))
+			Expect(delays[0]).To(BeNumerically("<", 1500*time.Millisecond))
+			Expect(delays[2]).To(BeNumerically(">=", 4*time.Second))
+			Expect(delays[2]).To(BeNumerically("<", 4500*time.Millisecond))
+		})
+
+		Context("when the next retry would pass the deadline", func() {
+			BeforeEach(func() {
+				policy.Deadline = 5 * time.Second
+			})
+
+			It("should give up", func() {
+				client.GetExistingSubscribersReturns(nil, throttled)
+				_, err := service.GetExistingSubscribers("my-topic-arn")
+				Expect(err).To(MatchError("error publishing message: Throttling: Rate exceeded (gave up after 3 attempts, retry deadline of 5s reached)"))
+				Expect(delays).To(Equal([]time.Duration{time.Second, 2 * time.Second}))
+			})
+		})
+
+		It("should count attempts across all calls", func() {
+			client.FindTopicReturns("my-topic-arn", nil)
+			service.FindTopic("my-topic")
+			service.RemoveSubscriptions([]sms.Subscription{})
+			Expect(service.Attempts()).To(Equal(2))
+		})
+	})
+})